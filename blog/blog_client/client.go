@@ -2,138 +2,218 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
-	"io"
-	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogauth"
 	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/config"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"github.com/Peter-Yocum/grpc-go-course/tracing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
-func main() {
-	fmt.Println("Hello I'm a client")
-
-	opts := grpc.WithInsecure()
-
-	conn, err := grpc.Dial("localhost:50051", opts)
-	if err != nil {
-		log.Fatalf("could not connect: %v\n", err)
-	}
-	defer conn.Close()
+// cli is what a command works with once its flags are parsed.
+type cli struct {
+	blogs    blogpb.BlogServiceClient
+	comments blogpb.CommentServiceClient
+	// out prints the results of commands taking -o.
+	out *printer
+}
 
-	client := blogpb.NewBlogServiceClient(conn)
+// command is a subcommand of blog_client.
+type command struct {
+	name    string
+	args    string // positional arguments, shown in the usage
+	summary string
+	// output adds the -o flag picking how results are printed.
+	output bool
+	// setup registers the flags of the command and returns the function
+	// running it with the remaining arguments.
+	setup func(flags *flag.FlagSet) func(c *cli, args []string) error
+}
 
-	fmt.Printf("created client: %f\n", client)
+var commands = []*command{
+	{name: "create", summary: "create a blog", output: true, setup: createCommand},
+	{name: "get", args: "[ID]", summary: "print a blog by id or slug", output: true, setup: getCommand},
+	{name: "update", args: "ID", summary: "change the given fields of a blog", output: true, setup: updateCommand},
+	{name: "delete", args: "ID...", summary: "delete blogs", setup: deleteCommand},
+	{name: "list", summary: "list blogs", output: true, setup: listCommand},
+	{name: "search", args: "QUERY...", summary: "search blog titles and content", output: true, setup: searchCommand},
+	{name: "export", summary: "write every blog to JSON Lines or Markdown files", setup: exportCommand},
+	{name: "import", summary: "create or update blogs from an export", setup: importCommand},
+	{name: "demo", summary: "run through every call once", setup: demoCommand},
+}
 
-	blog := &blogpb.Blog{
-		AuthorId: "Peter",
-		Title:    "My First Blog",
-		Content:  "Content of first blog",
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
 	}
-	response := sendCreateBlogRequest(client, blog)
-
-	//proof trying to read a blog that doesn't exist creates an error but doesn't break program
-	junk_blog := sendReadBlogRequest(client, "fake id")
-	fmt.Printf("The error for retrieving blog is: %v\n", junk_blog)
-
-	retrieved_blog := sendReadBlogRequest(client, response.Blog.Id)
-	fmt.Printf("The retrieved blog is: %v\n", retrieved_blog)
-
-	retrieved_blog.Content = "New content to show update works!"
-
-	//proof updating a blog that doesn't exist creates an error but doesn't break program
-	junk_blog = &blogpb.Blog{
-		Id:       "62406ccd33ece94df7aac7a8",
-		AuthorId: "not a real author",
-		Title:    "not a real title",
-		Content:  "no real content",
+	var cmd *command
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			cmd = c
+		}
 	}
-	junk_blog = sendUpdateBlogRequest(client, junk_blog)
-	fmt.Printf("The error for updating a blog is: %v\n", junk_blog)
-
-	updated_blog := sendUpdateBlogRequest(client, retrieved_blog)
-	fmt.Printf("The updated blog is: %v\n", updated_blog)
-
-	deleted_id := sendDeleteBlogRequest(client, junk_blog.GetId())
-	fmt.Printf("Just deleted blog with id: %v\n", deleted_id)
-
-	deleted_id = sendDeleteBlogRequest(client, updated_blog.GetId())
-	fmt.Printf("Just deleted blog with id: %v\n", deleted_id)
-
-	fmt.Printf("Getting blog list: \n")
-	blogs := sendListBlogRequest(client)
-	for index, blog := range blogs {
-		fmt.Printf("The %v-th blog is: %v\n", index, blog)
+	if cmd == nil {
+		if os.Args[1] != "-h" && os.Args[1] != "-help" && os.Args[1] != "help" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
 	}
-}
 
-func sendCreateBlogRequest(client blogpb.BlogServiceClient, blog *blogpb.Blog) *blogpb.CreateBlogResponse {
+	flags := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %v\n\n%v\n\n", strings.TrimSpace("blog_client "+cmd.name+" [flags] "+cmd.args), cmd.summary)
+		flags.PrintDefaults()
+	}
+	conn := addConnFlags(flags)
+	format := ""
+	if cmd.output {
+		flags.StringVar(&format, "o", formatTable, "output format: table, json or yaml")
+	}
+	run := cmd.setup(flags)
+	args := parseArgs(flags, os.Args[2:])
 
-	fmt.Println("Creating create blog request...")
-	in := &blogpb.CreateBlogRequest{
-		Blog: blog,
+	out, err := newPrinter(os.Stdout, format)
+	if err != nil {
+		fail(err)
 	}
-	fmt.Println("Sending create blog request")
-	createBlogResponse, err := client.CreateBlog(context.Background(), in)
+	if err := startTracing(); err != nil {
+		fail(err)
+	}
+	cc, err := conn.dial()
+	if err != nil {
+		fail(err)
+	}
+	err = run(&cli{
+		blogs:    blogpb.NewBlogServiceClient(cc),
+		comments: blogpb.NewCommentServiceClient(cc),
+		out:      out,
+	}, args)
+	cc.Close()
 	if err != nil {
-		log.Fatalf("Error when creating blog: %v", err)
+		fail(err)
 	}
-	fmt.Printf("Create blog response received: %v\n", createBlogResponse)
-	return createBlogResponse
+	flushTraces()
 }
 
-func sendReadBlogRequest(client blogpb.BlogServiceClient, id string) *blogpb.Blog {
+// stopTracing is set by startTracing.
+var stopTracing func(context.Context) error
 
-	res, err := client.ReadBlog(context.Background(), &blogpb.ReadBlogRequest{
-		BlogId: id,
-	})
-	if err != nil {
-		log.Printf("Error when reading blog: %v\n", err)
+// startTracing sets up tracing from the environment only, e.g.
+// BLOG_CLIENT_TRACING=file, the flags belong to the commands.
+func startTracing() error {
+	cfg := config.New("blog_client")
+	tracingCfg := tracing.AddSettings(cfg)
+	if err := cfg.Load(nil); err != nil {
+		return err
 	}
-	return res.GetBlog()
+	var err error
+	stopTracing, err = tracing.Start(context.Background(), "blog_client", tracingCfg)
+	return err
 }
 
-func sendUpdateBlogRequest(client blogpb.BlogServiceClient, blog *blogpb.Blog) *blogpb.Blog {
+// flushTraces exports the spans still buffered, if tracing was started.
+func flushTraces() {
+	if stopTracing == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := stopTracing(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to flush traces: %v\n", err)
+	}
+}
 
-	res, err := client.UpdateBlog(context.Background(), &blogpb.UpdateBlogRequest{
-		Blog: blog,
-	})
-	if err != nil {
-		log.Printf("Error when updating blog: %v\n", err)
+// parseArgs parses args with flags and returns the positional arguments.
+// Unlike flags.Parse it also takes flags after them, as in "get ID -o json".
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional
+		}
+		//"--" ends the flags, everything after it is positional
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...)
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
 	}
-	return res.GetBlog()
 }
 
-func sendDeleteBlogRequest(client blogpb.BlogServiceClient, blog_id string) string {
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: blog_client COMMAND [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8v %v\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun blog_client COMMAND -h for the flags of a command.\n")
+}
 
-	res, err := client.DeleteBlog(context.Background(), &blogpb.DeleteBlogRequest{
-		BlogId: blog_id,
-	})
-	if err != nil {
-		log.Printf("Error when updating blog: %v\n", err)
+// fail prints err, with the details of service errors, and exits.
+func fail(err error) {
+	if _, ok := status.FromError(err); ok {
+		fmt.Fprintln(os.Stderr, rpcerr.Decode(err))
+	} else {
+		fmt.Fprintln(os.Stderr, err)
 	}
-	return res.GetBlogId()
+	flushTraces()
+	os.Exit(1)
 }
 
-func sendListBlogRequest(client blogpb.BlogServiceClient) []blogpb.Blog {
+// connFlags say how to reach the blog server.
+type connFlags struct {
+	server string
+	tls    bool
+	ca     string
+	token  string
+}
 
-	var received_blogs []blogpb.Blog
+func addConnFlags(flags *flag.FlagSet) *connFlags {
+	c := &connFlags{}
+	flags.StringVar(&c.server, "server", "localhost:50051", "address of the blog server")
+	flags.BoolVar(&c.tls, "tls", false, "connect over TLS, checking the server certificate against the system roots")
+	flags.StringVar(&c.ca, "ca", "", "PEM file of the CA the server certificate is checked against, implies -tls")
+	//writes need a token when the server runs with -auth-key, see blog_token
+	flags.StringVar(&c.token, "token", os.Getenv("BLOG_TOKEN"), "bearer token sent with every call, defaults to $BLOG_TOKEN")
+	return c
+}
 
-	stream, err := client.ListBlog(context.Background(), &blogpb.ListBlogRequest{})
-	if err != nil {
-		log.Printf("Error when listing blogs: %v\n", err)
-	}
-	for {
-		msg, err := stream.Recv()
-		if err == io.EOF {
-			// reached end of stream
-			break
-		}
-		if err != nil {
-			log.Fatalf("Error while collecting results for listblog: %v\n", err)
+func (c *connFlags) dial() (*grpc.ClientConn, error) {
+	secure := c.tls || c.ca != ""
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if secure {
+		creds := credentials.NewTLS(&tls.Config{})
+		if c.ca != "" {
+			var err error
+			creds, err = credentials.NewClientTLSFromFile(c.ca, "")
+			if err != nil {
+				return nil, fmt.Errorf("loading -ca: %w", err)
+			}
 		}
-		received_blogs = append(received_blogs, *msg.GetBlog())
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	}
+	if c.token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(blogauth.NewTokenCredentials(c.token, secure)))
 	}
+	opts = append(opts, tracing.DialOptions()...)
+	return grpc.Dial(c.server, opts...)
+}
 
-	return received_blogs
+func demoCommand(flags *flag.FlagSet) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		runDemo(c.blogs, c.comments)
+		return nil
+	}
 }
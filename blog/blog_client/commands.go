@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"gopkg.in/yaml.v3"
+)
+
+// blogFlags are the flags giving the fields of a blog to create or update.
+type blogFlags struct {
+	file     string
+	author   string
+	title    string
+	content  string
+	tags     string
+	category string
+	slug     string
+}
+
+// blogFieldFlags maps the field flags to their update mask paths.
+var blogFieldFlags = map[string]string{
+	"author":   "author_id",
+	"title":    "title",
+	"content":  "content",
+	"tags":     "tags",
+	"category": "category",
+	"slug":     "slug",
+}
+
+func addBlogFlags(flags *flag.FlagSet) *blogFlags {
+	b := &blogFlags{}
+	flags.StringVar(&b.file, "f", "", "JSON or YAML file holding the blog, - for stdin, the flags below override its fields")
+	flags.StringVar(&b.author, "author", "", "author id")
+	flags.StringVar(&b.title, "title", "", "title")
+	flags.StringVar(&b.content, "content", "", "content, - to read it from stdin")
+	flags.StringVar(&b.tags, "tags", "", "comma separated tags")
+	flags.StringVar(&b.category, "category", "", "category")
+	flags.StringVar(&b.slug, "slug", "", "slug, derived from the title when left empty on create")
+	return b
+}
+
+// blog builds a blog from -f and the field flags. It also returns the update
+// mask paths of the field flags that were set.
+func (b *blogFlags) blog(flags *flag.FlagSet) (*blogpb.Blog, []string, error) {
+	if b.file == "-" && b.content == "-" {
+		return nil, nil, errors.New("-f and -content can't both read stdin")
+	}
+	blog := &blogpb.Blog{}
+	if b.file != "" {
+		var err error
+		if blog, err = readBlogFile(b.file); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var paths []string
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		path, ok := blogFieldFlags[f.Name]
+		if !ok {
+			return
+		}
+		paths = append(paths, path)
+		switch f.Name {
+		case "author":
+			blog.AuthorId = b.author
+		case "title":
+			blog.Title = b.title
+		case "content":
+			blog.Content = b.content
+			if b.content == "-" {
+				var data []byte
+				data, err = io.ReadAll(os.Stdin)
+				blog.Content = string(data)
+			}
+		case "tags":
+			blog.Tags = splitList(b.tags)
+		case "category":
+			blog.Category = b.category
+		case "slug":
+			blog.Slug = b.slug
+		}
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading content: %w", err)
+	}
+	return blog, paths, nil
+}
+
+// readBlogFile reads a blog in the JSON or YAML printed by get, - reads stdin.
+func readBlogFile(path string) (*blogpb.Blog, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	//JSON is YAML, so one decoder reads both before protojson takes over
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	blog := &blogpb.Blog{}
+	if err := protojson.Unmarshal(data, blog); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return blog, nil
+}
+
+// splitList splits a comma separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func createCommand(flags *flag.FlagSet) func(c *cli, args []string) error {
+	fields := addBlogFlags(flags)
+	return func(c *cli, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("create takes no arguments, got %q", args)
+		}
+		blog, _, err := fields.blog(flags)
+		if err != nil {
+			return err
+		}
+		//the server hands out ids, one in a file from get is ignored
+		blog.Id = ""
+		res, err := c.blogs.CreateBlog(context.Background(), &blogpb.CreateBlogRequest{Blog: blog})
+		if err != nil {
+			return err
+		}
+		return c.out.blog(res.GetBlog())
+	}
+}
+
+func getCommand(flags *flag.FlagSet) func(c *cli, args []string) error {
+	slug := flags.String("slug", "", "get the blog with this slug instead of an id")
+	deleted := flags.Bool("deleted", false, "also get the blog if it is in the trash")
+	return func(c *cli, args []string) error {
+		var blog *blogpb.Blog
+		switch {
+		case *slug != "" && len(args) == 0:
+			res, err := c.blogs.GetBlogBySlug(context.Background(), &blogpb.GetBlogBySlugRequest{Slug: *slug, ShowDeleted: *deleted})
+			if err != nil {
+				return err
+			}
+			blog = res.GetBlog()
+		case *slug == "" && len(args) == 1:
+			res, err := c.blogs.ReadBlog(context.Background(), &blogpb.ReadBlogRequest{BlogId: args[0], ShowDeleted: *deleted})
+			if err != nil {
+				return err
+			}
+			blog = res.GetBlog()
+		default:
+			return errors.New("get takes either one blog id or -slug")
+		}
+		return c.out.blog(blog)
+	}
+}
+
+func updateCommand(flags *flag.FlagSet) func(c *cli, args []string) error {
+	fields := addBlogFlags(flags)
+	version := flags.Int64("version", 0, "fail unless the blog is still at this version")
+	return func(c *cli, args []string) error {
+		if len(args) != 1 {
+			return errors.New("update takes one blog id")
+		}
+		blog, paths, err := fields.blog(flags)
+		if err != nil {
+			return err
+		}
+		if fields.file == "" && len(paths) == 0 {
+			return errors.New("nothing to update, give -f or the fields to change")
+		}
+		blog.Id = args[0]
+		req := &blogpb.UpdateBlogRequest{Blog: blog, ExpectedVersion: *version}
+		//a file replaces the whole blog, flags alone only change their fields
+		if fields.file == "" {
+			req.UpdateMask = &fieldmaskpb.FieldMask{Paths: paths}
+		}
+		res, err := c.blogs.UpdateBlog(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		return c.out.blog(res.GetBlog())
+	}
+}
+
+func deleteCommand(flags *flag.FlagSet) func(c *cli, args []string) error {
+	version := flags.Int64("version", 0, "fail unless the blog is still at this version, only with a single id")
+	return func(c *cli, args []string) error {
+		if len(args) == 0 {
+			return errors.New("delete takes the ids of the blogs to delete")
+		}
+		if *version != 0 && len(args) > 1 {
+			return errors.New("-version needs a single blog id")
+		}
+		for _, id := range args {
+			_, err := c.blogs.DeleteBlog(context.Background(), &blogpb.DeleteBlogRequest{BlogId: id, ExpectedVersion: *version})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Deleted %v\n", id)
+		}
+		return nil
+	}
+}
+
+func listCommand(flags *flag.FlagSet) func(c *cli, args []string) error {
+	author := flags.String("author", "", "only blogs of this author")
+	titlePrefix := flags.String("title-prefix", "", "only blogs whose title starts with this")
+	tags := flags.String("tags", "", "only blogs having all of these comma separated tags")
+	category := flags.String("category", "", "only blogs in this category")
+	order := flags.String("order", "created_asc", "sort order: created_asc, created_desc, title_asc or title_desc")
+	deleted := flags.String("deleted", "exclude", "blogs in the trash: exclude, include or only")
+	limit := flags.Int("limit", 0, "list at most this many blogs, 0 lists all of them")
+	return func(c *cli, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("list takes no arguments, got %q", args)
+		}
+		orderBy, ok := blogpb.SortOrder_value[strings.ToUpper(*order)]
+		if !ok {
+			return fmt.Errorf("unknown -order %q", *order)
+		}
+		deletedFilter, ok := blogpb.DeletedFilter_value[strings.ToUpper(*deleted)+"_DELETED"]
+		if !ok {
+			return fmt.Errorf("unknown -deleted %q", *deleted)
+		}
+		if *limit < 0 {
+			return errors.New("-limit can't be negative")
+		}
+		req := &blogpb.ListBlogRequest{
+			AuthorId:    *author,
+			TitlePrefix: *titlePrefix,
+			Tags:        splitList(*tags),
+			Category:    *category,
+			OrderBy:     blogpb.SortOrder(orderBy),
+			Deleted:     blogpb.DeletedFilter(deletedFilter),
+		}
+		var blogs []*blogpb.Blog
+		collect := func(blog *blogpb.Blog) error {
+			blogs = append(blogs, blog)
+			return nil
+		}
+		var err error
+		if *limit > 0 {
+			//a single page of the limit is exactly the blogs wanted
+			req.PageSize = int32(*limit)
+			err = listPage(c.blogs, req, collect)
+		} else {
+			err = listBlogs(c.blogs, req, collect)
+		}
+		if err != nil {
+			return err
+		}
+		return c.out.blogs(blogs)
+	}
+}
+
+func searchCommand(flags *flag.FlagSet) func(c *cli, args []string) error {
+	limit := flags.Int("limit", 20, "show at most this many matches, 0 shows all of them")
+	return func(c *cli, args []string) error {
+		if len(args) == 0 {
+			return errors.New("search takes the words to look for")
+		}
+		stream, err := c.blogs.SearchBlogs(context.Background(), &blogpb.SearchBlogsRequest{
+			Query: strings.Join(args, " "),
+			Limit: int32(*limit),
+		})
+		if err != nil {
+			return err
+		}
+		var results []*blogpb.SearchBlogsResponse
+		for {
+			msg, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			results = append(results, msg)
+		}
+		return c.out.searchResults(results)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// runDemo implements "blog_client demo": it runs through the calls of both
+// services once, printing what happens.
+func runDemo(client blogpb.BlogServiceClient, comment_client blogpb.CommentServiceClient) {
+	fmt.Println("Hello I'm a client")
+
+	fmt.Printf("created client: %f\n", client)
+
+	//print every change the calls below make while they happen
+	watch_ctx, stop_watching := context.WithCancel(context.Background())
+	watch_done := make(chan struct{})
+	go func() {
+		defer close(watch_done)
+		watchBlogs(watch_ctx, client)
+	}()
+	//give the watch a moment to start so it sees the first blog
+	time.Sleep(100 * time.Millisecond)
+
+	blog := &blogpb.Blog{
+		AuthorId: "Peter",
+		Title:    "My First Blog",
+		Content:  "Content of first blog",
+	}
+	response := sendCreateBlogRequest(client, blog)
+
+	//proof trying to read a blog that doesn't exist creates an error but doesn't break program
+	junk_blog := sendReadBlogRequest(client, "fake id")
+	fmt.Printf("The error for retrieving blog is: %v\n", junk_blog)
+
+	retrieved_blog := sendReadBlogRequest(client, response.Blog.Id)
+	fmt.Printf("The retrieved blog is: %v\n", retrieved_blog)
+
+	retrieved_blog.Content = "New content to show update works!"
+
+	//proof updating a blog that doesn't exist creates an error but doesn't break program
+	junk_blog = &blogpb.Blog{
+		Id:       "62406ccd33ece94df7aac7a8",
+		AuthorId: "not-a-real-author",
+		Title:    "not a real title",
+		Content:  "no real content",
+	}
+	junk_blog = sendUpdateBlogRequest(client, junk_blog)
+	fmt.Printf("The error for updating a blog is: %v\n", junk_blog)
+
+	updated_blog := sendUpdateBlogRequest(client, retrieved_blog)
+	fmt.Printf("The updated blog is: %v\n", updated_blog)
+
+	//proof updating from a stale copy of a blog is rejected instead of overwriting the newer version
+	stale_blog := sendUpdateBlogRequest(client, retrieved_blog)
+	fmt.Printf("The error for updating a stale blog is: %v\n", stale_blog)
+
+	comment := sendCreateCommentRequest(comment_client, &blogpb.Comment{
+		BlogId:   updated_blog.GetId(),
+		AuthorId: "Peter",
+		Content:  "First!",
+	})
+	reply := sendCreateCommentRequest(comment_client, &blogpb.Comment{
+		BlogId:   updated_blog.GetId(),
+		ParentId: comment.GetId(),
+		AuthorId: "Peter",
+		Content:  "Replying to myself",
+	})
+	fmt.Printf("The reply is: %v\n", reply)
+	for index, comment := range sendListCommentsRequest(comment_client, updated_blog.GetId(), "") {
+		fmt.Printf("The %v-th comment is: %v\n", index, comment)
+	}
+
+	//deleting the blog below takes its comments along
+	deleted_id := sendDeleteBlogRequest(client, junk_blog.GetId())
+	fmt.Printf("Just deleted blog with id: %v\n", deleted_id)
+
+	deleted_id = sendDeleteBlogRequest(client, updated_blog.GetId())
+	fmt.Printf("Just deleted blog with id: %v\n", deleted_id)
+
+	fmt.Printf("Getting blog list: \n")
+	blogs := sendListBlogRequest(client)
+	for index, blog := range blogs {
+		fmt.Printf("The %v-th blog is: %v\n", index, blog)
+	}
+
+	//one round trip each for many blogs
+	batch := sendBatchCreateBlogsRequest(client, []*blogpb.Blog{
+		{AuthorId: "Peter", Title: "Batch blog", Content: "First of the batch"},
+		{AuthorId: "Peter", Title: "Batch blog", Content: "Second of the batch"},
+		{AuthorId: "Peter", Title: "", Content: "Rejected, blogs need a title"},
+	})
+	var batch_ids []string
+	for index, result := range batch {
+		if result.GetError() != nil {
+			fmt.Printf("The %v-th batch blog failed: %v\n", index, rpcerr.Decode(status.ErrorProto(result.GetError())))
+			continue
+		}
+		batch_ids = append(batch_ids, result.GetBlogId())
+	}
+	fmt.Printf("Batch read: %v\n", sendBatchGetBlogsRequest(client, batch_ids))
+	fmt.Printf("Batch delete: %v\n", sendBatchDeleteBlogsRequest(client, batch_ids))
+
+	stop_watching()
+	<-watch_done
+}
+
+func sendCreateBlogRequest(client blogpb.BlogServiceClient, blog *blogpb.Blog) *blogpb.CreateBlogResponse {
+
+	fmt.Println("Creating create blog request...")
+	in := &blogpb.CreateBlogRequest{
+		Blog: blog,
+	}
+	fmt.Println("Sending create blog request")
+	createBlogResponse, err := client.CreateBlog(context.Background(), in)
+	if err != nil {
+		log.Fatalf("Error when creating blog: %v", rpcerr.Decode(err))
+	}
+	fmt.Printf("Create blog response received: %v\n", createBlogResponse)
+	return createBlogResponse
+}
+
+func sendReadBlogRequest(client blogpb.BlogServiceClient, id string) *blogpb.Blog {
+
+	res, err := client.ReadBlog(context.Background(), &blogpb.ReadBlogRequest{
+		BlogId: id,
+	})
+	if err != nil {
+		log.Printf("Error when reading blog: %v\n", rpcerr.Decode(err))
+	}
+	return res.GetBlog()
+}
+
+func sendUpdateBlogRequest(client blogpb.BlogServiceClient, blog *blogpb.Blog) *blogpb.Blog {
+
+	res, err := client.UpdateBlog(context.Background(), &blogpb.UpdateBlogRequest{
+		Blog:            blog,
+		ExpectedVersion: blog.GetVersion(),
+	})
+	if err != nil {
+		log.Printf("Error when updating blog: %v\n", rpcerr.Decode(err))
+	}
+	return res.GetBlog()
+}
+
+func sendDeleteBlogRequest(client blogpb.BlogServiceClient, blog_id string) string {
+
+	res, err := client.DeleteBlog(context.Background(), &blogpb.DeleteBlogRequest{
+		BlogId: blog_id,
+	})
+	if err != nil {
+		log.Printf("Error when deleting blog: %v\n", rpcerr.Decode(err))
+	}
+	return res.GetBlogId()
+}
+
+func sendListBlogRequest(client blogpb.BlogServiceClient) []*blogpb.Blog {
+
+	var received_blogs []*blogpb.Blog
+
+	req := &blogpb.ListBlogRequest{PageSize: 10}
+	for {
+		stream, err := client.ListBlog(context.Background(), req)
+		if err != nil {
+			log.Printf("Error when listing blogs: %v\n", rpcerr.Decode(err))
+			return received_blogs
+		}
+		next_page_token := ""
+		for {
+			msg, err := stream.Recv()
+			if err == io.EOF {
+				// reached end of stream
+				break
+			}
+			if err != nil {
+				log.Fatalf("Error while collecting results for listblog: %v\n", err)
+			}
+			received_blogs = append(received_blogs, msg.GetBlog())
+			next_page_token = msg.GetNextPageToken()
+		}
+		if next_page_token == "" {
+			// no more pages
+			return received_blogs
+		}
+		req.PageToken = next_page_token
+	}
+}
+
+func sendCreateCommentRequest(client blogpb.CommentServiceClient, comment *blogpb.Comment) *blogpb.Comment {
+
+	res, err := client.CreateComment(context.Background(), &blogpb.CreateCommentRequest{
+		Comment: comment,
+	})
+	if err != nil {
+		log.Printf("Error when creating comment: %v\n", rpcerr.Decode(err))
+	}
+	return res.GetComment()
+}
+
+func sendListCommentsRequest(client blogpb.CommentServiceClient, blog_id string, parent_id string) []*blogpb.Comment {
+
+	var received_comments []*blogpb.Comment
+
+	req := &blogpb.ListCommentsRequest{BlogId: blog_id, ParentId: parent_id, PageSize: 10}
+	for {
+		stream, err := client.ListComments(context.Background(), req)
+		if err != nil {
+			log.Printf("Error when listing comments: %v\n", rpcerr.Decode(err))
+			return received_comments
+		}
+		next_page_token := ""
+		for {
+			msg, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Printf("Error while collecting results for listcomments: %v\n", rpcerr.Decode(err))
+				return received_comments
+			}
+			received_comments = append(received_comments, msg.GetComment())
+			next_page_token = msg.GetNextPageToken()
+		}
+		if next_page_token == "" {
+			return received_comments
+		}
+		req.PageToken = next_page_token
+	}
+}
+
+// watchBlogs prints blog events until ctx ends. When the stream breaks it
+// reconnects with the resume token of the last event, so nothing is missed.
+func watchBlogs(ctx context.Context, client blogpb.BlogServiceClient) {
+
+	req := &blogpb.WatchBlogsRequest{}
+	for {
+		stream, err := client.WatchBlogs(ctx, req)
+		if err == nil {
+			for {
+				var msg *blogpb.WatchBlogsResponse
+				msg, err = stream.Recv()
+				if err != nil {
+					break
+				}
+				fmt.Printf("Blog event: %v %v\n", msg.GetType(), msg.GetBlog().GetId())
+				req.ResumeToken = msg.GetResumeToken()
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if status.Code(err) != codes.Unavailable {
+			log.Printf("Error while watching blogs: %v\n", rpcerr.Decode(err))
+			return
+		}
+		log.Printf("Lost the blog watch, resuming: %v\n", err)
+		time.Sleep(time.Second)
+	}
+}
+
+func sendBatchCreateBlogsRequest(client blogpb.BlogServiceClient, blogs []*blogpb.Blog) []*blogpb.BatchBlogResult {
+
+	stream, err := client.BatchCreateBlogs(context.Background())
+	if err != nil {
+		log.Fatalf("Error while opening batch create stream: %v", err)
+	}
+	for _, blog := range blogs {
+		if err := stream.Send(&blogpb.BatchCreateBlogsRequest{Blog: blog}); err != nil {
+			log.Fatalf("Error while sending blog to batch create: %v", err)
+		}
+	}
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		log.Printf("Error when creating blogs: %v\n", rpcerr.Decode(err))
+	}
+	fmt.Printf("Created %v blogs, %v failed\n", res.GetCreatedCount(), res.GetFailedCount())
+	return res.GetResults()
+}
+
+func sendBatchGetBlogsRequest(client blogpb.BlogServiceClient, blog_ids []string) []*blogpb.BatchBlogResult {
+
+	res, err := client.BatchGetBlogs(context.Background(), &blogpb.BatchGetBlogsRequest{
+		BlogIds: blog_ids,
+	})
+	if err != nil {
+		log.Printf("Error when reading blogs: %v\n", rpcerr.Decode(err))
+	}
+	return res.GetResults()
+}
+
+func sendBatchDeleteBlogsRequest(client blogpb.BlogServiceClient, blog_ids []string) []*blogpb.BatchBlogResult {
+
+	res, err := client.BatchDeleteBlogs(context.Background(), &blogpb.BatchDeleteBlogsRequest{
+		BlogIds: blog_ids,
+		Atomic:  true,
+	})
+	if err != nil {
+		log.Printf("Error when deleting blogs: %v\n", rpcerr.Decode(err))
+	}
+	return res.GetResults()
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats understood by export and import.
+const (
+	formatJSONL    = "jsonl"
+	formatMarkdown = "markdown"
+)
+
+// frontMatter is the YAML header of an exported Markdown file, the content
+// follows it.
+type frontMatter struct {
+	ID       string   `yaml:"id,omitempty"`
+	AuthorID string   `yaml:"author_id"`
+	Title    string   `yaml:"title"`
+	Slug     string   `yaml:"slug,omitempty"`
+	Category string   `yaml:"category,omitempty"`
+	Tags     []string `yaml:"tags,omitempty"`
+}
+
+const frontMatterFence = "---\n"
+
+// exportCommand implements "blog_client export": it streams every blog to a
+// JSON Lines file or to a directory of Markdown files.
+func exportCommand(flags *flag.FlagSet) func(c *cli, args []string) error {
+	format := flags.String("format", formatJSONL, "output format: jsonl or markdown")
+	out := flags.String("out", "-", "JSON Lines file, - for stdout, or the directory Markdown files are written to")
+	author := flags.String("author", "", "only export blogs of this author")
+	return func(c *cli, args []string) error {
+		return export(c.blogs, *format, *out, *author)
+	}
+}
+
+func export(client blogpb.BlogServiceClient, format, out, author string) error {
+
+	var write func(*blogpb.Blog) error
+	flush := func() error { return nil }
+	switch format {
+	case formatJSONL:
+		w := os.Stdout
+		if out != "-" {
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		buf := bufio.NewWriter(w)
+		flush = buf.Flush
+		write = func(blog *blogpb.Blog) error {
+			return writeJSONLine(buf, blog)
+		}
+	case formatMarkdown:
+		if out == "-" {
+			return errors.New("markdown export needs a directory in -out")
+		}
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return err
+		}
+		write = func(blog *blogpb.Blog) error {
+			return writeMarkdownFile(out, blog)
+		}
+	default:
+		return fmt.Errorf("unknown format %q, use jsonl or markdown", format)
+	}
+
+	count := 0
+	err := listBlogs(client, &blogpb.ListBlogRequest{AuthorId: author}, func(blog *blogpb.Blog) error {
+		count++
+		return write(blog)
+	})
+	if err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %v blogs\n", count)
+	return nil
+}
+
+// listBlogs calls fn for every blog matching req, following the page tokens.
+func listBlogs(client blogpb.BlogServiceClient, req *blogpb.ListBlogRequest, fn func(*blogpb.Blog) error) error {
+	req.PageSize = 100
+	for {
+		err := listPage(client, req, fn)
+		if err != nil || req.PageToken == "" {
+			return err
+		}
+	}
+}
+
+// listPage calls fn for the blogs of one page and sets req.PageToken to the
+// token of the next page, or to "" after the last one.
+func listPage(client blogpb.BlogServiceClient, req *blogpb.ListBlogRequest, fn func(*blogpb.Blog) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.ListBlog(ctx, req)
+	if err != nil {
+		return err
+	}
+	req.PageToken = ""
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(msg.GetBlog()); err != nil {
+			return err
+		}
+		req.PageToken = msg.GetNextPageToken()
+	}
+}
+
+func writeJSONLine(w io.Writer, blog *blogpb.Blog) error {
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(blog)
+	if err != nil {
+		return err
+	}
+	//protojson output may contain line breaks, a line must hold one blog
+	line := &bytes.Buffer{}
+	if err := json.Compact(line, data); err != nil {
+		return err
+	}
+	line.WriteByte('\n')
+	_, err = w.Write(line.Bytes())
+	return err
+}
+
+// writeMarkdownFile writes blog to dir, named after its slug, or its id for
+// blogs without one.
+func writeMarkdownFile(dir string, blog *blogpb.Blog) error {
+	name := blog.GetSlug()
+	if name == "" {
+		name = blog.GetId()
+	}
+	header, err := yaml.Marshal(frontMatter{
+		ID:       blog.GetId(),
+		AuthorID: blog.GetAuthorId(),
+		Title:    blog.GetTitle(),
+		Slug:     blog.GetSlug(),
+		Category: blog.GetCategory(),
+		Tags:     blog.GetTags(),
+	})
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	b.WriteString(frontMatterFence)
+	b.Write(header)
+	b.WriteString(frontMatterFence)
+	//written as is, so importing the file again changes nothing
+	b.WriteString(blog.GetContent())
+	return os.WriteFile(filepath.Join(dir, name+".md"), b.Bytes(), 0644)
+}
+
+// readMarkdownFile parses a file written by writeMarkdownFile.
+func readMarkdownFile(path string) (*blogpb.Blog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	if !strings.HasPrefix(text, frontMatterFence) {
+		return nil, fmt.Errorf("%v: missing front matter", path)
+	}
+	end := strings.Index(text[len(frontMatterFence):], "\n"+frontMatterFence)
+	if end < 0 {
+		return nil, fmt.Errorf("%v: front matter is not closed", path)
+	}
+	header := text[len(frontMatterFence) : len(frontMatterFence)+end+1]
+	content := text[len(frontMatterFence)+end+1+len(frontMatterFence):]
+
+	meta := frontMatter{}
+	if err := yaml.Unmarshal([]byte(header), &meta); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return &blogpb.Blog{
+		Id:       meta.ID,
+		AuthorId: meta.AuthorID,
+		Title:    meta.Title,
+		Content:  content,
+		Tags:     meta.Tags,
+		Category: meta.Category,
+		Slug:     meta.Slug,
+	}, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogservice"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient serves a blog service over an empty memory store and
+// returns a client connected to it.
+func newTestClient(t *testing.T) blogpb.BlogServiceClient {
+	t.Helper()
+	svc, err := blogservice.New(context.Background(), &blogservice.Config{
+		Store:          blogstore.Options{Backend: blogstore.BackendMemory},
+		TrashRetention: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("blogservice.New failed: %v", err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	svc.Register(s)
+	go s.Serve(lis)
+	t.Cleanup(func() {
+		s.Stop()
+		svc.Close(context.Background())
+	})
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return blogpb.NewBlogServiceClient(conn)
+}
+
+// allBlogs returns every blog client lists, by slug.
+func allBlogs(t *testing.T, client blogpb.BlogServiceClient) map[string]*blogpb.Blog {
+	t.Helper()
+	blogs := map[string]*blogpb.Blog{}
+	err := listBlogs(client, &blogpb.ListBlogRequest{}, func(blog *blogpb.Blog) error {
+		blogs[blog.GetSlug()] = blog
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("listing blogs failed: %v", err)
+	}
+	return blogs
+}
+
+func TestExportImport(t *testing.T) {
+	tests := []struct {
+		format string
+		// out is where the export goes, within a temporary directory.
+		out string
+	}{
+		{formatJSONL, "blogs.jsonl"},
+		{formatMarkdown, "blogs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			ctx := context.Background()
+			from := newTestClient(t)
+			for _, blog := range []*blogpb.Blog{
+				{AuthorId: "peter", Title: "First", Content: "one\n---\ntwo\n", Tags: []string{"go", "grpc"}, Category: "news"},
+				{AuthorId: "anna", Title: "Second", Content: "  indented\n\nlast line without a break"},
+				{AuthorId: "peter", Title: "Third", Content: "content", Slug: "custom"},
+			} {
+				if _, err := from.CreateBlog(ctx, &blogpb.CreateBlogRequest{Blog: blog}); err != nil {
+					t.Fatalf("CreateBlog failed: %v", err)
+				}
+			}
+			out := filepath.Join(t.TempDir(), tt.out)
+			if err := export(from, tt.format, out, ""); err != nil {
+				t.Fatalf("export failed: %v", err)
+			}
+
+			//a second server gets every blog, importing again changes nothing
+			to := newTestClient(t)
+			for i := 0; i < 2; i++ {
+				if err := importBlogs(to, tt.format, out, false); err != nil {
+					t.Fatalf("import %d failed: %v", i+1, err)
+				}
+			}
+			want, got := allBlogs(t, from), allBlogs(t, to)
+			if len(got) != len(want) {
+				t.Fatalf("imported blogs %v, want %v", got, want)
+			}
+			for slug, w := range want {
+				g := got[slug]
+				if g.GetAuthorId() != w.GetAuthorId() || g.GetTitle() != w.GetTitle() || g.GetContent() != w.GetContent() ||
+					g.GetCategory() != w.GetCategory() || !reflect.DeepEqual(g.GetTags(), w.GetTags()) {
+					t.Errorf("imported %v, want the fields of %v", g, w)
+				}
+				if g.GetVersion() != 1 {
+					t.Errorf("blog %v is at version %d after importing twice, want 1", slug, g.GetVersion())
+				}
+			}
+
+			//a dry run changes nothing either
+			if _, err := from.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: want["custom"].GetId()}); err != nil {
+				t.Fatalf("DeleteBlog failed: %v", err)
+			}
+			if err := importBlogs(from, tt.format, out, true); err != nil {
+				t.Fatalf("dry run failed: %v", err)
+			}
+			if got := allBlogs(t, from); len(got) != 2 {
+				t.Errorf("dry run left %d blogs, want 2", len(got))
+			}
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.jsonl")
+	if err := os.WriteFile(bad, []byte("{\"title\": \"Title\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.md"), []byte("no front matter"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t)
+	tests := []struct {
+		name   string
+		format string
+		in     string
+	}{
+		{"unknown format", "csv", bad},
+		{"missing file", formatJSONL, filepath.Join(dir, "missing.jsonl")},
+		{"malformed line", formatJSONL, bad},
+		{"malformed markdown", formatMarkdown, dir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := importBlogs(client, tt.format, tt.in, false); err == nil {
+				t.Error("import succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestMarkdownFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		blog *blogpb.Blog
+		file string
+	}{
+		{"slug", &blogpb.Blog{Id: "5f0000000000000000000001", AuthorId: "peter", Title: "Title", Content: "text\n", Slug: "title", Tags: []string{"go"}, Category: "news"}, "title.md"},
+		{"no slug", &blogpb.Blog{Id: "5f0000000000000000000002", AuthorId: "peter", Title: "Title", Content: "---\nno break at the end"}, "5f0000000000000000000002.md"},
+		{"empty content", &blogpb.Blog{Id: "5f0000000000000000000003", AuthorId: "peter", Title: "Title: with colon"}, "5f0000000000000000000003.md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeMarkdownFile(dir, tt.blog); err != nil {
+				t.Fatalf("writeMarkdownFile failed: %v", err)
+			}
+			got, err := readMarkdownFile(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatalf("readMarkdownFile failed: %v", err)
+			}
+			if got.GetId() != tt.blog.GetId() || got.GetTitle() != tt.blog.GetTitle() || got.GetContent() != tt.blog.GetContent() ||
+				got.GetSlug() != tt.blog.GetSlug() || got.GetCategory() != tt.blog.GetCategory() || !reflect.DeepEqual(got.GetTags(), tt.blog.GetTags()) {
+				t.Errorf("readMarkdownFile() = %v, want %v", got, tt.blog)
+			}
+		})
+	}
+}
+
+func TestSameBlog(t *testing.T) {
+	current := &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content", Tags: []string{"go"}, Slug: "title"}
+	tests := []struct {
+		name string
+		blog *blogpb.Blog
+		want bool
+	}{
+		{"same", &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content", Tags: []string{"go"}, Slug: "title"}, true},
+		{"no slug keeps the stored one", &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content", Tags: []string{"go"}}, true},
+		{"other slug", &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content", Tags: []string{"go"}, Slug: "other"}, false},
+		{"other content", &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "changed", Tags: []string{"go"}}, false},
+		{"other tag", &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content", Tags: []string{"grpc"}}, false},
+		{"no tags", &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameBlog(current, tt.blog); got != tt.want {
+				t.Errorf("sameBlog() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// importStats counts what an import did, or would do in a dry run.
+type importStats struct {
+	created, updated, unchanged, failed int
+}
+
+// importCommand implements "blog_client import": it upserts the blogs of a
+// JSON Lines file or a directory of Markdown files written by export. A blog
+// is matched by its id, then by its slug, so importing the same file twice
+// creates nothing the second time.
+func importCommand(flags *flag.FlagSet) func(c *cli, args []string) error {
+	format := flags.String("format", formatJSONL, "input format: jsonl or markdown")
+	in := flags.String("in", "-", "JSON Lines file, - for stdin, or the directory holding the Markdown files")
+	dryRun := flags.Bool("dry-run", false, "only print what would be created or updated")
+	return func(c *cli, args []string) error {
+		return importBlogs(c.blogs, *format, *in, *dryRun)
+	}
+}
+
+func importBlogs(client blogpb.BlogServiceClient, format, in string, dryRun bool) error {
+
+	stats := &importStats{}
+	upsert := func(source string, blog *blogpb.Blog) {
+		action, err := upsertBlog(client, blog, dryRun)
+		if err != nil {
+			stats.failed++
+			fmt.Fprintf(os.Stderr, "%v: %v\n", source, rpcerr.Decode(err))
+			return
+		}
+		switch action {
+		case "create":
+			stats.created++
+		case "update":
+			stats.updated++
+		default:
+			stats.unchanged++
+		}
+		if dryRun {
+			fmt.Printf("%v: would %v %q\n", source, action, blog.GetTitle())
+		}
+	}
+
+	switch format {
+	case formatJSONL:
+		r := os.Stdin
+		if in != "-" {
+			f, err := os.Open(in)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		scanner := bufio.NewScanner(r)
+		//blogs can hold up to 100000 characters of content
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			blog := &blogpb.Blog{}
+			if err := protojson.Unmarshal(scanner.Bytes(), blog); err != nil {
+				stats.failed++
+				fmt.Fprintf(os.Stderr, "%v:%d: %v\n", in, line, err)
+				continue
+			}
+			upsert(fmt.Sprintf("%v:%d", in, line), blog)
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	case formatMarkdown:
+		paths, err := filepath.Glob(filepath.Join(in, "*.md"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			blog, err := readMarkdownFile(path)
+			if err != nil {
+				stats.failed++
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			upsert(path, blog)
+		}
+	default:
+		return fmt.Errorf("unknown format %q, use jsonl or markdown", format)
+	}
+
+	verb := "Imported"
+	if dryRun {
+		verb = "Dry run"
+	}
+	fmt.Fprintf(os.Stderr, "%v: %v created, %v updated, %v unchanged, %v failed\n",
+		verb, stats.created, stats.updated, stats.unchanged, stats.failed)
+	if stats.failed > 0 {
+		return fmt.Errorf("%v blogs failed to import", stats.failed)
+	}
+	return nil
+}
+
+// upsertBlog creates blog, or updates the stored blog it matches, and
+// returns which of "create", "update" or "keep" it did.
+func upsertBlog(client blogpb.BlogServiceClient, blog *blogpb.Blog, dryRun bool) (string, error) {
+	current, err := findBlog(client, blog)
+	if err != nil {
+		return "", err
+	}
+	if current == nil {
+		if dryRun {
+			return "create", nil
+		}
+		//ids are given out by the server, the slug finds the blog next time
+		create := proto.Clone(blog).(*blogpb.Blog)
+		create.Id = ""
+		_, err := client.CreateBlog(context.Background(), &blogpb.CreateBlogRequest{Blog: create})
+		return "create", err
+	}
+	if current.GetDeletedAt() != nil {
+		return "", status.Errorf(codes.FailedPrecondition, "blog %v is in the trash, undelete it first", current.GetId())
+	}
+	if sameBlog(current, blog) {
+		return "keep", nil
+	}
+	if dryRun {
+		return "update", nil
+	}
+	update := proto.Clone(blog).(*blogpb.Blog)
+	update.Id = current.GetId()
+	_, err = client.UpdateBlog(context.Background(), &blogpb.UpdateBlogRequest{
+		Blog:            update,
+		ExpectedVersion: current.GetVersion(),
+	})
+	return "update", err
+}
+
+// findBlog returns the stored blog with the id of blog, or else with its
+// slug, or nil when there is none.
+func findBlog(client blogpb.BlogServiceClient, blog *blogpb.Blog) (*blogpb.Blog, error) {
+	if blog.GetId() != "" {
+		res, err := client.ReadBlog(context.Background(), &blogpb.ReadBlogRequest{BlogId: blog.GetId(), ShowDeleted: true})
+		if err == nil {
+			return res.GetBlog(), nil
+		}
+		//an id from another server may not even parse here
+		if code := status.Code(err); code != codes.NotFound && code != codes.InvalidArgument {
+			return nil, err
+		}
+	}
+	if blog.GetSlug() != "" {
+		res, err := client.GetBlogBySlug(context.Background(), &blogpb.GetBlogBySlugRequest{Slug: blog.GetSlug(), ShowDeleted: true})
+		if err == nil {
+			return res.GetBlog(), nil
+		}
+		if status.Code(err) != codes.NotFound {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// sameBlog tells whether importing blog over current would change anything.
+func sameBlog(current, blog *blogpb.Blog) bool {
+	if current.GetAuthorId() != blog.GetAuthorId() ||
+		current.GetTitle() != blog.GetTitle() ||
+		current.GetContent() != blog.GetContent() ||
+		current.GetCategory() != blog.GetCategory() ||
+		len(current.GetTags()) != len(blog.GetTags()) {
+		return false
+	}
+	//an empty slug keeps the stored one
+	if blog.GetSlug() != "" && current.GetSlug() != blog.GetSlug() {
+		return false
+	}
+	for i, tag := range current.GetTags() {
+		if blog.GetTags()[i] != tag {
+			return false
+		}
+	}
+	return true
+}
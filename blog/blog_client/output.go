@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats understood by -o.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// printer writes the results of a command in the format picked with -o.
+// JSON and YAML use the field names of the proto files, so the output of
+// get can be edited and passed back to create or update with -f.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "":
+		format = formatTable
+	case formatTable, formatJSON, formatYAML:
+	default:
+		return nil, fmt.Errorf("unknown output format %q, use table, json or yaml", format)
+	}
+	return &printer{w: w, format: format}, nil
+}
+
+// blog prints a single blog, with its content.
+func (p *printer) blog(blog *blogpb.Blog) error {
+	if p.format != formatTable {
+		return p.structured(false, blog)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%v\n", blog.GetId())
+	fmt.Fprintf(tw, "Author:\t%v\n", blog.GetAuthorId())
+	fmt.Fprintf(tw, "Title:\t%v\n", blog.GetTitle())
+	fmt.Fprintf(tw, "Slug:\t%v\n", blog.GetSlug())
+	fmt.Fprintf(tw, "Category:\t%v\n", blog.GetCategory())
+	fmt.Fprintf(tw, "Tags:\t%v\n", strings.Join(blog.GetTags(), ", "))
+	fmt.Fprintf(tw, "Version:\t%v\n", blog.GetVersion())
+	fmt.Fprintf(tw, "Created:\t%v\n", formatTime(blog.GetCreatedAt()))
+	fmt.Fprintf(tw, "Updated:\t%v by %v\n", formatTime(blog.GetUpdatedAt()), blog.GetLastModifiedBy())
+	if blog.GetDeletedAt() != nil {
+		fmt.Fprintf(tw, "Deleted:\t%v\n", formatTime(blog.GetDeletedAt()))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(p.w, "\n%v\n", strings.TrimRight(blog.GetContent(), "\n"))
+	return err
+}
+
+// blogs prints one line per blog, without the content.
+func (p *printer) blogs(blogs []*blogpb.Blog) error {
+	if p.format != formatTable {
+		msgs := make([]proto.Message, len(blogs))
+		for i, blog := range blogs {
+			msgs[i] = blog
+		}
+		return p.structured(true, msgs...)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tAUTHOR\tTITLE\tSLUG\tTAGS\tUPDATED")
+	for _, blog := range blogs {
+		updated := formatTime(blog.GetUpdatedAt())
+		if blog.GetDeletedAt() != nil {
+			updated += " (deleted)"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", blog.GetId(), cell(blog.GetAuthorId()), cell(blog.GetTitle()),
+			blog.GetSlug(), strings.Join(blog.GetTags(), ","), updated)
+	}
+	return tw.Flush()
+}
+
+// searchResults prints the matches of a search, best first.
+func (p *printer) searchResults(results []*blogpb.SearchBlogsResponse) error {
+	if p.format != formatTable {
+		msgs := make([]proto.Message, len(results))
+		for i, result := range results {
+			msgs[i] = result
+		}
+		return p.structured(true, msgs...)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCORE\tID\tTITLE\tMATCH")
+	for _, result := range results {
+		match := ""
+		if len(result.GetSnippets()) > 0 {
+			match = result.GetSnippets()[0]
+		}
+		fmt.Fprintf(tw, "%.2f\t%v\t%v\t%v\n", result.GetScore(), result.GetBlog().GetId(),
+			cell(stripHighlight(result.GetTitleHighlight())), cell(stripHighlight(match)))
+	}
+	return tw.Flush()
+}
+
+// structured prints msgs as JSON or YAML, as a list when list is set.
+func (p *printer) structured(list bool, msgs ...proto.Message) error {
+	marshal := protojson.MarshalOptions{UseProtoNames: true}
+	items := make([]json.RawMessage, len(msgs))
+	for i, msg := range msgs {
+		data, err := marshal.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		items[i] = data
+	}
+	var data []byte
+	var err error
+	if list {
+		data, err = json.Marshal(items)
+	} else {
+		data, err = json.Marshal(items[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	if p.format == formatJSON {
+		var out bytes.Buffer
+		if err := json.Indent(&out, data, "", "  "); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		_, err := p.w.Write(out.Bytes())
+		return err
+	}
+	//JSON is YAML, decoding it into a node keeps the field order
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	blockStyle(&doc)
+	enc := yaml.NewEncoder(p.w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle drops the JSON flow style and quoting decoding left on n, the
+// encoder then quotes only the strings that need it.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
+
+func formatTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().Local().Format("2006-01-02 15:04:05")
+}
+
+// cell keeps a value on one line of a table.
+func cell(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var highlightReplacer = strings.NewReplacer("<em>", "", "</em>", "")
+
+func stripHighlight(s string) string {
+	return highlightReplacer.Replace(s)
+}
@@ -2,265 +2,103 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"net"
 	"os"
-	"os/signal"
 	"time"
 
-	"gopkg.in/mgo.v2/bson"
-
-	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogservice"
+	"github.com/Peter-Yocum/grpc-go-course/config"
+	"github.com/Peter-Yocum/grpc-go-course/graceful"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/metrics"
+	"github.com/Peter-Yocum/grpc-go-course/recovery"
+	"github.com/Peter-Yocum/grpc-go-course/tracing"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
-	"google.golang.org/grpc/status"
 )
 
-var collection *mongo.Collection
-
-type server struct {
-	blogpb.BlogServiceServer
-}
-
-type blogItem struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty"`
-	AuthorID string             `bson:"author_id"`
-	Title    string             `bson:"title"`
-	Content  string             `bson:"content"`
-}
-
-func (*server) CreateBlog(ctx context.Context, req *blogpb.CreateBlogRequest) (*blogpb.CreateBlogResponse, error) {
-	blog := req.GetBlog()
-	fmt.Printf("Retrieved blog from request: %v\n", blog)
-	data := blogItem{
-		AuthorID: blog.GetAuthorId(),
-		Title:    blog.GetTitle(),
-		Content:  blog.GetContent(),
-	}
-
-	res, err := collection.InsertOne(context.Background(), data)
-	if err != nil {
-		return nil, status.Errorf(
-			codes.Internal,
-			fmt.Sprintf("Internal error: %v", err),
-		)
-	}
-	oid, ok := res.InsertedID.(primitive.ObjectID)
-	if !ok {
-		return nil, status.Errorf(
-			codes.Internal,
-			fmt.Sprintf("Cannot convert to OID: %v", err),
-		)
-	}
-
-	return &blogpb.CreateBlogResponse{
-		Blog: &blogpb.Blog{
-			Id:       oid.Hex(),
-			AuthorId: blog.GetAuthorId(),
-			Title:    blog.GetTitle(),
-			Content:  blog.GetContent(),
-		},
-	}, nil
-}
-
-func (*server) ReadBlog(ctx context.Context, req *blogpb.ReadBlogRequest) (*blogpb.ReadBlogResponse, error) {
-	blog_id := req.GetBlogId()
-	fmt.Printf("Retrieved blog_id from read blog request: %v\n", blog_id)
-
-	oid, err := primitive.ObjectIDFromHex(blog_id)
-	if err != nil {
-		return nil, status.Errorf(
-			codes.InvalidArgument,
-			fmt.Sprintf("Invalid blog_id sent: %v", err),
-		)
-	}
-
-	retrieved_data := &blogItem{}
-
-	filter := bson.M{"_id": oid}
-
-	res := collection.FindOne(context.Background(), filter)
-
-	if err := res.Decode(retrieved_data); err != nil {
-		return nil, status.Errorf(
-			codes.NotFound,
-			fmt.Sprintf("Cannot find blog with specified ID: %v", err),
-		)
-	}
-
-	return &blogpb.ReadBlogResponse{
-		Blog: dataToBlogPb(retrieved_data),
-	}, nil
-}
-
-func (*server) UpdateBlog(ctx context.Context, req *blogpb.UpdateBlogRequest) (*blogpb.UpdateBlogResponse, error) {
-	new_blog := req.GetBlog()
-	fmt.Printf("Retrieved blog_id from update blog request: %v\n", new_blog.Id)
-
-	oid, err := primitive.ObjectIDFromHex(new_blog.Id)
-	if err != nil {
-		return nil, status.Errorf(
-			codes.InvalidArgument,
-			fmt.Sprintf("Invalid blog_id sent: %v", err),
-		)
-	}
-
-	filter := bson.M{"_id": oid}
-	update := blogItem{
-		ID:       oid,
-		AuthorID: new_blog.GetAuthorId(),
-		Title:    new_blog.GetTitle(),
-		Content:  new_blog.GetContent(),
-	}
-	res, err := collection.ReplaceOne(context.Background(), filter, update)
-	if err != nil || res.MatchedCount != 1 {
-		return nil, status.Errorf(
-			codes.NotFound,
-			fmt.Sprintf("Cannot find blog with specified ID: %v", err),
-		)
-	}
-	if res.ModifiedCount != 1 {
-		return nil, status.Errorf(
-			codes.Internal,
-			fmt.Sprintf("Could not update blog with specified ID: %v", err),
-		)
-	}
-
-	return &blogpb.UpdateBlogResponse{
-		Blog: dataToBlogPb(&update),
-	}, nil
-}
-
-func (*server) DeleteBlog(ctx context.Context, req *blogpb.DeleteBlogRequest) (*blogpb.DeleteBlogResponse, error) {
-
-	fmt.Printf("Retrieved blog_id from delete blog request: %v\n", req.GetBlogId())
-
-	oid, err := primitive.ObjectIDFromHex(req.GetBlogId())
-	if err != nil {
-		return nil, status.Errorf(
-			codes.InvalidArgument,
-			fmt.Sprintf("Invalid blog_id sent: %v", err),
-		)
-	}
-
-	filter := bson.M{"_id": oid}
-
-	res, err := collection.DeleteOne(context.Background(), filter)
+func main() {
+	cfg := config.New("blog")
+	srv := cfg.Server("localhost:50051")
+	blogCfg := blogservice.AddSettings(cfg)
+	cfg.Check(blogCfg.Validate)
+	tracingCfg := tracing.AddSettings(cfg)
+	logCfg := logging.AddSettings(cfg)
+	if err := cfg.Load(os.Args[1:]); err != nil {
+		//there is no logger before the settings are loaded
+		log.Fatalln(err)
+	}
+	logger, err := logging.New(logCfg)
 	if err != nil {
-		return nil, status.Errorf(
-			codes.NotFound,
-			fmt.Sprintf("Error when trying to delete blog with specified ID: %v", err),
-		)
-	}
-	if res.DeletedCount == 0 {
-		return nil, status.Errorf(
-			codes.NotFound,
-			fmt.Sprintf("Could not find blog with specified ID: %v", err),
-		)
-	} else if res.DeletedCount != 1 {
-		return nil, status.Errorf(
-			codes.Internal,
-			fmt.Sprintf("Could not delete blog with specified ID: %v", err),
-		)
-	}
-
-	return &blogpb.DeleteBlogResponse{
-		BlogId: req.GetBlogId(),
-	}, nil
-}
-
-func dataToBlogPb(data *blogItem) *blogpb.Blog {
-	return &blogpb.Blog{
-		Id:       data.ID.Hex(),
-		AuthorId: data.AuthorID,
-		Title:    data.Title,
-		Content:  data.Content,
+		log.Fatalln(err)
 	}
-}
-
-func (*server) ListBlog(req *blogpb.ListBlogRequest, stream blogpb.BlogService_ListBlogServer) error {
-	fmt.Println("Inside list blog")
-
-	filter := bson.M{}
-	cursor, err := collection.Find(context.Background(), filter)
+	defer logger.Sync()
+	logger.Info("Blog Service started", zap.String("listen", srv.Listen))
+	stopTracing, err := tracing.Start(context.Background(), "blog_server", tracingCfg)
 	if err != nil {
-		return status.Errorf(
-			codes.Internal,
-			fmt.Sprintf("unexpected internal error when creating cursor: %v", err),
-		)
+		logger.Fatal("Failed to start tracing", zap.Error(err))
 	}
-	defer cursor.Close(context.Background())
-	for cursor.Next(context.Background()) {
-		data := &blogItem{}
-		err := cursor.Decode(data)
-		if err != nil {
-			return status.Errorf(
-				codes.Internal,
-				fmt.Sprintf("unexpected internal error when trying to decode blog from cursor: %v", err),
-			)
-		}
-		fmt.Printf("Just retrieved blog: %v from cursor\n", data.ID.String())
-		stream.SendMsg(&blogpb.ListBlogResponse{Blog: dataToBlogPb(data)})
-	}
-	if err := cursor.Err(); err != nil {
-		return status.Errorf(
-			codes.Internal,
-			fmt.Sprintf("unexpected internal error when finished reading from cursor: %v", err),
-		)
-	}
-	return nil
-}
 
-func main() {
-	//if we crash the go code we get the file name and line number
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	fmt.Println("Blog Service started on port 50051")
-
-	//connect to local mongodb
-	fmt.Println("Connecting to mongodb on port 27017")
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
+	blogs, err := blogservice.New(ctx, blogCfg)
 	if err != nil {
-		log.Fatalf("Failed to initiliaze mongodb: %v", err)
+		logger.Fatal("Failed to start the blog service", zap.Error(err))
 	}
 
-	collection = client.Database("blogdb").Collection("blog")
-
-	lis, err := net.Listen("tcp", "localhost:50051")
+	lis, err := net.Listen("tcp", srv.Listen)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v\n", err)
+		logger.Fatal("Failed to listen", zap.Error(err))
 	}
 
-	opts := []grpc.ServerOption{}
-	s := grpc.NewServer(opts...)
-	blogpb.RegisterBlogServiceServer(s, &server{})
+	opts, err := srv.ServerOptions()
+	if err != nil {
+		logger.Fatal("Failed to load the server options", zap.Error(err))
+	}
+	drain := graceful.New(srv.DrainTimeout)
+	drain.OnDrain(blogs.Drain)
+	opts = append(opts, tracing.ServerOptions()...)
+	opts = append(opts, logging.ServerOptions(logger)...)
+	opts = append(opts, metrics.ServerOptions()...)
+	opts = append(opts, recovery.ServerOptions()...)
+	opts = append(opts, drain.ServerOptions()...)
+	s := grpc.NewServer(append(opts, blogs.ServerOptions()...)...)
+	blogs.Register(s)
+	healthServer := health.NewServer()
+	//BlogService follows the blog store, NOT_SERVING while it is unreachable
+	blogs.StartHealthReports(healthServer)
+	healthpb.RegisterHealthServer(s, healthServer)
 	reflection.Register(s)
+	metrics.Register(s)
 
-	go func() {
-		fmt.Println("Starting Server...")
-
-		if err := s.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve: %v\n", err)
-		}
-	}()
-
-	//wait for control c to exit
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt)
-
-	//block until signal is received
-	<-ch
-	fmt.Println("\nStopping the server...")
-	s.Stop()
-	fmt.Println("Closing the listener...")
-	lis.Close()
-	fmt.Println("Closing mongodb connection...")
-	client.Disconnect(ctx)
-	fmt.Println("Ending the program.")
+	metricsServer, err := metrics.Serve(srv.MetricsListen)
+	if err != nil {
+		//the calls are served all the same, only without metrics
+		logger.Error("Failed to serve metrics", zap.Error(err))
+	} else if metricsServer != nil {
+		logger.Info("Serving metrics", zap.String("url", "http://"+srv.MetricsListen+"/metrics"))
+	}
+	logger.Info("Starting Server...")
+	if err := drain.Serve(s, lis, healthServer); err != nil {
+		logger.Fatal("Failed to serve", zap.Error(err))
+	}
+	metricsServer.Close()
+
+	//every handler has returned, nothing uses the store any more
+	logger.Info("Closing the blog store...")
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelClose()
+	if err := blogs.Close(closeCtx); err != nil {
+		logger.Error("Failed to close the blog store", zap.Error(err))
+	}
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := stopTracing(flushCtx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
+	}
+	logger.Info("Ending the program.")
 }
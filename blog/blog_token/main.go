@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogauth"
+)
+
+// blog_token issues bearer tokens for the blog server, signed with the
+// private key matching the server's -auth-key
+func main() {
+	keyPath := flag.String("key", "auth.key", "PEM PKCS #8 private key used to sign the token")
+	subject := flag.String("sub", "", "user id the token is issued to, used as author_id")
+	admin := flag.Bool("admin", false, "give the token the admin role")
+	ttl := flag.Duration("ttl", 24*time.Hour, "how long the token stays valid")
+	flag.Parse()
+	if *subject == "" {
+		log.Fatalf("-sub is required")
+	}
+
+	signer, err := blogauth.NewSignerFromFile(*keyPath)
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+	id := &blogauth.Identity{Subject: *subject}
+	if *admin {
+		id.Roles = append(id.Roles, blogauth.RoleAdmin)
+	}
+	token, err := signer.Sign(id, *ttl)
+	if err != nil {
+		log.Fatalf("Failed to sign token: %v", err)
+	}
+	fmt.Println(token)
+}
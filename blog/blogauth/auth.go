@@ -0,0 +1,179 @@
+// Package blogauth authenticates BlogService callers with JWT bearer tokens.
+//
+// Tokens are signed with a private key (Ed25519, ECDSA or RSA) and the server
+// only needs the matching public key to check them, so verification works
+// offline without calling any identity service.
+package blogauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// RoleAdmin lets a caller change and delete any blog, not just their own.
+const RoleAdmin = "admin"
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	// Subject is the caller's user id, used as the author_id of their blogs.
+	Subject string
+	Roles   []string
+}
+
+// IsAdmin reports whether the identity has the admin role.
+func (id *Identity) IsAdmin() bool {
+	for _, role := range id.Roles {
+		if role == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// claims is the JWT payload understood by the server.
+type claims struct {
+	Roles []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity of the caller, if the request carried a
+// valid token.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// Verifier checks tokens against a public key.
+type Verifier struct {
+	key    crypto.PublicKey
+	method jwt.SigningMethod
+}
+
+// NewVerifierFromFile loads a PEM encoded public key.
+func NewVerifierFromFile(path string) (*Verifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key %s: %w", path, err)
+	}
+	method, err := signingMethod(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{key: key, method: method}, nil
+}
+
+// Verify parses and validates a signed token and returns who it was issued to.
+// Tokens must expire, one without an exp claim would be valid forever.
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	parsed := &claims{}
+	_, err := jwt.ParseWithClaims(token, parsed, func(t *jwt.Token) (interface{}, error) {
+		// only accept the algorithm that matches our key
+		if t.Method.Alg() != v.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Method.Alg())
+		}
+		return v.key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if parsed.ExpiresAt == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	if parsed.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &Identity{Subject: parsed.Subject, Roles: parsed.Roles}, nil
+}
+
+// Signer issues tokens with a private key.
+type Signer struct {
+	key    crypto.PrivateKey
+	method jwt.SigningMethod
+}
+
+// NewSignerFromFile loads a PEM encoded PKCS #8 private key.
+func NewSignerFromFile(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	method, err := signingMethod(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{key: key, method: method}, nil
+}
+
+// Sign issues a token for id that expires after ttl.
+func (s *Signer) Sign(id *Identity, ttl time.Duration) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(s.method, &claims{
+		Roles: id.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   id.Subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+	return token.SignedString(s.key)
+}
+
+// signingMethod picks the JWT algorithm for a public key.
+func signingMethod(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return jwt.SigningMethodES256, nil
+		case 384:
+			return jwt.SigningMethodES384, nil
+		case 521:
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported ECDSA curve %v", k.Curve.Params().Name)
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
@@ -0,0 +1,83 @@
+package blogauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// newKeys returns a signer and the verifier of its tokens.
+func newKeys(t *testing.T) (*Signer, *Verifier) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Signer{key: private, method: jwt.SigningMethodEdDSA},
+		&Verifier{key: public, method: jwt.SigningMethodEdDSA}
+}
+
+// sign signs c with the key of s, bypassing Sign to build bad tokens.
+func sign(t *testing.T, s *Signer, c *claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(s.method, c).SignedString(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerify(t *testing.T) {
+	signer, verifier := newKeys(t)
+	other, _ := newKeys(t)
+	hour := time.Now().Add(time.Hour)
+
+	valid, err := signer.Sign(&Identity{Subject: "peter", Roles: []string{RoleAdmin}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject: "peter", ExpiresAt: jwt.NewNumericDate(hour),
+	}}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"expired", sign(t, signer, &claims{RegisteredClaims: jwt.RegisteredClaims{
+			Subject: "peter", ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		}}), true},
+		{"no expiry", sign(t, signer, &claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "peter"}}), true},
+		{"no subject", sign(t, signer, &claims{RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(hour)}}), true},
+		{"other key", sign(t, other, &claims{RegisteredClaims: jwt.RegisteredClaims{
+			Subject: "peter", ExpiresAt: jwt.NewNumericDate(hour),
+		}}), true},
+		{"other algorithm", hmac, true},
+		{"malformed", "not.a.token", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := verifier.Verify(tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Verify() = %+v, want an error", id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() failed: %v", err)
+			}
+			if id.Subject != "peter" || !id.IsAdmin() {
+				t.Errorf("Verify() = %+v, want peter with the admin role", id)
+			}
+		})
+	}
+}
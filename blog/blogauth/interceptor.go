@@ -0,0 +1,135 @@
+package blogauth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// ErrorDomain is the ErrorInfo domain of authentication errors.
+const ErrorDomain = "blogauth"
+
+// ErrorInfo reasons of authentication errors.
+const (
+	// ReasonTokenMissing means the method needs a bearer token and the
+	// request had none.
+	ReasonTokenMissing = "TOKEN_MISSING"
+	// ReasonTokenInvalid means the token was malformed, expired, without
+	// an expiry or not signed by the server's key.
+	ReasonTokenInvalid = "TOKEN_INVALID"
+)
+
+// Authenticator checks the bearer token of every request. Requests without a
+// token go through anonymously unless their method is listed as requiring
+// authentication, requests with a bad token are always rejected.
+type Authenticator struct {
+	verifier *Verifier
+	// required holds full method names, e.g. "/blog.BlogService/CreateBlog".
+	required map[string]bool
+}
+
+// NewAuthenticator returns an Authenticator that insists on a token for the
+// given methods.
+func NewAuthenticator(verifier *Verifier, requiredMethods ...string) *Authenticator {
+	required := make(map[string]bool, len(requiredMethods))
+	for _, method := range requiredMethods {
+		required[method] = true
+	}
+	return &Authenticator{verifier: verifier, required: required}
+}
+
+// UnaryServerInterceptor authenticates unary calls.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates streaming calls.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		if a.required[method] {
+			return nil, rpcerr.New(codes.Unauthenticated, ErrorDomain, ReasonTokenMissing,
+				fmt.Sprintf("%v requires a bearer token", method))
+		}
+		return ctx, nil
+	}
+	id, err := a.verifier.Verify(token)
+	if err != nil {
+		return nil, rpcerr.New(codes.Unauthenticated, ErrorDomain, ReasonTokenInvalid,
+			fmt.Sprintf("invalid bearer token: %v", err))
+	}
+	return NewContext(ctx, id), nil
+}
+
+// bearerToken returns the token from the authorization metadata, or "" when
+// there is none.
+func bearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", nil
+	}
+	const prefix = "bearer "
+	if len(values[0]) < len(prefix) || !strings.EqualFold(values[0][:len(prefix)], prefix) {
+		return "", rpcerr.New(codes.Unauthenticated, ErrorDomain, ReasonTokenInvalid,
+			"authorization metadata must be a bearer token")
+	}
+	return strings.TrimSpace(values[0][len(prefix):]), nil
+}
+
+// identityStream swaps the context of a server stream for one carrying the
+// caller identity.
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+// tokenCredentials attaches a bearer token to every call of a client.
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+// NewTokenCredentials returns per-RPC credentials sending token as a bearer
+// token. With requireTLS false the token is also sent over plaintext
+// connections, which is only acceptable for local development.
+func NewTokenCredentials(token string, requireTLS bool) credentials.PerRPCCredentials {
+	return &tokenCredentials{token: token, secure: requireTLS}
+}
+
+func (c *tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.token}, nil
+}
+
+func (c *tokenCredentials) RequireTransportSecurity() bool {
+	return c.secure
+}
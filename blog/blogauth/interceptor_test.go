@@ -0,0 +1,104 @@
+package blogauth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	requiredMethod = "/blog.BlogService/CreateBlog"
+	openMethod     = "/blog.BlogService/ReadBlog"
+)
+
+// fakeStream is a server stream with nothing but a context.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func TestInterceptors(t *testing.T) {
+	signer, verifier := newKeys(t)
+	other, _ := newKeys(t)
+	valid, err := signer.Sign(&Identity{Subject: "peter"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := other.Sign(&Identity{Subject: "peter"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		// wantSubject is the caller the handler sees, "" for none.
+		wantSubject string
+		wantReason  string
+	}{
+		{"anonymous read", openMethod, "", "", ""},
+		{"anonymous write", requiredMethod, "", "", ReasonTokenMissing},
+		{"authenticated read", openMethod, "Bearer " + valid, "peter", ""},
+		{"authenticated write", requiredMethod, "Bearer " + valid, "peter", ""},
+		{"lower case scheme", requiredMethod, "bearer " + valid, "peter", ""},
+		{"forged token", requiredMethod, "Bearer " + forged, "", ReasonTokenInvalid},
+		{"bad token on a read", openMethod, "Bearer " + forged, "", ReasonTokenInvalid},
+		{"other scheme", requiredMethod, "Basic cGV0ZXI6c2VjcmV0", "", ReasonTokenInvalid},
+		{"empty header", requiredMethod, "Bearer", "", ReasonTokenInvalid},
+	}
+	auth := NewAuthenticator(verifier, requiredMethod)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.authorization))
+			}
+			check := func(err error, subject string) {
+				t.Helper()
+				if tt.wantReason != "" {
+					if status.Code(err) != codes.Unauthenticated || !rpcerr.Is(err, ErrorDomain, tt.wantReason) {
+						t.Errorf("got %v, want Unauthenticated with reason %v", err, tt.wantReason)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("call failed: %v", err)
+				}
+				if subject != tt.wantSubject {
+					t.Errorf("handler saw caller %q, want %q", subject, tt.wantSubject)
+				}
+			}
+			subjectOf := func(ctx context.Context) string {
+				if id, ok := FromContext(ctx); ok {
+					return id.Subject
+				}
+				return ""
+			}
+
+			var subject string
+			_, err := auth.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					subject = subjectOf(ctx)
+					return nil, nil
+				})
+			check(err, subject)
+
+			subject = ""
+			err = auth.StreamServerInterceptor()(nil, &fakeStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: tt.method},
+				func(srv interface{}, ss grpc.ServerStream) error {
+					subject = subjectOf(ss.Context())
+					return nil
+				})
+			check(err, subject)
+		})
+	}
+}
@@ -1,4 +1,5 @@
 #!/bin/bash
 
-protoc blog/blogpb/blog.proto --go_out=. --go-grpc_out=.
+# google/rpc/status.proto comes from a checkout of github.com/googleapis/googleapis
+protoc -I. -I"${GOOGLEAPIS:?set GOOGLEAPIS to a googleapis checkout}" blog/blogpb/blog.proto blog/blogpb/comment.proto --go_out=. --go-grpc_out=.
 # python3 -m grpc_tools.protoc -I./blog/blogpb --python_out=./blog/blog_client --grpc_python_out=./blog/blog_client ./blog/blogpb/blog.proto 
@@ -0,0 +1,119 @@
+package blogservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogauth"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// caller returns a context authenticated as subject with the given roles.
+func caller(subject string, roles ...string) context.Context {
+	return blogauth.NewContext(context.Background(), &blogauth.Identity{Subject: subject, Roles: roles})
+}
+
+// createTestBlogAs is createTestBlog for an authenticated caller.
+func createTestBlogAs(t *testing.T, s *server, ctx context.Context, blog *blogpb.Blog) *blogpb.Blog {
+	t.Helper()
+	res, err := s.CreateBlog(ctx, &blogpb.CreateBlogRequest{Blog: blog})
+	if err != nil {
+		t.Fatalf("CreateBlog failed: %v", err)
+	}
+	return res.GetBlog()
+}
+
+func TestIsBlogMethod(t *testing.T) {
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{"/blog.BlogService/CreateBlog", true},
+		{"/blog.BlogService/ReadBlog", true},
+		{"/blog.CommentService/DeleteComment", true},
+		{"/greet.GreetService/Greet", false},
+		{"/blog.BlogService", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			if got := isBlogMethod(tt.method); got != tt.want {
+				t.Errorf("isBlogMethod(%q) = %v, want %v", tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateBlogTakesAuthorFromToken(t *testing.T) {
+	s := newTestServer(t)
+	s.authEnabled = true
+	res, err := s.CreateBlog(caller("peter"), &blogpb.CreateBlogRequest{Blog: &blogpb.Blog{AuthorId: "anna", Title: "Title", Content: "content"}})
+	if err != nil {
+		t.Fatalf("CreateBlog failed: %v", err)
+	}
+	if res.GetBlog().GetAuthorId() != "peter" || res.GetBlog().GetLastModifiedBy() != "peter" {
+		t.Errorf("CreateBlog() = %v, want a blog by peter", res.GetBlog())
+	}
+}
+
+func TestWriteAuthorization(t *testing.T) {
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		write func(ctx context.Context, s *server, id string) error
+		want  codes.Code
+	}{
+		{"author updates", caller("peter"), updateBlog("peter"), codes.OK},
+		{"other user updates", caller("anna"), updateBlog("peter"), codes.PermissionDenied},
+		{"admin updates", caller("anna", blogauth.RoleAdmin), updateBlog("peter"), codes.OK},
+		{"anonymous updates", context.Background(), updateBlog("peter"), codes.Unauthenticated},
+		{"author hands blog over", caller("peter"), updateBlog("anna"), codes.PermissionDenied},
+		{"admin hands blog over", caller("root", blogauth.RoleAdmin), updateBlog("anna"), codes.OK},
+		{"author patches", caller("peter"), patchBlog("title"), codes.OK},
+		{"other user patches", caller("anna"), patchBlog("title"), codes.PermissionDenied},
+		{"author patches author", caller("peter"), patchBlog("author_id"), codes.PermissionDenied},
+		{"author deletes", caller("peter"), deleteBlog, codes.OK},
+		{"other user deletes", caller("anna"), deleteBlog, codes.PermissionDenied},
+		{"other user restores", caller("anna"), restoreBlog, codes.PermissionDenied},
+		{"author restores", caller("peter"), restoreBlog, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.authEnabled = true
+			blog := createTestBlogAs(t, s, caller("peter"), &blogpb.Blog{Title: "Title", Content: "content"})
+			wantCode(t, tt.write(tt.ctx, s, blog.GetId()), tt.want)
+		})
+	}
+}
+
+// updateBlog replaces a blog, with author as its new author.
+func updateBlog(author string) func(ctx context.Context, s *server, id string) error {
+	return func(ctx context.Context, s *server, id string) error {
+		_, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: id, AuthorId: author, Title: "New", Content: "content"}})
+		return err
+	}
+}
+
+// patchBlog sets one field of a blog to anna.
+func patchBlog(field string) func(ctx context.Context, s *server, id string) error {
+	return func(ctx context.Context, s *server, id string) error {
+		_, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{
+			Blog:       &blogpb.Blog{Id: id, AuthorId: "anna", Title: "anna"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{field}},
+		})
+		return err
+	}
+}
+
+func deleteBlog(ctx context.Context, s *server, id string) error {
+	_, err := s.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: id})
+	return err
+}
+
+func restoreBlog(ctx context.Context, s *server, id string) error {
+	_, err := s.RestoreBlogRevision(ctx, &blogpb.RestoreBlogRevisionRequest{BlogId: id, Version: 1})
+	return err
+}
@@ -0,0 +1,240 @@
+package blogservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogauth"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchSize caps how many blogs a single batch call may carry.
+const maxBatchSize = 500
+
+func (s *server) BatchCreateBlogs(stream blogpb.BlogService_BatchCreateBlogsServer) error {
+	ctx := stream.Context()
+	var reqs []*blogpb.BatchCreateBlogsRequest
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(reqs) == maxBatchSize {
+			return badRequest(rpcerr.FieldViolation("blog", fmt.Sprintf("a batch can create at most %d blogs", maxBatchSize)))
+		}
+		reqs = append(reqs, req)
+	}
+	atomic := len(reqs) > 0 && reqs[0].GetAtomic()
+	logging.FromContext(stream.Context()).Debug("Creating a batch of blogs", zap.Int("blogs", len(reqs)), zap.Bool("atomic", atomic))
+
+	results := make([]*blogpb.BatchBlogResult, len(reqs))
+	var data []*blogstore.Blog
+	var positions []int // index in reqs of every entry of data
+	for i, req := range reqs {
+		create := &blogpb.CreateBlogRequest{Blog: req.GetBlog()}
+		if id, ok := blogauth.FromContext(ctx); ok && s.authEnabled && create.Blog != nil {
+			//the token says who is writing, whatever the request claims
+			create.Blog.AuthorId = id.Subject
+		}
+		if err := validateCreateBlog(create); err != nil {
+			if atomic {
+				return batchItemError(i, err)
+			}
+			results[i] = &blogpb.BatchBlogResult{Error: status.Convert(err).Proto()}
+			continue
+		}
+		data = append(data, newBlogData(ctx, create.Blog))
+		positions = append(positions, i)
+	}
+
+	//an empty batch, or one where every blog is invalid, writes nothing
+	if len(data) > 0 {
+		if err := s.createBatch(ctx, data, atomic, positions, results); err != nil {
+			return err
+		}
+	}
+
+	res := &blogpb.BatchCreateBlogsResponse{Results: results}
+	for _, result := range results {
+		if result.GetError() != nil {
+			res.FailedCount++
+		} else {
+			res.CreatedCount++
+		}
+	}
+	return stream.SendAndClose(res)
+}
+
+// createBatch creates the valid blogs of a batch, data, and writes their
+// results to results at their positions.
+func (s *server) createBatch(ctx context.Context, data []*blogstore.Blog, atomic bool, positions []int, results []*blogpb.BatchBlogResult) error {
+	derived, err := s.pickSlugs(ctx, data)
+	if err != nil {
+		return internalError(fmt.Errorf("picking slugs: %w", err))
+	}
+	created, err := s.store.CreateMany(ctx, data, atomic)
+	var batchErr *blogstore.BatchError
+	if errors.As(err, &batchErr) {
+		return batchItemError(positions[batchErr.Index], storeError(batchErr.Err, "blog.id", ""))
+	}
+	if err != nil {
+		return internalError(fmt.Errorf("creating blogs: %w", err))
+	}
+	for j, result := range created {
+		if errors.Is(result.Err, blogstore.ErrSlugTaken) && derived[j] {
+			//someone took the picked slug meanwhile, number it like CreateBlog
+			data[j].Slug = ""
+			result.Blog, result.Err = s.createBlog(ctx, data[j])
+		}
+		results[positions[j]] = batchResult(result, "blog.id", "")
+	}
+	return nil
+}
+
+func (s *server) BatchGetBlogs(ctx context.Context, req *blogpb.BatchGetBlogsRequest) (*blogpb.BatchGetBlogsResponse, error) {
+	ids := req.GetBlogIds()
+	logging.FromContext(ctx).Debug("Reading a batch of blogs", zap.Int("blogs", len(ids)))
+
+	if len(ids) > maxBatchSize {
+		return nil, badRequest(rpcerr.FieldViolation("blog_ids", fmt.Sprintf("a batch can read at most %d blogs, got %d", maxBatchSize, len(ids))))
+	}
+	read, err := s.store.ReadMany(ctx, ids)
+	if err != nil {
+		return nil, internalError(fmt.Errorf("reading blogs: %w", err))
+	}
+	res := &blogpb.BatchGetBlogsResponse{}
+	for i, result := range read {
+		if result.Err == nil && !result.Blog.DeletedAt.IsZero() && !req.GetShowDeleted() {
+			result = blogstore.BatchResult{Err: blogstore.ErrNotFound}
+		}
+		res.Results = append(res.Results, batchResult(result, fmt.Sprintf("blog_ids[%d]", i), ids[i]))
+	}
+	return res, nil
+}
+
+func (s *server) BatchDeleteBlogs(ctx context.Context, req *blogpb.BatchDeleteBlogsRequest) (*blogpb.BatchDeleteBlogsResponse, error) {
+	ids := req.GetBlogIds()
+	logging.FromContext(ctx).Debug("Deleting a batch of blogs", zap.Int("blogs", len(ids)), zap.Bool("atomic", req.GetAtomic()))
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	if len(ids) > maxBatchSize {
+		violations = append(violations, rpcerr.FieldViolation("blog_ids", fmt.Sprintf("a batch can delete at most %d blogs, got %d", maxBatchSize, len(ids))))
+	}
+	first := make(map[string]int, len(ids))
+	for i, id := range ids {
+		if j, ok := first[id]; ok {
+			violations = append(violations, rpcerr.FieldViolation(fmt.Sprintf("blog_ids[%d]", i), fmt.Sprintf("repeats blog_ids[%d]", j)))
+			continue
+		}
+		first[id] = i
+	}
+	if err := badRequest(violations...); err != nil {
+		return nil, err
+	}
+
+	results := make([]*blogpb.BatchBlogResult, len(ids))
+	allowed, positions, err := s.authorizeBatchDelete(ctx, ids, req.GetAtomic(), results)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.store.DeleteMany(ctx, allowed, blogstore.DeleteManyOptions{
+		Atomic:     req.GetAtomic(),
+		Soft:       s.softDelete,
+		ModifiedBy: callerIdentity(ctx),
+	})
+	var batchErr *blogstore.BatchError
+	if errors.As(err, &batchErr) {
+		i := positions[batchErr.Index]
+		return nil, batchItemError(i, storeError(batchErr.Err, fmt.Sprintf("blog_ids[%d]", i), ids[i]))
+	}
+	if err != nil {
+		return nil, internalError(fmt.Errorf("deleting blogs: %w", err))
+	}
+	for j, result := range deleted {
+		i := positions[j]
+		if !s.softDelete {
+			//the blog is gone, only the id is left to report
+			result.Blog = nil
+		}
+		results[i] = batchResult(result, fmt.Sprintf("blog_ids[%d]", i), ids[i])
+	}
+
+	res := &blogpb.BatchDeleteBlogsResponse{Results: results}
+	for _, result := range results {
+		if result.GetError() != nil {
+			res.FailedCount++
+		} else {
+			res.DeletedCount++
+		}
+	}
+	return res, nil
+}
+
+// authorizeBatchDelete returns the ids the caller may delete, with their
+// index in ids. Refusals are written to results, or fail an atomic batch.
+func (s *server) authorizeBatchDelete(ctx context.Context, ids []string, atomic bool, results []*blogpb.BatchBlogResult) ([]string, []int, error) {
+	positions := make([]int, len(ids))
+	for i := range ids {
+		positions[i] = i
+	}
+	if !s.authEnabled {
+		return ids, positions, nil
+	}
+	current, err := s.store.ReadMany(ctx, ids)
+	if err != nil {
+		return nil, nil, internalError(fmt.Errorf("reading blogs: %w", err))
+	}
+	var allowed []string
+	positions = positions[:0]
+	for i, result := range current {
+		//blogs that can't be read are left to the store to report
+		if result.Err == nil {
+			if err := s.checkAuthor(ctx, result.Blog.AuthorID); err != nil {
+				if atomic {
+					return nil, nil, batchItemError(i, err)
+				}
+				results[i] = &blogpb.BatchBlogResult{BlogId: ids[i], Error: status.Convert(err).Proto()}
+				continue
+			}
+		}
+		allowed = append(allowed, ids[i])
+		positions = append(positions, i)
+	}
+	return allowed, positions, nil
+}
+
+// batchResult converts the store's result for one blog of a batch. idField
+// and blogID describe the blog in the request, as for storeError.
+func batchResult(result blogstore.BatchResult, idField, blogID string) *blogpb.BatchBlogResult {
+	if result.Err != nil {
+		return &blogpb.BatchBlogResult{
+			BlogId: blogID,
+			Error:  status.Convert(storeError(result.Err, idField, blogID)).Proto(),
+		}
+	}
+	res := &blogpb.BatchBlogResult{BlogId: blogID}
+	if result.Blog != nil {
+		res.BlogId = result.Blog.ID
+		res.Blog = dataToBlogPb(result.Blog)
+	}
+	return res
+}
+
+// batchItemError fails a whole atomic batch with the error of the blog at
+// index i, keeping the error's code and details.
+func batchItemError(i int, err error) error {
+	st := status.Convert(err).Proto()
+	st.Message = fmt.Sprintf("Batch was not written, blog %d failed: %v", i, st.GetMessage())
+	return status.ErrorProto(st)
+}
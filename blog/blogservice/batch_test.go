@@ -0,0 +1,92 @@
+package blogservice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// batchCreateStream feeds reqs to BatchCreateBlogs and keeps its response.
+type batchCreateStream struct {
+	grpc.ServerStream
+	reqs []*blogpb.BatchCreateBlogsRequest
+	res  *blogpb.BatchCreateBlogsResponse
+}
+
+func (s *batchCreateStream) Context() context.Context { return context.Background() }
+
+func (s *batchCreateStream) Recv() (*blogpb.BatchCreateBlogsRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *batchCreateStream) SendAndClose(res *blogpb.BatchCreateBlogsResponse) error {
+	s.res = res
+	return nil
+}
+
+// noEmptyBatchStore refuses empty batches, like InsertMany does.
+type noEmptyBatchStore struct {
+	blogstore.BlogStore
+}
+
+func (s noEmptyBatchStore) CreateMany(ctx context.Context, blogs []*blogstore.Blog, atomic bool) ([]blogstore.BatchResult, error) {
+	if len(blogs) == 0 {
+		return nil, errors.New("must provide at least one element in input slice")
+	}
+	return s.BlogStore.CreateMany(ctx, blogs, atomic)
+}
+
+func TestBatchCreateBlogs(t *testing.T) {
+	valid := &blogpb.Blog{AuthorId: "peter", Title: "Valid", Content: "content"}
+	invalid := &blogpb.Blog{AuthorId: "peter", Title: "", Content: "content"}
+	tests := []struct {
+		name        string
+		blogs       []*blogpb.Blog
+		atomic      bool
+		wantCode    codes.Code
+		wantCreated int32
+		wantFailed  int32
+	}{
+		{name: "empty", wantCode: codes.OK},
+		{name: "all valid", blogs: []*blogpb.Blog{valid, valid}, wantCreated: 2},
+		{name: "some invalid", blogs: []*blogpb.Blog{valid, invalid}, wantCreated: 1, wantFailed: 1},
+		{name: "all invalid", blogs: []*blogpb.Blog{invalid, invalid}, wantFailed: 2},
+		{name: "atomic with an invalid blog", blogs: []*blogpb.Blog{valid, invalid}, atomic: true, wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.store = noEmptyBatchStore{s.store}
+			stream := &batchCreateStream{}
+			for _, blog := range tt.blogs {
+				stream.reqs = append(stream.reqs, &blogpb.BatchCreateBlogsRequest{Blog: proto.Clone(blog).(*blogpb.Blog), Atomic: tt.atomic})
+			}
+			err := s.BatchCreateBlogs(stream)
+			wantCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			if got := stream.res.GetCreatedCount(); got != tt.wantCreated {
+				t.Errorf("created %d blogs, want %d", got, tt.wantCreated)
+			}
+			if got := stream.res.GetFailedCount(); got != tt.wantFailed {
+				t.Errorf("failed %d blogs, want %d", got, tt.wantFailed)
+			}
+			if got, want := len(stream.res.GetResults()), len(tt.blogs); got != want {
+				t.Errorf("got %d results, want %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package blogservice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogauth"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// commentServer implements CommentService on top of the blog server, whose
+// store and authorization checks it shares.
+type commentServer struct {
+	blogpb.CommentServiceServer
+	blogs *server
+}
+
+func (s *commentServer) CreateComment(ctx context.Context, req *blogpb.CreateCommentRequest) (*blogpb.CreateCommentResponse, error) {
+	if id, ok := blogauth.FromContext(ctx); ok && s.blogs.authEnabled && req.GetComment() != nil {
+		//the token says who is writing, whatever the request claims
+		req.Comment.AuthorId = id.Subject
+	}
+	if err := validateCreateComment(req); err != nil {
+		return nil, err
+	}
+	comment := req.GetComment()
+	logging.FromContext(ctx).Debug("Creating comment", zap.String("blog_id", comment.GetBlogId()))
+
+	created, err := s.blogs.store.CreateComment(ctx, &blogstore.Comment{
+		BlogID:   comment.GetBlogId(),
+		ParentID: comment.GetParentId(),
+		AuthorID: comment.GetAuthorId(),
+		Content:  comment.GetContent(),
+	})
+	if err != nil {
+		return nil, commentStoreError(err, "comment.parent_id", comment.GetParentId(), "comment.blog_id", comment.GetBlogId())
+	}
+	return &blogpb.CreateCommentResponse{Comment: dataToCommentPb(created)}, nil
+}
+
+func (s *commentServer) ListComments(req *blogpb.ListCommentsRequest, stream blogpb.CommentService_ListCommentsServer) error {
+	logging.FromContext(stream.Context()).Debug("Inside list comments", zap.Stringer("request", req))
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	if req.GetBlogId() == "" {
+		violations = append(violations, rpcerr.FieldViolation("blog_id", "is required"))
+	}
+	if req.GetPageSize() < 0 {
+		violations = append(violations, rpcerr.FieldViolation("page_size", "cannot be negative"))
+	}
+	after, err := decodeCommentPageToken(req)
+	if err != nil {
+		violations = append(violations, rpcerr.FieldViolation("page_token", fmt.Sprintf("Invalid page_token sent: %v", err)))
+	}
+	if err := badRequest(violations...); err != nil {
+		return err
+	}
+	opts := blogstore.CommentListOptions{
+		BlogID:   req.GetBlogId(),
+		ParentID: req.GetParentId(),
+		After:    after,
+	}
+	if req.GetPageSize() > 0 {
+		//fetch one extra comment to know whether another page follows
+		opts.Limit = int(req.GetPageSize()) + 1
+	}
+
+	//same as ListBlog, each comment is sent once the next one is known
+	var pending *blogstore.Comment
+	sent := 0
+	err = s.blogs.store.ListComments(stream.Context(), opts, func(data *blogstore.Comment) error {
+		if pending != nil {
+			if err := stream.Send(&blogpb.ListCommentsResponse{
+				Comment:       dataToCommentPb(pending),
+				NextPageToken: encodeCommentPageToken(req, pending),
+			}); err != nil {
+				return err
+			}
+			sent++
+		}
+		pending = data
+		if opts.Limit > 0 && sent == int(req.GetPageSize()) {
+			pending = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return commentStoreError(err, "parent_id", req.GetParentId(), "blog_id", req.GetBlogId())
+	}
+	if pending != nil {
+		return stream.Send(&blogpb.ListCommentsResponse{Comment: dataToCommentPb(pending)})
+	}
+	return nil
+}
+
+func (s *commentServer) DeleteComment(ctx context.Context, req *blogpb.DeleteCommentRequest) (*blogpb.DeleteCommentResponse, error) {
+	logging.FromContext(ctx).Debug("Deleting comment", zap.String("comment_id", req.GetCommentId()))
+
+	if err := s.authorizeDelete(ctx, req.GetCommentId()); err != nil {
+		return nil, err
+	}
+	deleted, err := s.blogs.store.DeleteComment(ctx, req.GetCommentId())
+	if err != nil {
+		return nil, commentStoreError(err, "comment_id", req.GetCommentId(), "comment_id", "")
+	}
+	return &blogpb.DeleteCommentResponse{CommentId: req.GetCommentId(), DeletedCount: int64(deleted)}, nil
+}
+
+// authorizeDelete checks the caller may delete the comment: its author may,
+// and so may the author of the blog it was left on, and admins.
+func (s *commentServer) authorizeDelete(ctx context.Context, commentID string) error {
+	if !s.blogs.authEnabled {
+		return nil
+	}
+	comment, err := s.blogs.store.ReadComment(ctx, commentID)
+	if err != nil {
+		return commentStoreError(err, "comment_id", commentID, "comment_id", "")
+	}
+	if id, ok := blogauth.FromContext(ctx); ok && id.Subject == comment.AuthorID {
+		return nil
+	}
+	blog, err := s.blogs.store.Read(ctx, comment.BlogID)
+	if err != nil {
+		return storeError(err, "blog_id", comment.BlogID)
+	}
+	return s.blogs.checkAuthor(ctx, blog.AuthorID)
+}
+
+func dataToCommentPb(data *blogstore.Comment) *blogpb.Comment {
+	return &blogpb.Comment{
+		Id:         data.ID,
+		BlogId:     data.BlogID,
+		ParentId:   data.ParentID,
+		AuthorId:   data.AuthorID,
+		Content:    data.Content,
+		CreatedAt:  timestamppb.New(data.CreatedAt),
+		ReplyCount: data.ReplyCount,
+	}
+}
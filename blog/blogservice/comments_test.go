@@ -0,0 +1,162 @@
+package blogservice
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogauth"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// commentStream collects what ListComments sends.
+type commentStream struct {
+	grpc.ServerStream
+	sent []*blogpb.ListCommentsResponse
+}
+
+func (s *commentStream) Context() context.Context { return context.Background() }
+
+func (s *commentStream) Send(res *blogpb.ListCommentsResponse) error {
+	s.sent = append(s.sent, res)
+	return nil
+}
+
+// createTestComment creates a comment by anna through CreateComment.
+func createTestComment(t *testing.T, s *commentServer, ctx context.Context, blogID, parentID string) *blogpb.Comment {
+	t.Helper()
+	res, err := s.CreateComment(ctx, &blogpb.CreateCommentRequest{Comment: &blogpb.Comment{
+		BlogId: blogID, ParentId: parentID, AuthorId: "anna", Content: "comment",
+	}})
+	if err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+	return res.GetComment()
+}
+
+func TestComments(t *testing.T) {
+	ctx := context.Background()
+	blogs := newTestServer(t)
+	s := &commentServer{blogs: blogs}
+	blog := createTestBlog(t, blogs, &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content"})
+
+	var want []string
+	for i := 0; i < 3; i++ {
+		want = append(want, createTestComment(t, s, ctx, blog.GetId(), "").GetId())
+	}
+	reply := createTestComment(t, s, ctx, blog.GetId(), want[0])
+
+	//page through the top level comments two at a time
+	var got []string
+	req := &blogpb.ListCommentsRequest{BlogId: blog.GetId(), PageSize: 2}
+	for {
+		stream := &commentStream{}
+		if err := s.ListComments(req, stream); err != nil {
+			t.Fatalf("ListComments failed: %v", err)
+		}
+		next := ""
+		for _, res := range stream.sent {
+			got = append(got, res.GetComment().GetId())
+			next = res.GetNextPageToken()
+		}
+		if next == "" {
+			break
+		}
+		req.PageToken = next
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pages of comments = %v, want %v", got, want)
+	}
+
+	stream := &commentStream{}
+	if err := s.ListComments(&blogpb.ListCommentsRequest{BlogId: blog.GetId(), ParentId: want[0]}, stream); err != nil {
+		t.Fatalf("ListComments failed: %v", err)
+	}
+	if len(stream.sent) != 1 || stream.sent[0].GetComment().GetId() != reply.GetId() {
+		t.Errorf("replies = %v, want %s", stream.sent, reply.GetId())
+	}
+
+	res, err := s.DeleteComment(ctx, &blogpb.DeleteCommentRequest{CommentId: want[0]})
+	if err != nil {
+		t.Fatalf("DeleteComment failed: %v", err)
+	}
+	if res.GetDeletedCount() != 2 {
+		t.Errorf("DeleteComment() removed %d comments, want the comment and its reply", res.GetDeletedCount())
+	}
+}
+
+func TestCommentErrors(t *testing.T) {
+	ctx := context.Background()
+	blogs := newTestServer(t)
+	s := &commentServer{blogs: blogs}
+	blog := createTestBlog(t, blogs, &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content"})
+	const missing = "5f0000000000000000000000"
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"create on missing blog", func() error {
+			_, err := s.CreateComment(ctx, &blogpb.CreateCommentRequest{Comment: &blogpb.Comment{BlogId: missing, AuthorId: "anna", Content: "comment"}})
+			return err
+		}, codes.NotFound},
+		{"reply to missing comment", func() error {
+			_, err := s.CreateComment(ctx, &blogpb.CreateCommentRequest{Comment: &blogpb.Comment{BlogId: blog.GetId(), ParentId: missing, AuthorId: "anna", Content: "comment"}})
+			return err
+		}, codes.NotFound},
+		{"invalid comment", func() error {
+			_, err := s.CreateComment(ctx, &blogpb.CreateCommentRequest{Comment: &blogpb.Comment{BlogId: blog.GetId()}})
+			return err
+		}, codes.InvalidArgument},
+		{"list without blog", func() error {
+			return s.ListComments(&blogpb.ListCommentsRequest{}, &commentStream{})
+		}, codes.InvalidArgument},
+		{"list with bad token", func() error {
+			return s.ListComments(&blogpb.ListCommentsRequest{BlogId: blog.GetId(), PageToken: "bad"}, &commentStream{})
+		}, codes.InvalidArgument},
+		{"list missing blog", func() error {
+			return s.ListComments(&blogpb.ListCommentsRequest{BlogId: missing}, &commentStream{})
+		}, codes.NotFound},
+		{"delete missing", func() error {
+			_, err := s.DeleteComment(ctx, &blogpb.DeleteCommentRequest{CommentId: missing})
+			return err
+		}, codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantCode(t, tt.call(), tt.want)
+		})
+	}
+}
+
+func TestDeleteCommentAuthorization(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"comment author", caller("anna"), codes.OK},
+		{"blog author", caller("peter"), codes.OK},
+		{"admin", caller("root", blogauth.RoleAdmin), codes.OK},
+		{"someone else", caller("mallory"), codes.PermissionDenied},
+		{"anonymous", context.Background(), codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blogs := newTestServer(t)
+			blogs.authEnabled = true
+			s := &commentServer{blogs: blogs}
+			blog := createTestBlogAs(t, blogs, caller("peter"), &blogpb.Blog{Title: "Title", Content: "content"})
+			comment := createTestComment(t, s, caller("anna"), blog.GetId(), "")
+			if comment.GetAuthorId() != "anna" {
+				t.Fatalf("comment author = %q, want anna from the token", comment.GetAuthorId())
+			}
+
+			_, err := s.DeleteComment(tt.ctx, &blogpb.DeleteCommentRequest{CommentId: comment.GetId()})
+			wantCode(t, err, tt.want)
+		})
+	}
+}
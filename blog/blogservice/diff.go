@@ -0,0 +1,101 @@
+package blogservice
+
+import (
+	"strings"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+)
+
+// diffBlogs returns a line diff of every field that differs between two
+// versions of a blog.
+func diffBlogs(from, to *blogstore.Blog) []*blogpb.FieldDiff {
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"author_id", from.AuthorID, to.AuthorID},
+		{"title", from.Title, to.Title},
+		{"content", from.Content, to.Content},
+		{"tags", strings.Join(from.Tags, "\n"), strings.Join(to.Tags, "\n")},
+		{"category", from.Category, to.Category},
+		{"slug", from.Slug, to.Slug},
+	}
+
+	var diffs []*blogpb.FieldDiff
+	for _, field := range fields {
+		if field.from == field.to {
+			continue
+		}
+		diffs = append(diffs, &blogpb.FieldDiff{
+			Field: field.name,
+			Lines: diffLines(strings.Split(field.from, "\n"), strings.Split(field.to, "\n")),
+		})
+	}
+	return diffs
+}
+
+// diffLines computes the shortest edit script turning a into b with the Myers
+// algorithm. trace keeps the furthest reaching x of every diagonal k before
+// each round d, which is walked backwards to recover the edits.
+func diffLines(a, b []string) []*blogpb.DiffLine {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var lines []*blogpb.DiffLine
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			lines = append(lines, &blogpb.DiffLine{Op: blogpb.DiffLine_EQUAL, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			lines = append(lines, &blogpb.DiffLine{Op: blogpb.DiffLine_INSERT, Text: b[y-1]})
+		} else {
+			lines = append(lines, &blogpb.DiffLine{Op: blogpb.DiffLine_DELETE, Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+
+	// the walk above went from the end to the start
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
@@ -0,0 +1,90 @@
+package blogservice
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+)
+
+// formatDiff writes diff lines as " x", "+x" and "-x", joined by commas.
+func formatDiff(lines []*blogpb.DiffLine) string {
+	ops := map[blogpb.DiffLine_Op]string{
+		blogpb.DiffLine_EQUAL:  " ",
+		blogpb.DiffLine_INSERT: "+",
+		blogpb.DiffLine_DELETE: "-",
+	}
+	formatted := make([]string, len(lines))
+	for i, line := range lines {
+		formatted[i] = ops[line.GetOp()] + line.GetText()
+	}
+	return strings.Join(formatted, ",")
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a b c", "a b c", " a, b, c"},
+		{"insert", "a c", "a b c", " a,+b, c"},
+		{"delete", "a b c", "a c", " a,-b, c"},
+		{"replace", "a b c", "a x c", " a,-b,+x, c"},
+		{"append", "a", "a b", " a,+b"},
+		{"from nothing", "", "a b", "+a,+b"},
+		{"to nothing", "a b", "", "-a,-b"},
+		{"both empty", "", "", ""},
+		{"shortest script", "a b c a b b a", "c b a b a c", "-a,-b, c,+b, a, b,-b, a,+c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := diffLines(strings.Fields(tt.a), strings.Fields(tt.b))
+			if got := formatDiff(lines); got != tt.want {
+				t.Errorf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+
+			//whatever script comes out, it must turn a into b
+			var from, to []string
+			for _, line := range lines {
+				if line.GetOp() != blogpb.DiffLine_INSERT {
+					from = append(from, line.GetText())
+				}
+				if line.GetOp() != blogpb.DiffLine_DELETE {
+					to = append(to, line.GetText())
+				}
+			}
+			if strings.Join(from, " ") != tt.a || strings.Join(to, " ") != tt.b {
+				t.Errorf("diffLines(%q, %q) turns %q into %q", tt.a, tt.b, strings.Join(from, " "), strings.Join(to, " "))
+			}
+		})
+	}
+}
+
+func TestDiffBlogs(t *testing.T) {
+	from := &blogstore.Blog{AuthorID: "peter", Title: "Title", Content: "one\ntwo", Tags: []string{"go"}, Slug: "title"}
+	to := &blogstore.Blog{AuthorID: "peter", Title: "New title", Content: "one\nthree", Tags: []string{"go", "grpc"}, Slug: "title"}
+
+	diffs := diffBlogs(from, to)
+	got := map[string]string{}
+	for _, diff := range diffs {
+		got[diff.GetField()] = formatDiff(diff.GetLines())
+	}
+	want := map[string]string{
+		"title":   "-Title,+New title",
+		"content": " one,-two,+three",
+		"tags":    " go,+grpc",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("diffBlogs() changed %v, want %v", got, want)
+	}
+	for field, lines := range want {
+		if got[field] != lines {
+			t.Errorf("diff of %s = %q, want %q", field, got[field], lines)
+		}
+	}
+	if diffs := diffBlogs(from, from); len(diffs) != 0 {
+		t.Errorf("diffBlogs() of a blog with itself = %v, want nothing", diffs)
+	}
+}
@@ -0,0 +1,96 @@
+package blogservice
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the ErrorInfo domain of every error BlogService returns.
+const errorDomain = "blog.BlogService"
+
+// ErrorInfo reasons returned by BlogService. Clients switch on these, so
+// they must never change once released.
+const (
+	reasonInvalidRequest     = "INVALID_REQUEST"
+	reasonInvalidBlogID      = "INVALID_BLOG_ID"
+	reasonBlogNotFound       = "BLOG_NOT_FOUND"
+	reasonRevisionNotFound   = "REVISION_NOT_FOUND"
+	reasonBlogNotDeleted     = "BLOG_NOT_DELETED"
+	reasonSlugTaken          = "SLUG_TAKEN"
+	reasonCommentNotFound    = "COMMENT_NOT_FOUND"
+	reasonInvalidResumeToken = "INVALID_RESUME_TOKEN"
+	reasonResumeTokenExpired = "RESUME_TOKEN_EXPIRED"
+	reasonShuttingDown       = "SHUTTING_DOWN"
+	reasonVersionMismatch    = "VERSION_MISMATCH"
+	reasonPermissionDenied   = "PERMISSION_DENIED"
+	reasonInternal           = "INTERNAL"
+)
+
+// ResourceInfo types of the things BlogService looks up.
+const (
+	blogResource     = "blog.Blog"
+	revisionResource = "blog.Blog/revisions"
+	slugResource     = "blog.Blog/slugs"
+	commentResource  = "blog.Comment"
+)
+
+// storeError converts an error from the blog store into a grpc status about
+// the blog with the given id. idField is where the id was sent in the
+// request, e.g. "blog_id", so an invalid id can be pinned on it.
+func storeError(err error, idField, blogID string) error {
+	if _, ok := status.FromError(err); ok {
+		//already a grpc status, e.g. from an authorization check
+		return err
+	}
+	var taken *blogstore.SlugTakenError
+	switch {
+	case errors.As(err, &taken):
+		return rpcerr.Resource(codes.AlreadyExists, errorDomain, reasonSlugTaken, slugResource, taken.Slug,
+			fmt.Sprintf("Slug is already used by another blog: %v", taken.Slug))
+	case errors.Is(err, blogstore.ErrInvalidID):
+		return rpcerr.BadRequest(errorDomain, reasonInvalidBlogID,
+			rpcerr.FieldViolation(idField, err.Error()))
+	case errors.Is(err, blogstore.ErrNotFound):
+		return rpcerr.NotFound(errorDomain, reasonBlogNotFound, blogResource, blogID,
+			fmt.Sprintf("Cannot find blog with specified %v: %v", idField, blogID))
+	case errors.Is(err, blogstore.ErrRevisionNotFound):
+		return rpcerr.NotFound(errorDomain, reasonRevisionNotFound, revisionResource, blogID,
+			fmt.Sprintf("Cannot find blog revision with specified version: %v", err))
+	case errors.Is(err, blogstore.ErrNotDeleted):
+		return rpcerr.Resource(codes.FailedPrecondition, errorDomain, reasonBlogNotDeleted, blogResource, blogID,
+			fmt.Sprintf("Blog is not in the trash: %v", err))
+	case errors.Is(err, blogstore.ErrVersionMismatch):
+		return rpcerr.Resource(codes.Aborted, errorDomain, reasonVersionMismatch, blogResource, blogID,
+			fmt.Sprintf("Blog was modified by someone else, read it again and retry: %v", err))
+	default:
+		return internalError(err)
+	}
+}
+
+// commentStoreError converts an error from the comment store into a grpc
+// status. commentField and commentID name the comment the request was
+// about, blogField and blogID its blog, as for storeError.
+func commentStoreError(err error, commentField, commentID, blogField, blogID string) error {
+	if errors.Is(err, blogstore.ErrCommentNotFound) {
+		return rpcerr.NotFound(errorDomain, reasonCommentNotFound, commentResource, commentID,
+			fmt.Sprintf("Cannot find comment with specified %v: %v", commentField, commentID))
+	}
+	return storeError(err, blogField, blogID)
+}
+
+// internalError reports a failure the client can do nothing about.
+func internalError(err error) error {
+	return rpcerr.New(codes.Internal, errorDomain, reasonInternal, fmt.Sprintf("Internal error: %v", err))
+}
+
+// badRequest returns an InvalidArgument error for the violations, or nil
+// when there are none.
+func badRequest(violations ...*errdetails.BadRequest_FieldViolation) error {
+	return rpcerr.BadRequest(errorDomain, reasonInvalidRequest, violations...)
+}
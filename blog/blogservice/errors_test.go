@@ -0,0 +1,83 @@
+package blogservice
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStoreError(t *testing.T) {
+	const id = "5f0000000000000000000000"
+	tests := []struct {
+		name   string
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{"invalid id", blogstore.ErrInvalidID, codes.InvalidArgument, reasonInvalidBlogID},
+		{"not found", fmt.Errorf("reading blog: %w", blogstore.ErrNotFound), codes.NotFound, reasonBlogNotFound},
+		{"revision not found", blogstore.ErrRevisionNotFound, codes.NotFound, reasonRevisionNotFound},
+		{"not deleted", blogstore.ErrNotDeleted, codes.FailedPrecondition, reasonBlogNotDeleted},
+		{"version mismatch", blogstore.ErrVersionMismatch, codes.Aborted, reasonVersionMismatch},
+		{"slug taken", &blogstore.SlugTakenError{Slug: "hello"}, codes.AlreadyExists, reasonSlugTaken},
+		{"unexpected", errors.New("disk full"), codes.Internal, reasonInternal},
+		//statuses pass through as they are
+		{"status", status.Error(codes.PermissionDenied, "no"), codes.PermissionDenied, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storeError(tt.err, "blog_id", id)
+			if status.Code(err) != tt.code || rpcerr.Reason(err) != tt.reason {
+				t.Fatalf("storeError() = %v, want %v with reason %q", rpcerr.Decode(err), tt.code, tt.reason)
+			}
+			if tt.reason != "" && !rpcerr.Is(err, errorDomain, tt.reason) {
+				t.Errorf("storeError() = %v, want the %v domain", rpcerr.Decode(err), errorDomain)
+			}
+		})
+	}
+
+	//an invalid id is pinned on the field it was sent in
+	err := storeError(blogstore.ErrInvalidID, "blog.id", "bad")
+	if _, ok := rpcerr.FieldViolations(err)["blog.id"]; !ok {
+		t.Errorf("storeError() = %v, want a violation of blog.id", rpcerr.Decode(err))
+	}
+	err = storeError(blogstore.ErrNotFound, "blog_id", id)
+	if got := rpcerr.Decode(err).ResourceInfo; got.GetResourceType() != blogResource || got.GetResourceName() != id {
+		t.Errorf("resource of storeError() = %v, want blog %s", got, id)
+	}
+}
+
+func TestCommentStoreError(t *testing.T) {
+	const blogID, commentID = "5f0000000000000000000000", "5f0000000000000000000001"
+	tests := []struct {
+		name     string
+		err      error
+		code     codes.Code
+		reason   string
+		resource string
+	}{
+		{"comment not found", blogstore.ErrCommentNotFound, codes.NotFound, reasonCommentNotFound, commentID},
+		//the blog of the comment is reported under its own field
+		{"blog not found", blogstore.ErrNotFound, codes.NotFound, reasonBlogNotFound, blogID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := commentStoreError(tt.err, "comment_id", commentID, "blog_id", blogID)
+			if status.Code(err) != tt.code || !rpcerr.Is(err, errorDomain, tt.reason) {
+				t.Fatalf("commentStoreError() = %v, want %v with reason %q", rpcerr.Decode(err), tt.code, tt.reason)
+			}
+			if got := rpcerr.Decode(err).ResourceInfo.GetResourceName(); got != tt.resource {
+				t.Errorf("resource of commentStoreError() = %q, want %q", got, tt.resource)
+			}
+		})
+	}
+	err := commentStoreError(blogstore.ErrInvalidID, "comment_id", commentID, "comment.blog_id", "bad")
+	if _, ok := rpcerr.FieldViolations(err)["comment.blog_id"]; !ok {
+		t.Errorf("commentStoreError() = %v, want a violation of comment.blog_id", rpcerr.Decode(err))
+	}
+}
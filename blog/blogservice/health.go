@@ -0,0 +1,67 @@
+package blogservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Health checks ping the store every healthInterval and give up on a ping
+// after healthTimeout.
+const (
+	healthInterval = 10 * time.Second
+	healthTimeout  = 5 * time.Second
+)
+
+// StartHealthReports keeps the status of BlogService and CommentService in
+// hs in line with the store: NOT_SERVING while it can't be reached, SERVING
+// otherwise. The store is pinged once before it returns, then in the
+// background until Close.
+func (svc *Service) StartHealthReports(hs *health.Server) {
+	ctx, stop := context.WithCancel(context.Background())
+	svc.stopHealth = stop
+	last := svc.reportHealth(ctx, hs, healthpb.HealthCheckResponse_UNKNOWN)
+	go func() {
+		ticker := time.NewTicker(healthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			last = svc.reportHealth(ctx, hs, last)
+		}
+	}()
+}
+
+// reportHealth pings the store and sets the status of the blog services in
+// hs when it differs from the last one, which it returns.
+func (svc *Service) reportHealth(ctx context.Context, hs *health.Server, last healthpb.HealthCheckResponse_ServingStatus) healthpb.HealthCheckResponse_ServingStatus {
+	pingCtx, cancel := context.WithTimeout(ctx, healthTimeout)
+	err := svc.store.Ping(pingCtx)
+	cancel()
+	if ctx.Err() != nil {
+		return last
+	}
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if err != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if status == last {
+		return last
+	}
+	if err != nil {
+		zap.L().Error("Blog store is unreachable, BlogService is NOT_SERVING", zap.Error(err))
+	} else if last != healthpb.HealthCheckResponse_UNKNOWN {
+		zap.L().Info("Blog store is reachable again, BlogService is SERVING")
+	}
+	hs.SetServingStatus(blogpb.BlogService_ServiceDesc.ServiceName, status)
+	hs.SetServingStatus(blogpb.CommentService_ServiceDesc.ServiceName, status)
+	return status
+}
@@ -0,0 +1,62 @@
+package blogservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// pingStore is a store whose Ping fails while down is set.
+type pingStore struct {
+	blogstore.BlogStore
+	down bool
+}
+
+func (s *pingStore) Ping(ctx context.Context) error {
+	if s.down {
+		return errors.New("store unreachable")
+	}
+	return s.BlogStore.Ping(ctx)
+}
+
+func TestReportHealth(t *testing.T) {
+	store := &pingStore{BlogStore: blogstore.NewMemoryStore()}
+	svc := &Service{store: store}
+	hs := health.NewServer()
+	last := healthpb.HealthCheckResponse_UNKNOWN
+
+	for _, step := range []struct {
+		down bool
+		want healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{false, healthpb.HealthCheckResponse_SERVING},
+		{true, healthpb.HealthCheckResponse_NOT_SERVING},
+		{true, healthpb.HealthCheckResponse_NOT_SERVING},
+		{false, healthpb.HealthCheckResponse_SERVING},
+	} {
+		store.down = step.down
+		last = svc.reportHealth(context.Background(), hs, last)
+		if last != step.want {
+			t.Errorf("reportHealth() with the store down %v = %v, want %v", step.down, last, step.want)
+		}
+		for _, service := range []string{blogpb.BlogService_ServiceDesc.ServiceName, blogpb.CommentService_ServiceDesc.ServiceName} {
+			res, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err != nil || res.GetStatus() != step.want {
+				t.Errorf("status of %v = %v, %v, want %v", service, res.GetStatus(), err, step.want)
+			}
+		}
+	}
+
+	//a ping cut short by Close leaves the status alone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store.down = true
+	if got := svc.reportHealth(ctx, hs, last); got != last {
+		t.Errorf("reportHealth() after Close = %v, want %v kept", got, last)
+	}
+}
@@ -0,0 +1,107 @@
+package blogservice
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"google.golang.org/protobuf/proto"
+)
+
+// pageToken is the decoded form of the next_page_token of ListBlogResponse
+// and ListCommentsResponse. It remembers the query it belongs to so a token
+// can't be replayed against a different filter or sort order.
+type pageToken struct {
+	Query     string `json:"q"`
+	LastID    string `json:"i"`
+	LastTitle string `json:"t,omitempty"`
+}
+
+// queryFingerprint hashes every field of the request except the paging ones,
+// so any change to the filters or the order invalidates old tokens.
+func queryFingerprint(req *blogpb.ListBlogRequest) string {
+	query := proto.Clone(req).(*blogpb.ListBlogRequest)
+	query.PageSize = 0
+	query.PageToken = ""
+	return fingerprint(query)
+}
+
+// fingerprint hashes a request whose paging fields were cleared.
+func fingerprint(query proto.Message) string {
+	data, _ := proto.MarshalOptions{Deterministic: true}.Marshal(query)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func encodePageToken(req *blogpb.ListBlogRequest, last *blogstore.Blog) string {
+	token := pageToken{
+		Query:  queryFingerprint(req),
+		LastID: last.ID,
+	}
+	if req.GetOrderBy() == blogpb.SortOrder_TITLE_ASC || req.GetOrderBy() == blogpb.SortOrder_TITLE_DESC {
+		token.LastTitle = last.Title
+	}
+	return token.encode()
+}
+
+func (token pageToken) encode() string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeToken parses a page token and checks it belongs to the query with
+// the given fingerprint.
+func decodeToken(s, query string) (pageToken, error) {
+	token := pageToken{}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return token, errors.New("malformed page_token")
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.LastID == "" {
+		return token, errors.New("malformed page_token")
+	}
+	if token.Query != query {
+		return token, errors.New("page_token does not match the request filters")
+	}
+	return token, nil
+}
+
+// decodePageToken returns the cursor stored in the request's page token, or
+// nil when the request starts a new listing.
+func decodePageToken(req *blogpb.ListBlogRequest) (*blogstore.Cursor, error) {
+	if req.GetPageToken() == "" {
+		return nil, nil
+	}
+	token, err := decodeToken(req.GetPageToken(), queryFingerprint(req))
+	if err != nil {
+		return nil, err
+	}
+	return &blogstore.Cursor{ID: token.LastID, Title: token.LastTitle}, nil
+}
+
+func commentQueryFingerprint(req *blogpb.ListCommentsRequest) string {
+	query := proto.Clone(req).(*blogpb.ListCommentsRequest)
+	query.PageSize = 0
+	query.PageToken = ""
+	return fingerprint(query)
+}
+
+func encodeCommentPageToken(req *blogpb.ListCommentsRequest, last *blogstore.Comment) string {
+	return pageToken{Query: commentQueryFingerprint(req), LastID: last.ID}.encode()
+}
+
+// decodeCommentPageToken returns the id of the last comment already listed,
+// or "" when the request starts a new listing.
+func decodeCommentPageToken(req *blogpb.ListCommentsRequest) (string, error) {
+	if req.GetPageToken() == "" {
+		return "", nil
+	}
+	token, err := decodeToken(req.GetPageToken(), commentQueryFingerprint(req))
+	if err != nil {
+		return "", err
+	}
+	return token.LastID, nil
+}
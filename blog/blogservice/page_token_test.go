@@ -0,0 +1,67 @@
+package blogservice
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+)
+
+func TestDecodePageToken(t *testing.T) {
+	last := &blogstore.Blog{ID: "5f0000000000000000000001", Title: "Title"}
+	byAuthor := &blogpb.ListBlogRequest{AuthorId: "peter", PageSize: 10}
+	byTitle := &blogpb.ListBlogRequest{OrderBy: blogpb.SortOrder_TITLE_ASC}
+
+	tests := []struct {
+		name    string
+		req     *blogpb.ListBlogRequest
+		token   string
+		want    *blogstore.Cursor
+		wantErr bool
+	}{
+		{"first page", byAuthor, "", nil, false},
+		{"next page", byAuthor, encodePageToken(byAuthor, last), &blogstore.Cursor{ID: last.ID}, false},
+		//the page size may change between pages
+		{"other page size", &blogpb.ListBlogRequest{AuthorId: "peter", PageSize: 5}, encodePageToken(byAuthor, last), &blogstore.Cursor{ID: last.ID}, false},
+		{"by title", byTitle, encodePageToken(byTitle, last), &blogstore.Cursor{ID: last.ID, Title: "Title"}, false},
+		{"other filter", &blogpb.ListBlogRequest{AuthorId: "anna"}, encodePageToken(byAuthor, last), nil, true},
+		{"other order", &blogpb.ListBlogRequest{AuthorId: "peter", OrderBy: blogpb.SortOrder_CREATED_DESC}, encodePageToken(byAuthor, last), nil, true},
+		{"not base64", byAuthor, "!!!", nil, true},
+		{"not json", byAuthor, base64.RawURLEncoding.EncodeToString([]byte("token")), nil, true},
+		{"no id", byAuthor, pageToken{Query: queryFingerprint(byAuthor)}.encode(), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &blogpb.ListBlogRequest{
+				AuthorId:  tt.req.GetAuthorId(),
+				OrderBy:   tt.req.GetOrderBy(),
+				PageSize:  tt.req.GetPageSize(),
+				PageToken: tt.token,
+			}
+			got, err := decodePageToken(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodePageToken() failed with %v, want error %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("decodePageToken() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCommentPageToken(t *testing.T) {
+	last := &blogstore.Comment{ID: "5f0000000000000000000001"}
+	req := &blogpb.ListCommentsRequest{BlogId: "5f0000000000000000000002", PageSize: 2}
+	token := encodeCommentPageToken(req, last)
+
+	next := &blogpb.ListCommentsRequest{BlogId: req.GetBlogId(), PageSize: 2, PageToken: token}
+	if got, err := decodeCommentPageToken(next); err != nil || got != last.ID {
+		t.Errorf("decodeCommentPageToken() = %q, %v, want %q", got, err, last.ID)
+	}
+	//replies of a comment are another listing
+	replies := &blogpb.ListCommentsRequest{BlogId: req.GetBlogId(), ParentId: last.ID, PageToken: token}
+	if _, err := decodeCommentPageToken(replies); err == nil {
+		t.Error("decodeCommentPageToken() accepted the token of another listing")
+	}
+}
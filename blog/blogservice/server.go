@@ -0,0 +1,456 @@
+package blogservice
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogauth"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type server struct {
+	blogpb.BlogServiceServer
+	store blogstore.BlogStore
+	// softDelete makes DeleteBlog move blogs to the trash instead of
+	// removing them.
+	softDelete bool
+	// authEnabled restricts writes to the author of a blog and admins, and
+	// takes the author of new blogs from the caller's token.
+	authEnabled bool
+	// draining is closed when the server starts shutting down.
+	draining chan struct{}
+}
+
+func (s *server) CreateBlog(ctx context.Context, req *blogpb.CreateBlogRequest) (*blogpb.CreateBlogResponse, error) {
+	if id, ok := blogauth.FromContext(ctx); ok && s.authEnabled && req.GetBlog() != nil {
+		//the token says who is writing, whatever the request claims
+		req.Blog.AuthorId = id.Subject
+	}
+	if err := validateCreateBlog(req); err != nil {
+		return nil, err
+	}
+
+	blog := req.GetBlog()
+	logging.FromContext(ctx).Debug("Retrieved blog from request", zap.Stringer("blog", blog))
+
+	created, err := s.createBlog(ctx, newBlogData(ctx, blog))
+	if err != nil {
+		return nil, storeError(err, "blog.id", "")
+	}
+
+	return &blogpb.CreateBlogResponse{
+		Blog: dataToBlogPb(created),
+	}, nil
+}
+
+// newBlogData is the store's copy of a validated blog sent to be created.
+func newBlogData(ctx context.Context, blog *blogpb.Blog) *blogstore.Blog {
+	return &blogstore.Blog{
+		AuthorID:       blog.GetAuthorId(),
+		Title:          blog.GetTitle(),
+		Content:        blog.GetContent(),
+		LastModifiedBy: callerIdentity(ctx),
+		Tags:           blog.GetTags(),
+		Category:       blog.GetCategory(),
+		Slug:           blog.GetSlug(),
+	}
+}
+
+func (s *server) ReadBlog(ctx context.Context, req *blogpb.ReadBlogRequest) (*blogpb.ReadBlogResponse, error) {
+	blog_id := req.GetBlogId()
+	logging.FromContext(ctx).Debug("Retrieved blog_id from read blog request", zap.String("blog_id", blog_id))
+
+	retrieved_data, err := s.store.Read(ctx, blog_id)
+	if err != nil {
+		return nil, storeError(err, "blog_id", blog_id)
+	}
+	if !retrieved_data.DeletedAt.IsZero() && !req.GetShowDeleted() {
+		return nil, storeError(blogstore.ErrNotFound, "blog_id", blog_id)
+	}
+
+	return &blogpb.ReadBlogResponse{
+		Blog: dataToBlogPb(retrieved_data),
+	}, nil
+}
+
+func (s *server) GetBlogBySlug(ctx context.Context, req *blogpb.GetBlogBySlugRequest) (*blogpb.GetBlogBySlugResponse, error) {
+	logging.FromContext(ctx).Debug("Retrieved slug from get blog by slug request", zap.String("slug", req.GetSlug()))
+
+	retrieved_data, err := s.store.ReadBySlug(ctx, req.GetSlug())
+	if err != nil {
+		return nil, storeError(err, "slug", req.GetSlug())
+	}
+	if !retrieved_data.DeletedAt.IsZero() && !req.GetShowDeleted() {
+		return nil, storeError(blogstore.ErrNotFound, "slug", req.GetSlug())
+	}
+
+	return &blogpb.GetBlogBySlugResponse{
+		Blog: dataToBlogPb(retrieved_data),
+	}, nil
+}
+
+func (s *server) UpdateBlog(ctx context.Context, req *blogpb.UpdateBlogRequest) (*blogpb.UpdateBlogResponse, error) {
+	new_blog := req.GetBlog()
+	logging.FromContext(ctx).Debug("Retrieved blog_id from update blog request", zap.String("blog_id", new_blog.GetId()))
+
+	var paths []string
+	if len(req.GetUpdateMask().GetPaths()) > 0 {
+		var err error
+		paths, err = updateMaskPaths(req.GetUpdateMask())
+		if err != nil {
+			return nil, badRequest(rpcerr.FieldViolation("update_mask", err.Error()))
+		}
+	}
+	if err := validateUpdateBlog(req, paths); err != nil {
+		return nil, err
+	}
+
+	if paths != nil {
+		updated, err := s.patchBlog(ctx, new_blog, paths, req.GetExpectedVersion(), callerIdentity(ctx))
+		if err != nil {
+			return nil, storeError(err, "blog.id", new_blog.GetId())
+		}
+		return &blogpb.UpdateBlogResponse{
+			Blog: dataToBlogPb(updated),
+		}, nil
+	}
+
+	version, err := s.authorizeWrite(ctx, "blog.id", new_blog.GetId(), req.GetExpectedVersion())
+	if err != nil {
+		return nil, err
+	}
+	//a blog can only be handed to another author by an admin
+	if err := s.checkAuthor(ctx, new_blog.GetAuthorId()); err != nil {
+		return nil, err
+	}
+
+	update := &blogstore.Blog{
+		ID:             new_blog.GetId(),
+		AuthorID:       new_blog.GetAuthorId(),
+		Title:          new_blog.GetTitle(),
+		Content:        new_blog.GetContent(),
+		LastModifiedBy: callerIdentity(ctx),
+		Tags:           new_blog.GetTags(),
+		Category:       new_blog.GetCategory(),
+		Slug:           new_blog.GetSlug(),
+	}
+	updated, err := s.store.Update(ctx, update, version)
+	if err != nil {
+		return nil, storeError(err, "blog.id", new_blog.GetId())
+	}
+
+	return &blogpb.UpdateBlogResponse{
+		Blog: dataToBlogPb(updated),
+	}, nil
+}
+
+func (s *server) DeleteBlog(ctx context.Context, req *blogpb.DeleteBlogRequest) (*blogpb.DeleteBlogResponse, error) {
+
+	logging.FromContext(ctx).Debug("Retrieved blog_id from delete blog request", zap.String("blog_id", req.GetBlogId()))
+
+	version, err := s.authorizeWrite(ctx, "blog_id", req.GetBlogId(), req.GetExpectedVersion())
+	if err != nil {
+		return nil, err
+	}
+	if s.softDelete {
+		_, err = s.store.SoftDelete(ctx, req.GetBlogId(), version, callerIdentity(ctx))
+	} else {
+		err = s.store.Delete(ctx, req.GetBlogId(), version)
+	}
+	if err != nil {
+		return nil, storeError(err, "blog_id", req.GetBlogId())
+	}
+
+	return &blogpb.DeleteBlogResponse{
+		BlogId: req.GetBlogId(),
+	}, nil
+}
+
+func (s *server) UndeleteBlog(ctx context.Context, req *blogpb.UndeleteBlogRequest) (*blogpb.UndeleteBlogResponse, error) {
+	logging.FromContext(ctx).Debug("Retrieved blog_id from undelete blog request", zap.String("blog_id", req.GetBlogId()))
+
+	version, err := s.authorizeWrite(ctx, "blog_id", req.GetBlogId(), req.GetExpectedVersion())
+	if err != nil {
+		return nil, err
+	}
+	restored, err := s.store.Undelete(ctx, req.GetBlogId(), version, callerIdentity(ctx))
+	if err != nil {
+		return nil, storeError(err, "blog_id", req.GetBlogId())
+	}
+
+	return &blogpb.UndeleteBlogResponse{
+		Blog: dataToBlogPb(restored),
+	}, nil
+}
+
+func dataToBlogPb(data *blogstore.Blog) *blogpb.Blog {
+	var deletedAt *timestamppb.Timestamp
+	if !data.DeletedAt.IsZero() {
+		deletedAt = timestamppb.New(data.DeletedAt)
+	}
+	return &blogpb.Blog{
+		Id:       data.ID,
+		AuthorId: data.AuthorID,
+		Title:    data.Title,
+		Content:  data.Content,
+		Version:  data.Version,
+
+		CreatedAt:      timestamppb.New(data.CreatedAt),
+		UpdatedAt:      timestamppb.New(data.UpdatedAt),
+		LastModifiedBy: data.LastModifiedBy,
+		DeletedAt:      deletedAt,
+
+		Tags:     data.Tags,
+		Category: data.Category,
+		Slug:     data.Slug,
+	}
+}
+
+// callerIdentity names whoever sent the request, for LastModifiedBy. That is
+// the subject of the caller's token, or the peer address for anonymous calls.
+func callerIdentity(ctx context.Context) string {
+	if id, ok := blogauth.FromContext(ctx); ok {
+		return id.Subject
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// timeRange converts a TimeRange from a request, rejecting invalid timestamps.
+func timeRange(r *blogpb.TimeRange) (blogstore.TimeRange, error) {
+	var converted blogstore.TimeRange
+	if r.GetStart() != nil {
+		if err := r.GetStart().CheckValid(); err != nil {
+			return converted, err
+		}
+		converted.Start = r.GetStart().AsTime()
+	}
+	if r.GetEnd() != nil {
+		if err := r.GetEnd().CheckValid(); err != nil {
+			return converted, err
+		}
+		converted.End = r.GetEnd().AsTime()
+	}
+	return converted, nil
+}
+
+func (s *server) ListBlog(req *blogpb.ListBlogRequest, stream blogpb.BlogService_ListBlogServer) error {
+	logger := logging.FromContext(stream.Context())
+	logger.Debug("Inside list blog", zap.Stringer("request", req))
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	if req.GetPageSize() < 0 {
+		violations = append(violations, rpcerr.FieldViolation("page_size", "cannot be negative"))
+	}
+	after, err := decodePageToken(req)
+	if err != nil {
+		violations = append(violations, rpcerr.FieldViolation("page_token", fmt.Sprintf("Invalid page_token sent: %v", err)))
+	}
+	created, err := timeRange(req.GetCreated())
+	if err != nil {
+		violations = append(violations, rpcerr.FieldViolation("created", fmt.Sprintf("Invalid created range sent: %v", err)))
+	}
+	updated, err := timeRange(req.GetUpdated())
+	if err != nil {
+		violations = append(violations, rpcerr.FieldViolation("updated", fmt.Sprintf("Invalid updated range sent: %v", err)))
+	}
+	if err := badRequest(violations...); err != nil {
+		return err
+	}
+	opts := blogstore.ListOptions{
+		AuthorID:    req.GetAuthorId(),
+		TitlePrefix: req.GetTitlePrefix(),
+		Created:     created,
+		Updated:     updated,
+		Deleted:     deletedFilters[req.GetDeleted()],
+		Tags:        normalizeTags(req.GetTags()),
+		Category:    strings.TrimSpace(req.GetCategory()),
+		Order:       sortOrders[req.GetOrderBy()],
+		After:       after,
+	}
+	if req.GetPageSize() > 0 {
+		//fetch one extra blog to know whether another page follows
+		opts.Limit = int(req.GetPageSize()) + 1
+	}
+
+	//each blog is sent once the next one is known, so the last blog of the
+	//listing goes out with an empty next_page_token
+	var pending *blogstore.Blog
+	sent := 0
+	err = s.store.List(stream.Context(), opts, func(data *blogstore.Blog) error {
+		logger.Debug("Just retrieved blog from store", zap.String("blog_id", data.ID))
+		if pending != nil {
+			if err := stream.Send(&blogpb.ListBlogResponse{
+				Blog:          dataToBlogPb(pending),
+				NextPageToken: encodePageToken(req, pending),
+			}); err != nil {
+				return err
+			}
+			sent++
+		}
+		pending = data
+		if opts.Limit > 0 && sent == int(req.GetPageSize()) {
+			//the extra blog only proves there is a next page, don't send it
+			pending = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return internalError(fmt.Errorf("listing blogs: %w", err))
+	}
+	if pending != nil {
+		return stream.Send(&blogpb.ListBlogResponse{Blog: dataToBlogPb(pending)})
+	}
+	return nil
+}
+
+func (s *server) ListTags(ctx context.Context, req *blogpb.ListTagsRequest) (*blogpb.ListTagsResponse, error) {
+	logging.FromContext(ctx).Debug("Inside list tags", zap.Stringer("request", req))
+
+	counts, err := s.store.ListTags(ctx, blogstore.TagOptions{Category: strings.TrimSpace(req.GetCategory())})
+	if err != nil {
+		return nil, internalError(fmt.Errorf("counting tags: %w", err))
+	}
+	res := &blogpb.ListTagsResponse{}
+	for _, count := range counts {
+		res.Tags = append(res.Tags, &blogpb.TagCount{Tag: count.Tag, Count: count.Count})
+	}
+	return res, nil
+}
+
+func (s *server) SearchBlogs(req *blogpb.SearchBlogsRequest, stream blogpb.BlogService_SearchBlogsServer) error {
+	logging.FromContext(stream.Context()).Debug("Inside search blogs", zap.Stringer("request", req))
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	if strings.TrimSpace(req.GetQuery()) == "" {
+		violations = append(violations, rpcerr.FieldViolation("query", "cannot be empty"))
+	}
+	if req.GetLimit() < 0 {
+		violations = append(violations, rpcerr.FieldViolation("limit", "cannot be negative"))
+	}
+	if err := badRequest(violations...); err != nil {
+		return err
+	}
+
+	opts := blogstore.SearchOptions{
+		Query: req.GetQuery(),
+		Limit: int(req.GetLimit()),
+	}
+	err := s.store.Search(stream.Context(), opts, func(result *blogstore.SearchResult) error {
+		return stream.Send(&blogpb.SearchBlogsResponse{
+			Blog:           dataToBlogPb(result.Blog),
+			Score:          result.Score,
+			TitleHighlight: blogstore.Highlight(result.Blog.Title, req.GetQuery()),
+			Snippets:       blogstore.Snippets(result.Blog.Content, req.GetQuery(), maxSnippets),
+		})
+	})
+	if err != nil {
+		return internalError(fmt.Errorf("searching blogs: %w", err))
+	}
+	return nil
+}
+
+func (s *server) ListBlogRevisions(req *blogpb.ListBlogRevisionsRequest, stream blogpb.BlogService_ListBlogRevisionsServer) error {
+	logging.FromContext(stream.Context()).Debug("Inside list blog revisions", zap.String("blog_id", req.GetBlogId()))
+
+	err := s.store.ListRevisions(stream.Context(), req.GetBlogId(), func(revision *blogstore.Blog) error {
+		return stream.Send(&blogpb.ListBlogRevisionsResponse{Revision: dataToBlogPb(revision)})
+	})
+	if err != nil {
+		return storeError(err, "blog_id", req.GetBlogId())
+	}
+	return nil
+}
+
+func (s *server) RestoreBlogRevision(ctx context.Context, req *blogpb.RestoreBlogRevisionRequest) (*blogpb.RestoreBlogRevisionResponse, error) {
+	logging.FromContext(ctx).Debug("Restoring blog revision", zap.Int64("version", req.GetVersion()), zap.String("blog_id", req.GetBlogId()))
+
+	version, err := s.authorizeWrite(ctx, "blog_id", req.GetBlogId(), req.GetExpectedVersion())
+	if err != nil {
+		return nil, err
+	}
+	revision, err := s.store.ReadRevision(ctx, req.GetBlogId(), req.GetVersion())
+	if err != nil {
+		return nil, storeError(err, "blog_id", req.GetBlogId())
+	}
+	//an old revision may name another author, restoring it hands the blog over
+	if err := s.checkAuthor(ctx, revision.AuthorID); err != nil {
+		return nil, err
+	}
+
+	//the slug comes back too, unless another blog took it in the meantime,
+	//revisions from before slugs existed keep the current one
+	restore := &blogstore.Blog{
+		ID:             revision.ID,
+		AuthorID:       revision.AuthorID,
+		Title:          revision.Title,
+		Content:        revision.Content,
+		LastModifiedBy: callerIdentity(ctx),
+		Tags:           revision.Tags,
+		Category:       revision.Category,
+		Slug:           revision.Slug,
+	}
+	restored, err := s.store.Update(ctx, restore, version)
+	if err != nil {
+		return nil, storeError(err, "blog_id", req.GetBlogId())
+	}
+
+	return &blogpb.RestoreBlogRevisionResponse{
+		Blog: dataToBlogPb(restored),
+	}, nil
+}
+
+func (s *server) DiffBlogRevisions(ctx context.Context, req *blogpb.DiffBlogRevisionsRequest) (*blogpb.DiffBlogRevisionsResponse, error) {
+	logging.FromContext(ctx).Debug("Diffing blog revisions", zap.Int64("from_version", req.GetFromVersion()), zap.Int64("to_version", req.GetToVersion()), zap.String("blog_id", req.GetBlogId()))
+
+	from, err := s.store.ReadRevision(ctx, req.GetBlogId(), req.GetFromVersion())
+	if err != nil {
+		return nil, storeError(err, "blog_id", req.GetBlogId())
+	}
+	var to *blogstore.Blog
+	if req.GetToVersion() == 0 {
+		to, err = s.store.Read(ctx, req.GetBlogId())
+	} else {
+		to, err = s.store.ReadRevision(ctx, req.GetBlogId(), req.GetToVersion())
+	}
+	if err != nil {
+		return nil, storeError(err, "blog_id", req.GetBlogId())
+	}
+
+	return &blogpb.DiffBlogRevisionsResponse{
+		From:   dataToBlogPb(from),
+		To:     dataToBlogPb(to),
+		Fields: diffBlogs(from, to),
+	}, nil
+}
+
+// maxSnippets is the number of content fragments returned per search result.
+const maxSnippets = 3
+
+var deletedFilters = map[blogpb.DeletedFilter]blogstore.DeletedFilter{
+	blogpb.DeletedFilter_EXCLUDE_DELETED: blogstore.ExcludeDeleted,
+	blogpb.DeletedFilter_INCLUDE_DELETED: blogstore.IncludeDeleted,
+	blogpb.DeletedFilter_ONLY_DELETED:    blogstore.OnlyDeleted,
+}
+
+var sortOrders = map[blogpb.SortOrder]blogstore.SortOrder{
+	blogpb.SortOrder_CREATED_ASC:  blogstore.SortCreatedAsc,
+	blogpb.SortOrder_CREATED_DESC: blogstore.SortCreatedDesc,
+	blogpb.SortOrder_TITLE_ASC:    blogstore.SortTitleAsc,
+	blogpb.SortOrder_TITLE_DESC:   blogstore.SortTitleDesc,
+}
@@ -0,0 +1,424 @@
+package blogservice
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a BlogService over an empty memory store, without
+// authentication.
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	store := blogstore.NewMemoryStore()
+	t.Cleanup(func() { store.Close(context.Background()) })
+	return &server{store: store, draining: make(chan struct{})}
+}
+
+// createTestBlog creates blog through CreateBlog and returns the stored copy.
+func createTestBlog(t *testing.T, s *server, blog *blogpb.Blog) *blogpb.Blog {
+	t.Helper()
+	res, err := s.CreateBlog(context.Background(), &blogpb.CreateBlogRequest{Blog: blog})
+	if err != nil {
+		t.Fatalf("CreateBlog failed: %v", err)
+	}
+	return res.GetBlog()
+}
+
+// wantCode fails the test unless err is a status with the given code.
+func wantCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != code {
+		t.Fatalf("got %v (%v), want %v", got, err, code)
+	}
+}
+
+// listStream collects what ListBlog sends.
+type listStream struct {
+	grpc.ServerStream
+	sent []*blogpb.ListBlogResponse
+}
+
+func (s *listStream) Context() context.Context { return context.Background() }
+
+func (s *listStream) Send(res *blogpb.ListBlogResponse) error {
+	s.sent = append(s.sent, res)
+	return nil
+}
+
+// listBlogs calls ListBlog and returns the blogs it sent with the token of
+// the next page.
+func listBlogs(t *testing.T, s *server, req *blogpb.ListBlogRequest) ([]*blogpb.Blog, string) {
+	t.Helper()
+	stream := &listStream{}
+	if err := s.ListBlog(req, stream); err != nil {
+		t.Fatalf("ListBlog failed: %v", err)
+	}
+	var blogs []*blogpb.Blog
+	next := ""
+	for _, res := range stream.sent {
+		blogs = append(blogs, res.GetBlog())
+		next = res.GetNextPageToken()
+	}
+	return blogs, next
+}
+
+// searchStream collects what SearchBlogs sends.
+type searchStream struct {
+	grpc.ServerStream
+	sent []*blogpb.SearchBlogsResponse
+}
+
+func (s *searchStream) Context() context.Context { return context.Background() }
+
+func (s *searchStream) Send(res *blogpb.SearchBlogsResponse) error {
+	s.sent = append(s.sent, res)
+	return nil
+}
+
+func TestBlogLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	created := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Hello", Content: "content", Tags: []string{"Go"}})
+	if created.GetId() == "" || created.GetCreatedAt() == nil || created.GetUpdatedAt() == nil {
+		t.Fatalf("CreateBlog() = %v, want a blog with an id and its times", created)
+	}
+	if created.GetSlug() != "hello" || !reflect.DeepEqual(created.GetTags(), []string{"go"}) {
+		t.Errorf("CreateBlog() = %v, want the slug hello and the normalized tags [go]", created)
+	}
+	//anonymous callers are named by their address
+	if created.GetLastModifiedBy() != "unknown" {
+		t.Errorf("created last modified by %q, want unknown", created.GetLastModifiedBy())
+	}
+
+	read, err := s.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: created.GetId()})
+	if err != nil {
+		t.Fatalf("ReadBlog failed: %v", err)
+	}
+	if read.GetBlog().GetTitle() != "Hello" {
+		t.Errorf("ReadBlog() = %v, want the created blog", read.GetBlog())
+	}
+	bySlug, err := s.GetBlogBySlug(ctx, &blogpb.GetBlogBySlugRequest{Slug: "hello"})
+	if err != nil || bySlug.GetBlog().GetId() != created.GetId() {
+		t.Errorf("GetBlogBySlug() = %v, %v, want blog %s", bySlug.GetBlog(), err, created.GetId())
+	}
+
+	updated, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{
+		Blog: &blogpb.Blog{Id: created.GetId(), AuthorId: "peter", Title: "Hello again", Content: "new content"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateBlog failed: %v", err)
+	}
+	if updated.GetBlog().GetTitle() != "Hello again" || updated.GetBlog().GetContent() != "new content" || updated.GetBlog().GetSlug() != "hello" {
+		t.Errorf("UpdateBlog() = %v, want the new fields and the kept slug", updated.GetBlog())
+	}
+
+	if _, err := s.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: created.GetId()}); err != nil {
+		t.Fatalf("DeleteBlog failed: %v", err)
+	}
+	//without soft delete nothing goes to the trash
+	_, err = s.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: created.GetId(), ShowDeleted: true})
+	wantCode(t, err, codes.NotFound)
+	_, err = s.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: created.GetId()})
+	wantCode(t, err, codes.NotFound)
+}
+
+func TestBlogVersions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	created := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Hello", Content: "content"})
+	if created.GetVersion() != 1 {
+		t.Fatalf("created version = %d, want 1", created.GetVersion())
+	}
+
+	updated, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{
+		Blog:            &blogpb.Blog{Id: created.GetId(), AuthorId: "peter", Title: "Hello again", Content: "new content"},
+		ExpectedVersion: 1,
+	})
+	if err != nil {
+		t.Fatalf("UpdateBlog failed: %v", err)
+	}
+	if updated.GetBlog().GetVersion() != 2 {
+		t.Errorf("updated version = %d, want 2", updated.GetBlog().GetVersion())
+	}
+	_, err = s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{
+		Blog:            &blogpb.Blog{Id: created.GetId(), AuthorId: "peter", Title: "Stale", Content: "content"},
+		ExpectedVersion: 1,
+	})
+	wantCode(t, err, codes.Aborted)
+
+	_, err = s.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: created.GetId(), ExpectedVersion: 1})
+	wantCode(t, err, codes.Aborted)
+	if _, err := s.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: created.GetId(), ExpectedVersion: 2}); err != nil {
+		t.Fatalf("DeleteBlog failed: %v", err)
+	}
+}
+
+func TestBlogErrors(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	const missing = "5f0000000000000000000000"
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"create without blog", func() error {
+			_, err := s.CreateBlog(ctx, &blogpb.CreateBlogRequest{})
+			return err
+		}, codes.InvalidArgument},
+		{"read invalid id", func() error {
+			_, err := s.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: "bad"})
+			return err
+		}, codes.InvalidArgument},
+		{"read missing", func() error {
+			_, err := s.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: missing})
+			return err
+		}, codes.NotFound},
+		{"missing slug", func() error {
+			_, err := s.GetBlogBySlug(ctx, &blogpb.GetBlogBySlugRequest{Slug: "missing"})
+			return err
+		}, codes.NotFound},
+		{"update missing", func() error {
+			_, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: missing, AuthorId: "peter", Title: "Title", Content: "content"}})
+			return err
+		}, codes.NotFound},
+		{"delete invalid id", func() error {
+			_, err := s.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: "bad"})
+			return err
+		}, codes.InvalidArgument},
+		{"missing revision", func() error {
+			_, err := s.RestoreBlogRevision(ctx, &blogpb.RestoreBlogRevisionRequest{BlogId: missing, Version: 1})
+			return err
+		}, codes.NotFound},
+		{"undelete missing", func() error {
+			_, err := s.UndeleteBlog(ctx, &blogpb.UndeleteBlogRequest{BlogId: missing})
+			return err
+		}, codes.NotFound},
+		{"empty search", func() error {
+			return s.SearchBlogs(&blogpb.SearchBlogsRequest{Query: " "}, &searchStream{})
+		}, codes.InvalidArgument},
+		{"negative page size", func() error {
+			return s.ListBlog(&blogpb.ListBlogRequest{PageSize: -1}, &listStream{})
+		}, codes.InvalidArgument},
+		{"bad page token", func() error {
+			return s.ListBlog(&blogpb.ListBlogRequest{PageToken: "bad"}, &listStream{})
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantCode(t, tt.call(), tt.want)
+		})
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	s.softDelete = true
+	blog := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content"})
+
+	if _, err := s.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: blog.GetId()}); err != nil {
+		t.Fatalf("DeleteBlog failed: %v", err)
+	}
+	_, err := s.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: blog.GetId()})
+	wantCode(t, err, codes.NotFound)
+	_, err = s.GetBlogBySlug(ctx, &blogpb.GetBlogBySlugRequest{Slug: blog.GetSlug()})
+	wantCode(t, err, codes.NotFound)
+	trashed, err := s.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: blog.GetId(), ShowDeleted: true})
+	if err != nil || trashed.GetBlog().GetDeletedAt() == nil {
+		t.Fatalf("ReadBlog() = %v, %v, want the blog in the trash", trashed.GetBlog(), err)
+	}
+	if blogs, _ := listBlogs(t, s, &blogpb.ListBlogRequest{}); len(blogs) != 0 {
+		t.Errorf("ListBlog() = %v, want the trash left out", blogs)
+	}
+	if blogs, _ := listBlogs(t, s, &blogpb.ListBlogRequest{Deleted: blogpb.DeletedFilter_ONLY_DELETED}); len(blogs) != 1 {
+		t.Errorf("ListBlog() of the trash = %v, want the deleted blog", blogs)
+	}
+
+	_, err = s.UndeleteBlog(ctx, &blogpb.UndeleteBlogRequest{BlogId: blog.GetId(), ExpectedVersion: 1})
+	wantCode(t, err, codes.Aborted)
+	restored, err := s.UndeleteBlog(ctx, &blogpb.UndeleteBlogRequest{BlogId: blog.GetId()})
+	if err != nil {
+		t.Fatalf("UndeleteBlog failed: %v", err)
+	}
+	if restored.GetBlog().GetDeletedAt() != nil || restored.GetBlog().GetVersion() != 3 {
+		t.Errorf("UndeleteBlog() = %v, want version 3 out of the trash", restored.GetBlog())
+	}
+	_, err = s.UndeleteBlog(ctx, &blogpb.UndeleteBlogRequest{BlogId: blog.GetId()})
+	wantCode(t, err, codes.FailedPrecondition)
+}
+
+func TestListBlogPages(t *testing.T) {
+	s := newTestServer(t)
+	var want []string
+	for _, title := range []string{"b", "d", "a", "c", "e"} {
+		createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: title, Content: "content"})
+		want = append(want, title)
+	}
+	createTestBlog(t, s, &blogpb.Blog{AuthorId: "anna", Title: "f", Content: "content"})
+
+	tests := []struct {
+		name string
+		req  *blogpb.ListBlogRequest
+		want []string
+	}{
+		{"created", &blogpb.ListBlogRequest{AuthorId: "peter"}, want},
+		{"title", &blogpb.ListBlogRequest{AuthorId: "peter", OrderBy: blogpb.SortOrder_TITLE_ASC}, []string{"a", "b", "c", "d", "e"}},
+		{"title descending", &blogpb.ListBlogRequest{AuthorId: "peter", OrderBy: blogpb.SortOrder_TITLE_DESC}, []string{"e", "d", "c", "b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var titles []string
+			req := tt.req
+			req.PageSize = 2
+			for pages := 1; ; pages++ {
+				blogs, next := listBlogs(t, s, req)
+				for _, blog := range blogs {
+					titles = append(titles, blog.GetTitle())
+				}
+				if next == "" {
+					//5 blogs make 3 pages, the last one without a token
+					if pages != 3 {
+						t.Errorf("got %d pages, want 3", pages)
+					}
+					break
+				}
+				if len(blogs) != 2 {
+					t.Fatalf("page %d has %d blogs and a next page token, want 2", pages, len(blogs))
+				}
+				req.PageToken = next
+			}
+			if !reflect.DeepEqual(titles, tt.want) {
+				t.Errorf("pages hold %q, want %q", titles, tt.want)
+			}
+		})
+	}
+
+	//the token of a full last page leads to an empty one
+	blogs, next := listBlogs(t, s, &blogpb.ListBlogRequest{AuthorId: "peter", PageSize: 5})
+	if len(blogs) != 5 || next != "" {
+		t.Errorf("ListBlog() of exactly one page = %d blogs with token %q, want 5 without a token", len(blogs), next)
+	}
+}
+
+func TestSearchBlogs(t *testing.T) {
+	s := newTestServer(t)
+	createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Streaming in gRPC", Content: "Server streams send many messages."})
+	createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Unary calls", Content: "One request, one response."})
+
+	stream := &searchStream{}
+	if err := s.SearchBlogs(&blogpb.SearchBlogsRequest{Query: "streams"}, stream); err != nil {
+		t.Fatalf("SearchBlogs failed: %v", err)
+	}
+	if len(stream.sent) != 1 {
+		t.Fatalf("SearchBlogs() sent %d results, want 1", len(stream.sent))
+	}
+	res := stream.sent[0]
+	if res.GetBlog().GetTitle() != "Streaming in gRPC" || res.GetScore() <= 0 {
+		t.Errorf("SearchBlogs() = %v with score %v, want the streaming blog", res.GetBlog(), res.GetScore())
+	}
+	if res.GetTitleHighlight() != "<em>Streaming</em> in gRPC" {
+		t.Errorf("title highlight = %q, want the stemmed match highlighted", res.GetTitleHighlight())
+	}
+	if want := []string{"Server <em>streams</em> send many messages"}; !reflect.DeepEqual(res.GetSnippets(), want) {
+		t.Errorf("snippets = %q, want %q", res.GetSnippets(), want)
+	}
+}
+
+func TestDiffBlogRevisions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	blog := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "one\ntwo"})
+	_, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: blog.GetId(), AuthorId: "peter", Title: "Title", Content: "one\nthree"}})
+	if err != nil {
+		t.Fatalf("UpdateBlog failed: %v", err)
+	}
+
+	//without a to_version the diff goes up to the current blog
+	res, err := s.DiffBlogRevisions(ctx, &blogpb.DiffBlogRevisionsRequest{BlogId: blog.GetId(), FromVersion: 1})
+	if err != nil {
+		t.Fatalf("DiffBlogRevisions failed: %v", err)
+	}
+	if res.GetFrom().GetVersion() != 1 || res.GetTo().GetVersion() != 2 {
+		t.Errorf("diffed versions %d and %d, want 1 and 2", res.GetFrom().GetVersion(), res.GetTo().GetVersion())
+	}
+	if len(res.GetFields()) != 1 || formatDiff(res.GetFields()[0].GetLines()) != " one,-two,+three" {
+		t.Errorf("DiffBlogRevisions() = %v, want the content changed", res.GetFields())
+	}
+	_, err = s.DiffBlogRevisions(ctx, &blogpb.DiffBlogRevisionsRequest{BlogId: blog.GetId(), FromVersion: 1, ToVersion: 5})
+	wantCode(t, err, codes.NotFound)
+}
+
+func TestRestoreBlogRevision(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	original := createTestBlog(t, s, &blogpb.Blog{
+		AuthorId: "peter",
+		Title:    "First",
+		Content:  "first content",
+		Tags:     []string{"go", "grpc"},
+		Category: "tutorials",
+		Slug:     "first",
+	})
+	_, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{
+		Id:       original.GetId(),
+		AuthorId: "peter",
+		Title:    "Second",
+		Content:  "second content",
+		Tags:     []string{"mongo"},
+		Slug:     "second",
+	}})
+	if err != nil {
+		t.Fatalf("UpdateBlog failed: %v", err)
+	}
+
+	res, err := s.RestoreBlogRevision(ctx, &blogpb.RestoreBlogRevisionRequest{BlogId: original.GetId(), Version: 1})
+	if err != nil {
+		t.Fatalf("RestoreBlogRevision failed: %v", err)
+	}
+	restored := res.GetBlog()
+	if restored.GetVersion() != 3 {
+		t.Errorf("restored version = %d, want 3", restored.GetVersion())
+	}
+	if restored.GetTitle() != "First" || restored.GetContent() != "first content" {
+		t.Errorf("restored title and content = %q, %q, want those of version 1", restored.GetTitle(), restored.GetContent())
+	}
+	if !reflect.DeepEqual(restored.GetTags(), original.GetTags()) || restored.GetCategory() != "tutorials" {
+		t.Errorf("restored tags and category = %q, %q, want %q, tutorials", restored.GetTags(), restored.GetCategory(), original.GetTags())
+	}
+	if restored.GetSlug() != "first" {
+		t.Errorf("restored slug = %q, want first", restored.GetSlug())
+	}
+
+	//the restore is a revision of its own, with the restored fields
+	latest, err := s.store.ReadRevision(ctx, original.GetId(), 3)
+	if err != nil {
+		t.Fatalf("ReadRevision failed: %v", err)
+	}
+	if !reflect.DeepEqual(latest.Tags, original.GetTags()) || latest.Category != "tutorials" {
+		t.Errorf("revision 3 tags and category = %q, %q, want %q, tutorials", latest.Tags, latest.Category, original.GetTags())
+	}
+}
+
+func TestRestoreBlogRevisionSlugTaken(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	original := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "First", Content: "content", Slug: "first"})
+	_, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{
+		Id: original.GetId(), AuthorId: "peter", Title: "Renamed", Content: "content", Slug: "renamed",
+	}})
+	if err != nil {
+		t.Fatalf("UpdateBlog failed: %v", err)
+	}
+	createTestBlog(t, s, &blogpb.Blog{AuthorId: "anna", Title: "Other", Content: "content", Slug: "first"})
+
+	_, err = s.RestoreBlogRevision(ctx, &blogpb.RestoreBlogRevisionRequest{BlogId: original.GetId(), Version: 1})
+	wantCode(t, err, codes.AlreadyExists)
+}
@@ -0,0 +1,150 @@
+// Package blogservice implements the BlogService and CommentService of
+// blog/blogpb on top of a blogstore. blog_server serves it on its own, the
+// combined server next to the other services of the course.
+package blogservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogauth"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"github.com/Peter-Yocum/grpc-go-course/config"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// Config holds the settings of the blog service.
+type Config struct {
+	Store      blogstore.Options
+	SoftDelete bool
+	// TrashRetention is how long blogs stay in the trash before they are
+	// purged.
+	TrashRetention time.Duration
+	// AuthKey is the PEM public key checking bearer tokens, writes are open
+	// to anyone without it.
+	AuthKey string
+}
+
+// AddSettings registers the settings of the blog service with cfg. They are
+// not validated until Validate is added as a check.
+func AddSettings(cfg *config.Loader) *Config {
+	c := &Config{}
+	cfg.String(&c.Store.Backend, "store", blogstore.BackendMongo, "blog storage backend: mongo, memory or bolt")
+	cfg.String(&c.Store.MongoURI, "mongo-uri", "mongodb://localhost:27017", "address of the mongo store")
+	cfg.String(&c.Store.MongoDatabase, "mongo-database", "blogdb", "database of the mongo store")
+	cfg.String(&c.Store.BoltPath, "bolt-path", "blog.db", "database file used by the bolt store")
+	cfg.Bool(&c.SoftDelete, "soft-delete", false, "move deleted blogs to the trash instead of removing them")
+	cfg.Duration(&c.TrashRetention, "trash-retention", 30*24*time.Hour, "how long blogs stay in the trash before they are purged")
+	cfg.String(&c.AuthKey, "auth-key", "", "PEM public key checking bearer tokens, writes are open to anyone without it")
+	return c
+}
+
+// Validate reports the first invalid setting.
+func (c *Config) Validate() error {
+	switch c.Store.Backend {
+	case blogstore.BackendMongo, blogstore.BackendMemory, blogstore.BackendBolt:
+	default:
+		return fmt.Errorf("store must be mongo, memory or bolt, got %q", c.Store.Backend)
+	}
+	if c.TrashRetention <= 0 {
+		return fmt.Errorf("trash-retention must be positive, got %v", c.TrashRetention)
+	}
+	return nil
+}
+
+// Service is the blog service with its open store.
+type Service struct {
+	store blogstore.BlogStore
+	blogs *server
+	// auth is nil when no auth key is configured.
+	auth      *blogauth.Authenticator
+	stopPurge context.CancelFunc
+	// stopHealth is set by StartHealthReports.
+	stopHealth context.CancelFunc
+}
+
+// New opens the store described by cfg and starts purging its trash. The
+// store is closed again by Close.
+func New(ctx context.Context, cfg *Config) (*Service, error) {
+	var auth *blogauth.Authenticator
+	if cfg.AuthKey != "" {
+		verifier, err := blogauth.NewVerifierFromFile(cfg.AuthKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading auth key: %w", err)
+		}
+		auth = newAuthenticator(verifier)
+	} else {
+		zap.L().Warn("No auth-key given, anyone can change or delete any blog")
+	}
+
+	//open the blog store, mongodb by default
+	zap.L().Info("Opening blog store", zap.String("backend", cfg.Store.Backend))
+	store, err := blogstore.Open(ctx, cfg.Store)
+	if err != nil {
+		return nil, fmt.Errorf("opening blog store: %w", err)
+	}
+	//record latency, failures and streamed documents of the store operations
+	store = blogstore.Instrument(store, cfg.Store.Backend)
+
+	//purge the trash in the background, even with soft delete turned off
+	//there may be blogs left in it from an earlier run
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	go purgeTrash(purgeCtx, store, cfg.TrashRetention)
+
+	return &Service{
+		store:     store,
+		blogs:     &server{store: store, softDelete: cfg.SoftDelete, authEnabled: auth != nil, draining: make(chan struct{})},
+		auth:      auth,
+		stopPurge: stopPurge,
+	}, nil
+}
+
+// Register registers BlogService and CommentService with s.
+func (svc *Service) Register(s grpc.ServiceRegistrar) {
+	blogpb.RegisterBlogServiceServer(s, svc.blogs)
+	blogpb.RegisterCommentServiceServer(s, &commentServer{blogs: svc.blogs})
+}
+
+// ServerOptions returns the interceptors the service needs on its server.
+// They only look at calls to the blog services, so the server may serve
+// other services as well.
+func (svc *Service) ServerOptions() []grpc.ServerOption {
+	if svc.auth == nil {
+		return nil
+	}
+	unary := svc.auth.UnaryServerInterceptor()
+	stream := svc.auth.StreamServerInterceptor()
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if !isBlogMethod(info.FullMethod) {
+				return handler(ctx, req)
+			}
+			return unary(ctx, req, info, handler)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if !isBlogMethod(info.FullMethod) {
+				return handler(srv, ss)
+			}
+			return stream(srv, ss, info, handler)
+		}),
+	}
+}
+
+// Drain ends the WatchBlogs streams with UNAVAILABLE, so that a graceful
+// stop doesn't wait for them. Their clients watch again elsewhere or once
+// the server is back. Drain is called at most once.
+func (svc *Service) Drain() {
+	close(svc.blogs.draining)
+}
+
+// Close stops the background work on the store and closes it.
+func (svc *Service) Close(ctx context.Context) error {
+	svc.stopPurge()
+	if svc.stopHealth != nil {
+		svc.stopHealth()
+	}
+	return svc.store.Close(ctx)
+}
@@ -0,0 +1,148 @@
+package blogservice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxSlugLength is the longest slug accepted or generated.
+const maxSlugLength = 100
+
+// maxSlugAttempts bounds how many numbered slugs are tried when the slug
+// derived from the title is taken. Past it the slug gets a random suffix,
+// which no other blog uses.
+const maxSlugAttempts = 10
+
+// maxRandomSlugAttempts bounds the random suffixes tried after the numbered
+// slugs, in case the store keeps reporting them taken.
+const maxRandomSlugAttempts = 3
+
+// slugify derives a slug from a title: lower case ASCII letters and digits in
+// runs separated by single dashes. Accents are dropped, everything else
+// becomes a separator.
+func slugify(title string) string {
+	var b strings.Builder
+	separate := false
+	for _, r := range norm.NFKD.String(title) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			//combining accent split off by NFKD
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if separate && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			separate = false
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			separate = true
+		}
+	}
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	if slug == "" {
+		return "blog"
+	}
+	return slug
+}
+
+// numberedSlug returns base for the first attempt and base-2, base-3, ...
+// after that.
+func numberedSlug(base string, attempt int) string {
+	if attempt == 1 {
+		return base
+	}
+	return suffixedSlug(base, fmt.Sprintf("-%d", attempt))
+}
+
+// randomSlug returns base with a random suffix.
+func randomSlug(base string) string {
+	var b [6]byte
+	rand.Read(b[:])
+	return suffixedSlug(base, "-"+hex.EncodeToString(b[:]))
+}
+
+// suffixedSlug returns base with suffix, shortening base so the result
+// still fits.
+func suffixedSlug(base, suffix string) string {
+	if len(base)+len(suffix) > maxSlugLength {
+		base = strings.TrimRight(base[:maxSlugLength-len(suffix)], "-")
+	}
+	return base + suffix
+}
+
+// candidateSlug returns the slug to try for the given attempt: numbered up
+// to maxSlugAttempts, random after that.
+func candidateSlug(base string, attempt int) string {
+	if attempt <= maxSlugAttempts {
+		return numberedSlug(base, attempt)
+	}
+	return randomSlug(base)
+}
+
+// createBlog stores a new blog. A blog sent without a slug gets one derived
+// from its title, numbered or given a random suffix if other blogs already
+// use it.
+func (s *server) createBlog(ctx context.Context, data *blogstore.Blog) (*blogstore.Blog, error) {
+	if data.Slug != "" {
+		return s.store.Create(ctx, data)
+	}
+	base := slugify(data.Title)
+	for attempt := 1; ; attempt++ {
+		data.Slug = candidateSlug(base, attempt)
+		created, err := s.store.Create(ctx, data)
+		if errors.Is(err, blogstore.ErrSlugTaken) && attempt < maxSlugAttempts+maxRandomSlugAttempts {
+			continue
+		}
+		return created, err
+	}
+}
+
+// pickSlugs gives every blog of a batch sent without a slug one derived from
+// its title, numbered past the slugs of stored blogs and of the other blogs
+// in the batch, or given a random suffix like createBlog does. It reports
+// which blogs got a picked slug.
+func (s *server) pickSlugs(ctx context.Context, blogs []*blogstore.Blog) ([]bool, error) {
+	used := make(map[string]bool, len(blogs))
+	for _, blog := range blogs {
+		if blog.Slug != "" {
+			used[blog.Slug] = true
+		}
+	}
+	picked := make([]bool, len(blogs))
+	for i, blog := range blogs {
+		if blog.Slug != "" {
+			continue
+		}
+		picked[i] = true
+		base := slugify(blog.Title)
+		for attempt := 1; ; attempt++ {
+			blog.Slug = candidateSlug(base, attempt)
+			if used[blog.Slug] {
+				continue
+			}
+			_, err := s.store.ReadBySlug(ctx, blog.Slug)
+			if errors.Is(err, blogstore.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			//give up like createBlog, the store will report the slug taken
+			if attempt >= maxSlugAttempts+maxRandomSlugAttempts {
+				break
+			}
+		}
+		used[blog.Slug] = true
+	}
+	return picked, nil
+}
@@ -0,0 +1,136 @@
+package blogservice
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSlugify(t *testing.T) {
+	long := strings.Repeat("a", maxSlugLength-1) + " bc"
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello World", "hello-world"},
+		{"  gRPC & Go: streams!  ", "grpc-go-streams"},
+		{"Crème brûlée", "creme-brulee"},
+		{"version 2.0", "version-2-0"},
+		{"日本語", "blog"},
+		{"", "blog"},
+		{"!!!", "blog"},
+		{long, strings.Repeat("a", maxSlugLength-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := slugify(tt.title); got != tt.want {
+				t.Errorf("slugify(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumberedSlug(t *testing.T) {
+	long := strings.Repeat("a", maxSlugLength-3) + "-bc"
+	tests := []struct {
+		name    string
+		base    string
+		attempt int
+		want    string
+	}{
+		{"first", "hello", 1, "hello"},
+		{"second", "hello", 2, "hello-2"},
+		{"tenth", "hello", 10, "hello-10"},
+		{"shortened", long, 2, strings.Repeat("a", maxSlugLength-3) + "-2"},
+		{"no double dash", strings.Repeat("a", maxSlugLength-2) + "-b", 2, strings.Repeat("a", maxSlugLength-2) + "-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := numberedSlug(tt.base, tt.attempt)
+			if got != tt.want {
+				t.Errorf("numberedSlug(%q, %d) = %q, want %q", tt.base, tt.attempt, got, tt.want)
+			}
+			if len(got) > maxSlugLength {
+				t.Errorf("numberedSlug(%q, %d) is %d bytes long, want at most %d", tt.base, tt.attempt, len(got), maxSlugLength)
+			}
+		})
+	}
+}
+
+func TestCreateBlogNumbersSlugs(t *testing.T) {
+	s := newTestServer(t)
+	var slugs []string
+	for i := 0; i < 3; i++ {
+		blog := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Hello World", Content: "content"})
+		slugs = append(slugs, blog.GetSlug())
+	}
+	want := []string{"hello-world", "hello-world-2", "hello-world-3"}
+	if !reflect.DeepEqual(slugs, want) {
+		t.Errorf("slugs = %q, want %q", slugs, want)
+	}
+
+	//a slug sent by the client is never renumbered
+	_, err := s.CreateBlog(context.Background(), &blogpb.CreateBlogRequest{Blog: &blogpb.Blog{
+		AuthorId: "peter", Title: "Other", Content: "content", Slug: "hello-world",
+	}})
+	wantCode(t, err, codes.AlreadyExists)
+}
+
+func TestCandidateSlug(t *testing.T) {
+	if got := candidateSlug("hello", maxSlugAttempts); got != numberedSlug("hello", maxSlugAttempts) {
+		t.Errorf("candidateSlug(hello, %d) = %q, want it numbered", maxSlugAttempts, got)
+	}
+	long := strings.Repeat("a", maxSlugLength)
+	for _, base := range []string{"hello", long} {
+		a, b := candidateSlug(base, maxSlugAttempts+1), candidateSlug(base, maxSlugAttempts+1)
+		if a == b {
+			t.Errorf("candidateSlug(%q, %d) returned %q twice", base, maxSlugAttempts+1, a)
+		}
+		if len(a) > maxSlugLength || !strings.HasPrefix(a, base[:5]) {
+			t.Errorf("candidateSlug(%q, %d) = %q, want a random suffix fitting %d bytes", base, maxSlugAttempts+1, a, maxSlugLength)
+		}
+	}
+}
+
+func TestCreateBlogPastNumberedSlugs(t *testing.T) {
+	s := newTestServer(t)
+	seen := map[string]bool{}
+	for i := 1; i <= maxSlugAttempts+2; i++ {
+		blog := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Hello World", Content: "content"})
+		slug := blog.GetSlug()
+		if seen[slug] {
+			t.Fatalf("blog %d got the slug %q of an earlier one", i, slug)
+		}
+		seen[slug] = true
+		if i > maxSlugAttempts && !strings.HasPrefix(slug, "hello-world-") {
+			t.Errorf("blog %d got slug %q, want hello-world- with a random suffix", i, slug)
+		}
+	}
+}
+
+func TestBatchCreateBlogsPastNumberedSlugs(t *testing.T) {
+	s := newTestServer(t)
+	createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Hello World", Content: "content"})
+	stream := &batchCreateStream{}
+	for i := 0; i < maxSlugAttempts+2; i++ {
+		stream.reqs = append(stream.reqs, &blogpb.BatchCreateBlogsRequest{Blog: &blogpb.Blog{AuthorId: "peter", Title: "Hello World", Content: "content"}})
+	}
+	if err := s.BatchCreateBlogs(stream); err != nil {
+		t.Fatalf("BatchCreateBlogs failed: %v", err)
+	}
+	if got, want := stream.res.GetCreatedCount(), int32(maxSlugAttempts+2); got != want {
+		t.Fatalf("created %d blogs, want %d: %v", got, want, stream.res.GetResults())
+	}
+	seen := map[string]bool{"hello-world": true}
+	for i, result := range stream.res.GetResults() {
+		slug := result.GetBlog().GetSlug()
+		if seen[slug] {
+			t.Errorf("blog %d got the taken slug %q", i, slug)
+		}
+		seen[slug] = true
+	}
+}
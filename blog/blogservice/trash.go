@@ -0,0 +1,36 @@
+package blogservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"go.uber.org/zap"
+)
+
+// purgeTrash permanently deletes blogs that have been in the trash for longer
+// than retention. It checks once at start up and then every hour, or more
+// often when the retention itself is shorter, until ctx is cancelled.
+func purgeTrash(ctx context.Context, store blogstore.BlogStore, retention time.Duration) {
+	interval := time.Hour
+	if retention < interval {
+		interval = retention
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := store.Purge(ctx, time.Now().Add(-retention))
+		if err != nil {
+			zap.L().Error("Error when purging the trash", zap.Error(err))
+		} else if purged > 0 {
+			zap.L().Info("Purged blogs from the trash", zap.Int("blogs", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package blogservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// updatableFields are the Blog fields an update mask may name. Everything
+// else on Blog is maintained by the server.
+var updatableFields = map[string]func(dst *blogstore.Blog, src *blogpb.Blog){
+	"author_id": func(dst *blogstore.Blog, src *blogpb.Blog) { dst.AuthorID = src.GetAuthorId() },
+	"title":     func(dst *blogstore.Blog, src *blogpb.Blog) { dst.Title = src.GetTitle() },
+	"content":   func(dst *blogstore.Blog, src *blogpb.Blog) { dst.Content = src.GetContent() },
+	"tags":      func(dst *blogstore.Blog, src *blogpb.Blog) { dst.Tags = src.GetTags() },
+	"category":  func(dst *blogstore.Blog, src *blogpb.Blog) { dst.Category = src.GetCategory() },
+	//an empty slug keeps the current one, see BlogStore.Update
+	"slug": func(dst *blogstore.Blog, src *blogpb.Blog) { dst.Slug = src.GetSlug() },
+}
+
+// maxPatchAttempts bounds how often patchBlog retries when another write
+// lands between its read and its update.
+const maxPatchAttempts = 3
+
+// updateMaskPaths validates the mask of an UpdateBlogRequest and returns its
+// normalized paths.
+func updateMaskPaths(mask *fieldmaskpb.FieldMask) ([]string, error) {
+	mask = proto.Clone(mask).(*fieldmaskpb.FieldMask)
+	mask.Normalize()
+	for _, path := range mask.GetPaths() {
+		if _, ok := updatableFields[path]; !ok {
+			return nil, fmt.Errorf("unknown or read-only field %q", path)
+		}
+	}
+	return mask.GetPaths(), nil
+}
+
+// patchBlog changes only the masked fields of a stored blog. The read and the
+// update are tied together by the version, so a concurrent write to the same
+// blog is never overwritten: without an expected version from the client the
+// patch is simply retried on the newer blog.
+func (s *server) patchBlog(ctx context.Context, blog *blogpb.Blog, paths []string, expectedVersion int64, modifiedBy string) (*blogstore.Blog, error) {
+	for attempt := 1; ; attempt++ {
+		current, err := s.store.Read(ctx, blog.GetId())
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.checkAuthor(ctx, current.AuthorID); err != nil {
+			return nil, err
+		}
+
+		version := expectedVersion
+		if version == 0 {
+			version = current.Version
+		}
+		patched := *current
+		for _, path := range paths {
+			updatableFields[path](&patched, blog)
+		}
+		if patched.AuthorID != current.AuthorID {
+			if err := s.checkAuthor(ctx, patched.AuthorID); err != nil {
+				return nil, err
+			}
+		}
+		patched.LastModifiedBy = modifiedBy
+
+		updated, err := s.store.Update(ctx, &patched, version)
+		if errors.Is(err, blogstore.ErrVersionMismatch) && expectedVersion == 0 && attempt < maxPatchAttempts {
+			continue
+		}
+		return updated, err
+	}
+}
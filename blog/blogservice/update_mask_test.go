@@ -0,0 +1,135 @@
+package blogservice
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestUpdateMaskPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		want    []string
+		wantErr bool
+	}{
+		{"one field", []string{"title"}, []string{"title"}, false},
+		{"sorted and deduplicated", []string{"title", "content", "title"}, []string{"content", "title"}, false},
+		{"every field", []string{"author_id", "title", "content", "tags", "category", "slug"}, []string{"author_id", "category", "content", "slug", "tags", "title"}, false},
+		{"read-only field", []string{"title", "version"}, nil, true},
+		{"unknown field", []string{"summary"}, nil, true},
+		{"nested path", []string{"title.text"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mask := &fieldmaskpb.FieldMask{Paths: tt.paths}
+			got, err := updateMaskPaths(mask)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("updateMaskPaths(%q) failed with %v, want error %v", tt.paths, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("updateMaskPaths(%q) = %q, want %q", tt.paths, got, tt.want)
+			}
+			//the request's own mask is left alone
+			if len(mask.GetPaths()) != len(tt.paths) {
+				t.Errorf("updateMaskPaths(%q) changed the mask to %q", tt.paths, mask.GetPaths())
+			}
+		})
+	}
+}
+
+func TestUpdateBlogMask(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		blog     *blogpb.Blog
+		paths    []string
+		want     *blogpb.Blog
+		wantCode codes.Code
+	}{
+		{
+			name:  "title only",
+			blog:  &blogpb.Blog{Title: "New title", Content: "ignored"},
+			paths: []string{"title"},
+			want:  &blogpb.Blog{AuthorId: "peter", Title: "New title", Content: "content", Tags: []string{"go"}, Category: "news", Slug: "title"},
+		},
+		{
+			name:  "clear tags",
+			paths: []string{"tags", "category"},
+			want:  &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content", Slug: "title"},
+		},
+		{
+			name:  "normalized tags",
+			blog:  &blogpb.Blog{Tags: []string{"Go", " gRPC "}},
+			paths: []string{"tags"},
+			want:  &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content", Tags: []string{"go", "grpc"}, Category: "news", Slug: "title"},
+		},
+		{
+			//only masked fields need to be valid
+			name:  "invalid field outside the mask",
+			blog:  &blogpb.Blog{Content: "new content", Slug: "Not A Slug"},
+			paths: []string{"content"},
+			want:  &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "new content", Tags: []string{"go"}, Category: "news", Slug: "title"},
+		},
+		{
+			name:     "invalid masked field",
+			blog:     &blogpb.Blog{Title: " "},
+			paths:    []string{"title"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "read-only field",
+			blog:     &blogpb.Blog{Version: 7},
+			paths:    []string{"version"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			stored := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content", Tags: []string{"go"}, Category: "news"})
+			blog := &blogpb.Blog{}
+			if tt.blog != nil {
+				blog = tt.blog
+			}
+			blog.Id = stored.GetId()
+
+			res, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: blog, UpdateMask: &fieldmaskpb.FieldMask{Paths: tt.paths}})
+			if tt.wantCode != codes.OK {
+				wantCode(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateBlog failed: %v", err)
+			}
+			got := res.GetBlog()
+			if got.GetVersion() != 2 {
+				t.Errorf("updated version = %d, want 2", got.GetVersion())
+			}
+			if got.GetAuthorId() != tt.want.GetAuthorId() || got.GetTitle() != tt.want.GetTitle() ||
+				got.GetContent() != tt.want.GetContent() || got.GetCategory() != tt.want.GetCategory() ||
+				got.GetSlug() != tt.want.GetSlug() || !reflect.DeepEqual(got.GetTags(), tt.want.GetTags()) {
+				t.Errorf("UpdateBlog() = %v, want the fields of %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateBlogMaskVersion(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	stored := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content"})
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"title"}}
+
+	_, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: stored.GetId(), Title: "New"}, UpdateMask: mask, ExpectedVersion: 2})
+	wantCode(t, err, codes.Aborted)
+	if _, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: stored.GetId(), Title: "New"}, UpdateMask: mask, ExpectedVersion: 1}); err != nil {
+		t.Fatalf("UpdateBlog failed: %v", err)
+	}
+	_, err = s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: "5f0000000000000000000000", Title: "New"}, UpdateMask: mask})
+	wantCode(t, err, codes.NotFound)
+}
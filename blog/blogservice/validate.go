@@ -0,0 +1,267 @@
+package blogservice
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"golang.org/x/text/unicode/norm"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// stringRule declares the checks for a string field. Values are normalized
+// to NFC, and trimmed if asked, before they are checked.
+type stringRule struct {
+	trim     bool
+	required bool
+	// trimmedForRequired rejects a required value that is only whitespace,
+	// without trimming the value that is kept, e.g. indented content.
+	trimmedForRequired bool
+	// maxRunes limits the length in characters, not bytes.
+	maxRunes int
+	// pattern, when set, must match the whole value.
+	pattern     *regexp.Regexp
+	patternDesc string
+	// multiline allows line breaks and tabs, other control characters are
+	// always rejected.
+	multiline bool
+}
+
+// authorIDRule checks author ids of blogs and comments alike.
+var authorIDRule = stringRule{
+	trim:        true,
+	required:    true,
+	maxRunes:    64,
+	pattern:     regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*$`),
+	patternDesc: "must start with a letter or digit and contain only letters, digits and . _ @ -",
+}
+
+// blogRule applies a stringRule to one field of a Blog.
+type blogRule struct {
+	// field is the proto name of the field inside Blog.
+	field string
+	get   func(*blogpb.Blog) string
+	set   func(*blogpb.Blog, string)
+	stringRule
+}
+
+var blogRules = []blogRule{
+	{
+		field:      "author_id",
+		get:        (*blogpb.Blog).GetAuthorId,
+		set:        func(b *blogpb.Blog, v string) { b.AuthorId = v },
+		stringRule: authorIDRule,
+	},
+	{
+		field:      "title",
+		get:        (*blogpb.Blog).GetTitle,
+		set:        func(b *blogpb.Blog, v string) { b.Title = v },
+		stringRule: stringRule{trim: true, required: true, maxRunes: 200},
+	},
+	{
+		field:      "content",
+		get:        (*blogpb.Blog).GetContent,
+		set:        func(b *blogpb.Blog, v string) { b.Content = v },
+		stringRule: stringRule{required: true, trimmedForRequired: true, maxRunes: 100000, multiline: true},
+	},
+	{
+		field:      "category",
+		get:        (*blogpb.Blog).GetCategory,
+		set:        func(b *blogpb.Blog, v string) { b.Category = v },
+		stringRule: stringRule{trim: true, maxRunes: 64},
+	},
+	{
+		//left empty the server derives the slug from the title
+		field: "slug",
+		get:   (*blogpb.Blog).GetSlug,
+		set:   func(b *blogpb.Blog, v string) { b.Slug = v },
+		stringRule: stringRule{
+			trim:        true,
+			maxRunes:    maxSlugLength,
+			pattern:     regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`),
+			patternDesc: "must be lower case letters and digits separated by single dashes",
+		},
+	},
+}
+
+// Limits on the tags of a blog.
+const (
+	maxTags     = 20
+	maxTagRunes = 32
+)
+
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_-]*$`)
+
+// validateBlog normalizes the fields of blog in place and returns whatever
+// is wrong with them. Only the named fields are looked at, nil means all of
+// them. Violations are reported under prefix, the path of blog in its
+// request.
+func validateBlog(prefix string, blog *blogpb.Blog, fields []string) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, rule := range blogRules {
+		if fields != nil && !contains(fields, rule.field) {
+			continue
+		}
+		value, fieldViolations := rule.check(prefix+rule.field, rule.get(blog))
+		rule.set(blog, value)
+		violations = append(violations, fieldViolations...)
+	}
+	if fields == nil || contains(fields, "tags") {
+		violations = append(violations, validateTags(prefix+"tags", blog)...)
+	}
+	return violations
+}
+
+// check returns the normalized value and whatever is wrong with it. Invalid
+// UTF-8 is returned unchanged.
+func (rule stringRule) check(field, value string) (string, []*errdetails.BadRequest_FieldViolation) {
+	if !utf8.ValidString(value) {
+		return value, []*errdetails.BadRequest_FieldViolation{rpcerr.FieldViolation(field, "must be valid UTF-8")}
+	}
+	value = norm.NFC.String(value)
+	if rule.trim {
+		value = strings.TrimSpace(value)
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	if value == "" || rule.trimmedForRequired && strings.TrimSpace(value) == "" {
+		if rule.required {
+			violations = append(violations, rpcerr.FieldViolation(field, "is required"))
+		}
+		return value, violations
+	}
+	if n := utf8.RuneCountInString(value); rule.maxRunes > 0 && n > rule.maxRunes {
+		violations = append(violations, rpcerr.FieldViolation(field,
+			fmt.Sprintf("must be at most %d characters, got %d", rule.maxRunes, n)))
+	}
+	if r, ok := controlRune(value, rule.multiline); ok {
+		violations = append(violations, rpcerr.FieldViolation(field,
+			fmt.Sprintf("must not contain control character %U", r)))
+	}
+	if rule.pattern != nil && !rule.pattern.MatchString(value) {
+		violations = append(violations, rpcerr.FieldViolation(field, rule.patternDesc))
+	}
+	return value, violations
+}
+
+// validateTags normalizes the tags of blog, see normalizeTags, and checks
+// each of them.
+func validateTags(field string, blog *blogpb.Blog) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for i, tag := range blog.GetTags() {
+		if !utf8.ValidString(tag) {
+			violations = append(violations, rpcerr.FieldViolation(fmt.Sprintf("%v[%d]", field, i), "must be valid UTF-8"))
+		}
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+
+	blog.Tags = normalizeTags(blog.GetTags())
+	if len(blog.Tags) > maxTags {
+		violations = append(violations, rpcerr.FieldViolation(field,
+			fmt.Sprintf("must have at most %d tags, got %d", maxTags, len(blog.Tags))))
+	}
+	for i, tag := range blog.Tags {
+		if n := utf8.RuneCountInString(tag); n > maxTagRunes {
+			violations = append(violations, rpcerr.FieldViolation(fmt.Sprintf("%v[%d]", field, i),
+				fmt.Sprintf("must be at most %d characters, got %d", maxTagRunes, n)))
+		}
+		if !tagPattern.MatchString(tag) {
+			violations = append(violations, rpcerr.FieldViolation(fmt.Sprintf("%v[%d]", field, i),
+				fmt.Sprintf("%q must start with a letter or digit and contain only letters, digits, _ and -", tag)))
+		}
+	}
+	return violations
+}
+
+// normalizeTags lower cases tags, drops empty ones and duplicates and sorts
+// the rest, so the same set of tags is always stored the same way.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(norm.NFC.String(tag)))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// validateCreateBlog checks and normalizes a CreateBlogRequest.
+func validateCreateBlog(req *blogpb.CreateBlogRequest) error {
+	if req.GetBlog() == nil {
+		return badRequest(rpcerr.FieldViolation("blog", "is required"))
+	}
+	return badRequest(validateBlog("blog.", req.GetBlog(), nil)...)
+}
+
+// validateUpdateBlog checks and normalizes an UpdateBlogRequest. With an
+// update mask only the masked fields need to be valid, the others are not
+// written.
+func validateUpdateBlog(req *blogpb.UpdateBlogRequest, paths []string) error {
+	if req.GetBlog() == nil {
+		return badRequest(rpcerr.FieldViolation("blog", "is required"))
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	if req.GetBlog().GetId() == "" {
+		violations = append(violations, rpcerr.FieldViolation("blog.id", "is required"))
+	}
+	if req.GetExpectedVersion() < 0 {
+		violations = append(violations, rpcerr.FieldViolation("expected_version", "cannot be negative"))
+	}
+	violations = append(violations, validateBlog("blog.", req.GetBlog(), paths)...)
+	return badRequest(violations...)
+}
+
+// validateCreateComment checks and normalizes a CreateCommentRequest.
+func validateCreateComment(req *blogpb.CreateCommentRequest) error {
+	comment := req.GetComment()
+	if comment == nil {
+		return badRequest(rpcerr.FieldViolation("comment", "is required"))
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	if comment.GetBlogId() == "" {
+		violations = append(violations, rpcerr.FieldViolation("comment.blog_id", "is required"))
+	}
+	var authorViolations, contentViolations []*errdetails.BadRequest_FieldViolation
+	comment.AuthorId, authorViolations = authorIDRule.check("comment.author_id", comment.GetAuthorId())
+	comment.Content, contentViolations = commentContentRule.check("comment.content", comment.GetContent())
+	violations = append(violations, authorViolations...)
+	violations = append(violations, contentViolations...)
+	return badRequest(violations...)
+}
+
+var commentContentRule = stringRule{trim: true, required: true, maxRunes: 10000, multiline: true}
+
+// controlRune returns the first control character in s. Multiline text may
+// contain line breaks and tabs.
+func controlRune(s string, multiline bool) (rune, bool) {
+	for _, r := range s {
+		if multiline && (r == '\n' || r == '\r' || r == '\t') {
+			continue
+		}
+		if unicode.IsControl(r) {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package blogservice
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// violatedFields returns the fields err reports violations for, sorted.
+func violatedFields(err error) []string {
+	var fields []string
+	for field := range rpcerr.FieldViolations(err) {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestValidateCreateBlog(t *testing.T) {
+	valid := func() *blogpb.Blog {
+		return &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content"}
+	}
+	tests := []struct {
+		name   string
+		change func(*blogpb.Blog)
+		want   []string
+	}{
+		{"valid", func(*blogpb.Blog) {}, nil},
+		{"missing fields", func(b *blogpb.Blog) { *b = blogpb.Blog{} }, []string{"blog.author_id", "blog.content", "blog.title"}},
+		{"blank title", func(b *blogpb.Blog) { b.Title = "  \t" }, []string{"blog.title"}},
+		{"long title", func(b *blogpb.Blog) { b.Title = strings.Repeat("é", 201) }, []string{"blog.title"}},
+		{"title of 200 characters", func(b *blogpb.Blog) { b.Title = strings.Repeat("é", 200) }, nil},
+		{"control character", func(b *blogpb.Blog) { b.Title = "bad\x00title" }, []string{"blog.title"}},
+		{"line breaks in content", func(b *blogpb.Blog) { b.Content = "one\r\ntwo\tthree" }, nil},
+		{"blank content", func(b *blogpb.Blog) { b.Content = " \n\t " }, []string{"blog.content"}},
+		{"line break in title", func(b *blogpb.Blog) { b.Title = "one\ntwo" }, []string{"blog.title"}},
+		{"invalid utf-8", func(b *blogpb.Blog) { b.Content = "\xff" }, []string{"blog.content"}},
+		{"author id", func(b *blogpb.Blog) { b.AuthorId = "-peter" }, []string{"blog.author_id"}},
+		{"email as author id", func(b *blogpb.Blog) { b.AuthorId = "peter.y@example.com" }, nil},
+		{"slug", func(b *blogpb.Blog) { b.Slug = "Not-A-Slug" }, []string{"blog.slug"}},
+		{"slug with double dash", func(b *blogpb.Blog) { b.Slug = "a--b" }, []string{"blog.slug"}},
+		//tags are checked once sorted, -bad comes first
+		{"tags", func(b *blogpb.Blog) { b.Tags = []string{"go", "-bad", "good_tag"} }, []string{"blog.tags[0]"}},
+		{"long tag", func(b *blogpb.Blog) { b.Tags = []string{strings.Repeat("t", maxTagRunes+1)} }, []string{"blog.tags[0]"}},
+		{"too many tags", func(b *blogpb.Blog) {
+			for i := 0; i <= maxTags; i++ {
+				b.Tags = append(b.Tags, strings.Repeat("t", i+1))
+			}
+		}, []string{"blog.tags"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blog := valid()
+			tt.change(blog)
+			err := validateCreateBlog(&blogpb.CreateBlogRequest{Blog: blog})
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("validateCreateBlog() failed: %v", err)
+				}
+				return
+			}
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("validateCreateBlog() = %v, want InvalidArgument", err)
+			}
+			if got := violatedFields(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations of %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCreateBlogNormalizes(t *testing.T) {
+	//e followed by a combining acute accent becomes é
+	blog := &blogpb.Blog{AuthorId: " peter ", Title: "  Cafe\u0301 ", Content: "  content\n", Category: " news ", Tags: []string{"Go", "go", " ", "gRPC"}}
+	if err := validateCreateBlog(&blogpb.CreateBlogRequest{Blog: blog}); err != nil {
+		t.Fatalf("validateCreateBlog() failed: %v", err)
+	}
+	want := &blogpb.Blog{AuthorId: "peter", Title: "Caf\u00e9", Content: "  content\n", Category: "news", Tags: []string{"go", "grpc"}}
+	if blog.GetAuthorId() != want.GetAuthorId() || blog.GetTitle() != want.GetTitle() || blog.GetContent() != want.GetContent() ||
+		blog.GetCategory() != want.GetCategory() || !reflect.DeepEqual(blog.GetTags(), want.GetTags()) {
+		t.Errorf("normalized blog = %v, want %v", blog, want)
+	}
+}
+
+func TestValidateUpdateBlog(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   *blogpb.UpdateBlogRequest
+		paths []string
+		want  []string
+	}{
+		{"no blog", &blogpb.UpdateBlogRequest{}, nil, []string{"blog"}},
+		{"no id", &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{AuthorId: "peter", Title: "Title", Content: "content"}}, nil, []string{"blog.id"}},
+		{"negative version", &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: "x", AuthorId: "peter", Title: "Title", Content: "content"}, ExpectedVersion: -1}, nil, []string{"expected_version"}},
+		{"masked", &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: "x", Title: "Title"}}, []string{"title"}, nil},
+		{"masked and missing", &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: "x", Title: "Title"}}, []string{"content", "title"}, []string{"blog.content"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUpdateBlog(tt.req, tt.paths)
+			if got := violatedFields(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations of %v (%v), want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCreateComment(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment *blogpb.Comment
+		want    []string
+	}{
+		{"valid", &blogpb.Comment{BlogId: "x", AuthorId: "anna", Content: "comment"}, nil},
+		{"missing fields", &blogpb.Comment{}, []string{"comment.author_id", "comment.blog_id", "comment.content"}},
+		{"blank content", &blogpb.Comment{BlogId: "x", AuthorId: "anna", Content: " \n "}, []string{"comment.content"}},
+		{"long content", &blogpb.Comment{BlogId: "x", AuthorId: "anna", Content: strings.Repeat("a", 10001)}, []string{"comment.content"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCreateComment(&blogpb.CreateCommentRequest{Comment: tt.comment})
+			if got := violatedFields(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations of %v (%v), want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package blogservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogstore"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var eventTypes = map[blogstore.EventType]blogpb.WatchBlogsResponse_EventType{
+	blogstore.EventCreated: blogpb.WatchBlogsResponse_CREATED,
+	blogstore.EventUpdated: blogpb.WatchBlogsResponse_UPDATED,
+	blogstore.EventDeleted: blogpb.WatchBlogsResponse_DELETED,
+}
+
+func (s *server) WatchBlogs(req *blogpb.WatchBlogsRequest, stream blogpb.BlogService_WatchBlogsServer) error {
+	logging.FromContext(stream.Context()).Debug("Watching blogs", zap.String("resume_token", req.GetResumeToken()))
+
+	//a watch never ends on its own, the server ends it when shutting down
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.draining:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := s.store.Watch(ctx, req.GetResumeToken(), func(event *blogstore.BlogEvent) error {
+		return stream.Send(&blogpb.WatchBlogsResponse{
+			Type:        eventTypes[event.Type],
+			Blog:        eventBlogPb(event.Blog),
+			ResumeToken: event.ResumeToken,
+			EventTime:   timestamppb.New(event.Time),
+		})
+	})
+	switch {
+	case stream.Context().Err() != nil:
+		//the client went away or its deadline passed
+		return status.FromContextError(stream.Context().Err()).Err()
+	case ctx.Err() != nil:
+		return rpcerr.New(codes.Unavailable, errorDomain, reasonShuttingDown,
+			"Server is shutting down, watch again with the last resume_token")
+	case errors.Is(err, blogstore.ErrInvalidResumeToken):
+		return rpcerr.BadRequest(errorDomain, reasonInvalidResumeToken,
+			rpcerr.FieldViolation("resume_token", err.Error()))
+	case errors.Is(err, blogstore.ErrResumeTokenExpired):
+		return rpcerr.New(codes.OutOfRange, errorDomain, reasonResumeTokenExpired,
+			fmt.Sprintf("Cannot resume watching blogs, list them again and start a new watch: %v", err))
+	case err != nil:
+		return internalError(err)
+	}
+	return nil
+}
+
+// eventBlogPb is dataToBlogPb for the blog of an event, which may carry no
+// more than an id when the blog was removed for good.
+func eventBlogPb(data *blogstore.Blog) *blogpb.Blog {
+	if data.Version == 0 {
+		return &blogpb.Blog{Id: data.ID}
+	}
+	return dataToBlogPb(data)
+}
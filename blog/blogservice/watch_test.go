@@ -0,0 +1,132 @@
+package blogservice
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// watchStream hands what WatchBlogs sends to a channel.
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *blogpb.WatchBlogsResponse
+}
+
+func (s *watchStream) Context() context.Context { return s.ctx }
+
+func (s *watchStream) Send(res *blogpb.WatchBlogsResponse) error {
+	select {
+	case s.sent <- res:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// startWatch runs WatchBlogs from resumeToken until the returned cancel is
+// called. The error it ended with arrives on done.
+func startWatch(ctx context.Context, s *server, resumeToken string) (stream *watchStream, done <-chan error, cancel context.CancelFunc) {
+	ctx, cancel = context.WithCancel(ctx)
+	stream = &watchStream{ctx: ctx, sent: make(chan *blogpb.WatchBlogsResponse, 10)}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.WatchBlogs(&blogpb.WatchBlogsRequest{ResumeToken: resumeToken}, stream)
+	}()
+	return stream, errc, cancel
+}
+
+// receive returns what the watch sends next.
+func (s *watchStream) receive(t *testing.T) *blogpb.WatchBlogsResponse {
+	t.Helper()
+	select {
+	case res := <-s.sent:
+		return res
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+// watchEnd returns the error the watch ended with.
+func watchEnd(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not end")
+		return nil
+	}
+}
+
+func TestWatchBlogs(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	first := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "First", Content: "content"})
+
+	//a first watch hands out a resume token, a second one resumes from it
+	stream, done, cancel := startWatch(ctx, s, "")
+	defer cancel()
+	//it has started once it sees a blog created after it
+	var token string
+	deadline := time.After(5 * time.Second)
+	for token == "" {
+		probe := createTestBlog(t, s, &blogpb.Blog{AuthorId: "peter", Title: "Probe", Content: "content"})
+		retry := time.After(50 * time.Millisecond)
+	wait:
+		for {
+			select {
+			case res := <-stream.sent:
+				if res.GetBlog().GetId() == probe.GetId() {
+					token = res.GetResumeToken()
+					break wait
+				}
+			case <-retry:
+				break wait
+			case <-deadline:
+				t.Fatal("watch did not start")
+			}
+		}
+	}
+	cancel()
+	wantCode(t, watchEnd(t, done), codes.Canceled)
+
+	if _, err := s.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: first.GetId()}); err != nil {
+		t.Fatalf("DeleteBlog failed: %v", err)
+	}
+	stream, done, cancel = startWatch(ctx, s, token)
+	defer cancel()
+	res := stream.receive(t)
+	if res.GetType() != blogpb.WatchBlogsResponse_DELETED || res.GetBlog().GetId() != first.GetId() || res.GetEventTime() == nil {
+		t.Errorf("event = %v, want the delete of %s", res, first.GetId())
+	}
+
+	//draining the server ends the watch, the client may resume elsewhere
+	close(s.draining)
+	wantCode(t, watchEnd(t, done), codes.Unavailable)
+}
+
+func TestWatchBlogsResumeTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  codes.Code
+	}{
+		{"malformed", "!!!", codes.InvalidArgument},
+		{"other server", base64.RawURLEncoding.EncodeToString([]byte("0011223344556677.1")), codes.OutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			_, done, cancel := startWatch(context.Background(), s, tt.token)
+			defer cancel()
+			wantCode(t, watchEnd(t, done), tt.want)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package blogstore
+
+import "fmt"
+
+// BatchResult is the outcome for one item of a batch call, at the same
+// index as the item in the request.
+type BatchResult struct {
+	// Blog is the blog as it was written or read, nil when Err is set.
+	Blog *Blog
+	Err  error
+}
+
+// BatchError is returned when an atomic batch was not written at all
+// because of the item at Index.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// DeleteManyOptions configures DeleteMany.
+type DeleteManyOptions struct {
+	// Atomic deletes every blog or, if any of them can't be deleted, none.
+	Atomic bool
+	// Soft moves the blogs to the trash like SoftDelete instead of removing
+	// them, ModifiedBy is then recorded as their LastModifiedBy.
+	Soft       bool
+	ModifiedBy string
+}
+
+// firstSlugConflict returns the first blog whose slug is taken, either by a
+// stored blog or by an earlier blog of the batch.
+func firstSlugConflict(blogs []*Blog, taken func(slug string) bool) (int, error) {
+	seen := make(map[string]bool, len(blogs))
+	for i, blog := range blogs {
+		if blog.Slug == "" {
+			continue
+		}
+		if seen[blog.Slug] || taken(blog.Slug) {
+			return i, &SlugTakenError{Slug: blog.Slug}
+		}
+		seen[blog.Slug] = true
+	}
+	return 0, nil
+}
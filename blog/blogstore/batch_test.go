@@ -0,0 +1,165 @@
+package blogstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// batchErrors returns the error of each result, nil where the item worked.
+func batchErrors(results []BatchResult) []error {
+	errs := make([]error, len(results))
+	for i, result := range results {
+		errs[i] = result.Err
+	}
+	return errs
+}
+
+// wantBatch fails the test unless every result failed with the error at its
+// index, or succeeded where that is nil.
+func wantBatch(t *testing.T, results []BatchResult, want []error) {
+	t.Helper()
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, err := range batchErrors(results) {
+		if !errors.Is(err, want[i]) {
+			t.Errorf("item %d failed with %v, want %v", i, err, want[i])
+		}
+		if err == nil && results[i].Blog == nil {
+			t.Errorf("item %d has neither a blog nor an error", i)
+		}
+	}
+}
+
+// wantBatchError fails the test unless err is a *BatchError for the item at
+// index, failing with want.
+func wantBatchError(t *testing.T, err error, index int, want error) {
+	t.Helper()
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != index || !errors.Is(err, want) {
+		t.Fatalf("got error %v, want a batch error for item %d with %v", err, index, want)
+	}
+}
+
+func TestCreateMany(t *testing.T) {
+	tests := []struct {
+		name   string
+		slugs  []string
+		atomic bool
+		want   []error
+		// wantIndex is the item an atomic batch fails on, -1 if it doesn't.
+		wantIndex int
+	}{
+		{"all created", []string{"a", "b", ""}, false, []error{nil, nil, nil}, -1},
+		{"taken slug", []string{"a", "taken", "b"}, false, []error{nil, ErrSlugTaken, nil}, -1},
+		{"repeated slug", []string{"a", "a"}, false, []error{nil, ErrSlugTaken}, -1},
+		{"atomic", []string{"a", "b"}, true, []error{nil, nil}, -1},
+		{"atomic with taken slug", []string{"a", "taken"}, true, nil, 1},
+		{"atomic with repeated slug", []string{"a", "b", "a"}, true, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, store BlogStore) {
+				ctx := context.Background()
+				mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Taken", Content: "content", Slug: "taken"})
+				blogs := make([]*Blog, len(tt.slugs))
+				for i, slug := range tt.slugs {
+					blogs[i] = &Blog{AuthorID: "peter", Title: "Title", Content: "content", Slug: slug}
+				}
+
+				results, err := store.CreateMany(ctx, blogs, tt.atomic)
+				if tt.wantIndex >= 0 {
+					wantBatchError(t, err, tt.wantIndex, ErrSlugTaken)
+					//nothing of the batch was stored
+					if got := listTitles(t, store, ListOptions{}); len(got) != 1 {
+						t.Errorf("store holds %q after a failed batch, want only the taken blog", got)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("CreateMany failed: %v", err)
+				}
+				wantBatch(t, results, tt.want)
+				for i, result := range results {
+					if result.Err != nil {
+						continue
+					}
+					read, err := store.Read(ctx, result.Blog.ID)
+					if err != nil || read.Slug != tt.slugs[i] || read.Version != 1 {
+						t.Errorf("Read of item %d = %+v, %v, want version 1 with slug %q", i, read, err, tt.slugs[i])
+					}
+					if _, err := store.ReadRevision(ctx, result.Blog.ID, 1); err != nil {
+						t.Errorf("ReadRevision of item %d failed: %v", i, err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestReadMany(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "content"})
+		results, err := store.ReadMany(context.Background(), []string{blog.ID, missingID, "bad", blog.ID})
+		if err != nil {
+			t.Fatalf("ReadMany failed: %v", err)
+		}
+		wantBatch(t, results, []error{nil, ErrNotFound, ErrInvalidID, nil})
+		if results[0].Blog.ID != blog.ID || results[3].Blog.ID != blog.ID {
+			t.Errorf("ReadMany() read %s and %s, want %s twice", results[0].Blog.ID, results[3].Blog.ID, blog.ID)
+		}
+	})
+}
+
+func TestDeleteMany(t *testing.T) {
+	tests := []struct {
+		name string
+		opts DeleteManyOptions
+		// want holds an error for a live blog, one in the trash, a missing
+		// one and an invalid id.
+		want []error
+		// wantIndex is the item an atomic batch fails on, -1 if it doesn't.
+		wantIndex int
+		wantError error
+	}{
+		{"delete", DeleteManyOptions{}, []error{nil, nil, ErrNotFound, ErrInvalidID}, -1, nil},
+		{"soft", DeleteManyOptions{Soft: true, ModifiedBy: "anna"}, []error{nil, ErrNotFound, ErrNotFound, ErrInvalidID}, -1, nil},
+		{"atomic", DeleteManyOptions{Atomic: true}, nil, 2, ErrNotFound},
+		{"atomic soft", DeleteManyOptions{Atomic: true, Soft: true}, nil, 1, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, store BlogStore) {
+				ctx := context.Background()
+				live := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Live", Content: "content"})
+				trashed := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Trashed", Content: "content"})
+				if _, err := store.SoftDelete(ctx, trashed.ID, 0, "peter"); err != nil {
+					t.Fatalf("SoftDelete failed: %v", err)
+				}
+
+				results, err := store.DeleteMany(ctx, []string{live.ID, trashed.ID, missingID, "bad"}, tt.opts)
+				if tt.wantIndex >= 0 {
+					wantBatchError(t, err, tt.wantIndex, tt.wantError)
+					if read, err := store.Read(ctx, live.ID); err != nil || !read.DeletedAt.IsZero() {
+						t.Errorf("Read() = %+v, %v, want the blog untouched by the failed batch", read, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("DeleteMany failed: %v", err)
+				}
+				wantBatch(t, results, tt.want)
+
+				read, err := store.Read(ctx, live.ID)
+				if tt.opts.Soft {
+					if err != nil || read.DeletedAt.IsZero() || read.LastModifiedBy != "anna" {
+						t.Errorf("Read() = %+v, %v, want the blog trashed by anna", read, err)
+					}
+				} else {
+					wantErr(t, err, ErrNotFound)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,779 @@
+package blogstore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	blogBucket = []byte("blogs")
+	// revisionBucket holds one nested bucket per blog id, keyed by version.
+	revisionBucket = []byte("revisions")
+	// slugBucket maps slugs to blog ids.
+	slugBucket = []byte("slugs")
+	// commentBucket holds the comments of every blog, keyed by comment id.
+	commentBucket = []byte("comments")
+)
+
+// BoltStore keeps blogs in a single bolt database file, one JSON document per
+// key. It needs no external service but survives restarts. The search index
+// is kept in memory and rebuilt from the file on open.
+type BoltStore struct {
+	db     *bolt.DB
+	index  *searchIndex
+	events *eventBus
+	// writeMu serializes the writes from their transaction to their events,
+	// see update.
+	writeMu sync.Mutex
+}
+
+// NewBoltStore opens (or creates) the bolt database at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt database %q: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{blogBucket, revisionBucket, slugBucket, commentBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating bolt bucket: %w", err)
+	}
+
+	store := &BoltStore{db: db, index: newSearchIndex(), events: newEventBus()}
+	// List skips blogs in the trash, which must not be searchable anyway
+	err = store.List(context.Background(), ListOptions{}, func(blog *Blog) error {
+		store.index.put(blog)
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("building search index: %w", err)
+	}
+	return store, nil
+}
+
+func (b *BoltStore) Create(ctx context.Context, blog *Blog) (*Blog, error) {
+	stored := created(blog)
+	err := b.update(func(tx *bolt.Tx) error {
+		if err := claimSlug(tx, stored, ""); err != nil {
+			return err
+		}
+		return putBlog(tx, stored)
+	}, func() {
+		b.index.put(stored)
+		b.events.publish(EventCreated, stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (b *BoltStore) Read(ctx context.Context, id string) (*Blog, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	var stored *Blog
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		stored, err = getBlog(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (b *BoltStore) ReadBySlug(ctx context.Context, slug string) (*Blog, error) {
+	var stored *Blog
+	err := b.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(slugBucket).Get([]byte(slug))
+		if id == nil {
+			return ErrNotFound
+		}
+		var err error
+		stored, err = getBlog(tx, string(id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (b *BoltStore) Update(ctx context.Context, blog *Blog, expectedVersion int64) (*Blog, error) {
+	if _, err := parseID(blog.ID); err != nil {
+		return nil, err
+	}
+
+	stored := *blog
+	err := b.update(func(tx *bolt.Tx) error {
+		current, err := getLiveBlog(tx, stored.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(current, expectedVersion); err != nil {
+			return err
+		}
+		if stored.Slug == "" {
+			stored.Slug = current.Slug
+		}
+		if err := claimSlug(tx, &stored, current.Slug); err != nil {
+			return err
+		}
+		stored.Version = current.Version + 1
+		stored.CreatedAt = current.CreatedAt
+		stored.UpdatedAt = now()
+		return putBlog(tx, &stored)
+	}, func() {
+		b.index.put(&stored)
+		b.events.publish(EventUpdated, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (b *BoltStore) Delete(ctx context.Context, id string, expectedVersion int64) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	var deleted *Blog
+	return b.update(func(tx *bolt.Tx) error {
+		current, err := getBlog(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(current, expectedVersion); err != nil {
+			return err
+		}
+		deleted = current
+		return deleteBlog(tx, current)
+	}, func() {
+		b.index.remove(id)
+		b.events.publish(EventDeleted, deleted)
+	})
+}
+
+func (b *BoltStore) SoftDelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	return b.setDeleted(id, true, expectedVersion, modifiedBy)
+}
+
+func (b *BoltStore) Undelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	return b.setDeleted(id, false, expectedVersion, modifiedBy)
+}
+
+func (b *BoltStore) setDeleted(id string, deleted bool, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	var stored *Blog
+	err := b.update(func(tx *bolt.Tx) error {
+		current, err := getBlog(tx, id)
+		if err != nil {
+			return err
+		}
+		stored, err = trashed(current, deleted, expectedVersion, modifiedBy)
+		if err != nil {
+			return err
+		}
+		return putBlog(tx, stored)
+	}, func() {
+		if deleted {
+			b.index.remove(id)
+			b.events.publish(EventDeleted, stored)
+		} else {
+			b.index.put(stored)
+			b.events.publish(EventUpdated, stored)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (b *BoltStore) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	var purged []Blog
+	err := b.update(func(tx *bolt.Tx) error {
+		// collect first, bolt doesn't allow deleting while iterating
+		var expired []Blog
+		err := tx.Bucket(blogBucket).ForEach(func(k, v []byte) error {
+			blog := Blog{}
+			if err := json.Unmarshal(v, &blog); err != nil {
+				return fmt.Errorf("decoding blog %s: %w", k, err)
+			}
+			if !blog.DeletedAt.IsZero() && blog.DeletedAt.Before(deletedBefore) {
+				expired = append(expired, blog)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, blog := range expired {
+			if err := deleteBlog(tx, &blog); err != nil {
+				return err
+			}
+		}
+		purged = expired
+		return nil
+	}, func() {
+		for i := range purged {
+			b.index.remove(purged[i].ID)
+			b.events.publish(EventDeleted, &purged[i])
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(purged), nil
+}
+
+func (b *BoltStore) List(ctx context.Context, opts ListOptions, fn func(*Blog) error) error {
+	// read everything in one transaction, then release it before calling fn
+	var blogs []Blog
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(blogBucket).ForEach(func(k, v []byte) error {
+			blog := Blog{}
+			if err := json.Unmarshal(v, &blog); err != nil {
+				return fmt.Errorf("decoding blog %s: %w", k, err)
+			}
+			blogs = append(blogs, blog)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	blogs = applyListOptions(blogs, opts)
+	for i := range blogs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&blogs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BoltStore) ListTags(ctx context.Context, opts TagOptions) ([]TagCount, error) {
+	var blogs []Blog
+	err := b.List(ctx, ListOptions{Category: opts.Category}, func(blog *Blog) error {
+		blogs = append(blogs, *blog)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return countTags(blogs, opts), nil
+}
+
+func (b *BoltStore) Search(ctx context.Context, opts SearchOptions, fn func(*SearchResult) error) error {
+	var results []SearchResult
+	err := b.db.View(func(tx *bolt.Tx) error {
+		for _, match := range b.index.search(opts.Query) {
+			if opts.Limit > 0 && len(results) == opts.Limit {
+				break
+			}
+			blog, err := getBlog(tx, match.id)
+			if err == ErrNotFound {
+				// deleted after the index was searched
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			results = append(results, SearchResult{Blog: blog, Score: match.score})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range results {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BoltStore) ListRevisions(ctx context.Context, id string, fn func(*Blog) error) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	var revisions []Blog
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if _, err := getLiveBlog(tx, id); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(revisionBucket).Bucket([]byte(id))
+		if bucket == nil {
+			return nil
+		}
+		// versions are big endian keys, so walking backwards is newest first
+		c := bucket.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			revision := Blog{}
+			if err := json.Unmarshal(v, &revision); err != nil {
+				return fmt.Errorf("decoding revision of blog %s: %w", id, err)
+			}
+			revisions = append(revisions, revision)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range revisions {
+		if err := fn(&revisions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BoltStore) ReadRevision(ctx context.Context, id string, version int64) (*Blog, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	revision := &Blog{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if _, err := getLiveBlog(tx, id); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(revisionBucket).Bucket([]byte(id))
+		if bucket == nil {
+			return ErrRevisionNotFound
+		}
+		data := bucket.Get(versionKey(version))
+		if data == nil {
+			return ErrRevisionNotFound
+		}
+		return json.Unmarshal(data, revision)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// CreateMany writes the whole batch in one transaction. An atomic batch
+// rolls it back on the first failure, otherwise failing blogs are skipped.
+func (b *BoltStore) CreateMany(ctx context.Context, blogs []*Blog, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(blogs))
+	err := b.update(func(tx *bolt.Tx) error {
+		for i, blog := range blogs {
+			stored := created(blog)
+			//claimSlug writes nothing when it fails, so skipping is safe
+			if err := claimSlug(tx, stored, ""); err != nil {
+				if atomic {
+					return &BatchError{Index: i, Err: err}
+				}
+				results[i].Err = err
+				continue
+			}
+			if err := putBlog(tx, stored); err != nil {
+				return err
+			}
+			results[i].Blog = stored
+		}
+		return nil
+	}, func() {
+		for _, result := range results {
+			if result.Err == nil {
+				b.index.put(result.Blog)
+				b.events.publish(EventCreated, result.Blog)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (b *BoltStore) ReadMany(ctx context.Context, ids []string) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ids))
+	err := b.db.View(func(tx *bolt.Tx) error {
+		for i, id := range ids {
+			if _, err := parseID(id); err != nil {
+				results[i].Err = err
+				continue
+			}
+			stored, err := getBlog(tx, id)
+			if err != nil && err != ErrNotFound {
+				return err
+			}
+			results[i] = BatchResult{Blog: stored, Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DeleteMany runs in one transaction like CreateMany.
+func (b *BoltStore) DeleteMany(ctx context.Context, ids []string, opts DeleteManyOptions) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ids))
+	err := b.update(func(tx *bolt.Tx) error {
+		for i, id := range ids {
+			deleted, err := deleteOne(tx, id, opts)
+			if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrInvalidID) {
+				//bolt failed, the transaction can't be trusted anymore
+				return err
+			}
+			if err != nil && opts.Atomic {
+				return &BatchError{Index: i, Err: err}
+			}
+			results[i] = BatchResult{Blog: deleted, Err: err}
+		}
+		return nil
+	}, func() {
+		for _, result := range results {
+			if result.Err == nil {
+				b.index.remove(result.Blog.ID)
+				b.events.publish(EventDeleted, result.Blog)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// deleteOne deletes, or moves to the trash, one blog of a DeleteMany and
+// returns it.
+func deleteOne(tx *bolt.Tx, id string, opts DeleteManyOptions) (*Blog, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+	current, err := getBlog(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Soft {
+		return current, deleteBlog(tx, current)
+	}
+	stored, err := trashed(current, true, 0, opts.ModifiedBy)
+	if err != nil {
+		return nil, err
+	}
+	return stored, putBlog(tx, stored)
+}
+
+// update runs fn in a write transaction and, once it is committed, done,
+// which updates the search index and publishes the events of the write.
+// Holding writeMu across both keeps the events in commit order, bolt alone
+// only orders the transactions.
+func (b *BoltStore) update(fn func(*bolt.Tx) error, done func()) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if err := b.db.Update(fn); err != nil {
+		return err
+	}
+	done()
+	return nil
+}
+
+func (b *BoltStore) Watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error {
+	return b.events.watch(ctx, resumeToken, fn)
+}
+
+func (b *BoltStore) Ping(ctx context.Context) error {
+	//the database is a local file, open for as long as the store
+	return nil
+}
+
+func (b *BoltStore) Close(ctx context.Context) error {
+	return b.db.Close()
+}
+
+func (b *BoltStore) CreateComment(ctx context.Context, comment *Comment) (*Comment, error) {
+	if _, err := parseID(comment.BlogID); err != nil {
+		return nil, err
+	}
+
+	stored := *comment
+	stored.ID = newID()
+	stored.CreatedAt = now()
+	stored.ReplyCount = 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if _, err := getLiveBlog(tx, stored.BlogID); err != nil {
+			return err
+		}
+		if stored.ParentID != "" {
+			parent, err := getComment(tx, stored.ParentID)
+			if err != nil {
+				return err
+			}
+			if parent.BlogID != stored.BlogID {
+				return ErrCommentNotFound
+			}
+			parent.ReplyCount++
+			if err := putComment(tx, parent); err != nil {
+				return err
+			}
+		}
+		return putComment(tx, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (b *BoltStore) ReadComment(ctx context.Context, id string) (*Comment, error) {
+	var stored *Comment
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		stored, err = getComment(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (b *BoltStore) DeleteComment(ctx context.Context, id string) (int, error) {
+	deleted := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		root, err := getComment(tx, id)
+		if err != nil {
+			return err
+		}
+		comments, err := blogComments(tx, root.BlogID)
+		if err != nil {
+			return err
+		}
+		replies := make(map[string][]string)
+		for _, comment := range comments {
+			if comment.ParentID != "" {
+				replies[comment.ParentID] = append(replies[comment.ParentID], comment.ID)
+			}
+		}
+		ids := commentSubtree(id, replies)
+		for _, commentID := range ids {
+			if err := tx.Bucket(commentBucket).Delete([]byte(commentID)); err != nil {
+				return err
+			}
+		}
+		deleted = len(ids)
+
+		if root.ParentID == "" {
+			return nil
+		}
+		parent, err := getComment(tx, root.ParentID)
+		if err != nil {
+			return err
+		}
+		parent.ReplyCount--
+		return putComment(tx, parent)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (b *BoltStore) ListComments(ctx context.Context, opts CommentListOptions, fn func(*Comment) error) error {
+	if _, err := parseID(opts.BlogID); err != nil {
+		return err
+	}
+
+	var comments []Comment
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if _, err := getLiveBlog(tx, opts.BlogID); err != nil {
+			return err
+		}
+		if opts.ParentID != "" {
+			parent, err := getComment(tx, opts.ParentID)
+			if err != nil {
+				return err
+			}
+			if parent.BlogID != opts.BlogID {
+				return ErrCommentNotFound
+			}
+		}
+		var err error
+		comments, err = blogComments(tx, opts.BlogID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	comments = applyCommentListOptions(comments, opts)
+	for i := range comments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&comments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getBlog(tx *bolt.Tx, id string) (*Blog, error) {
+	data := tx.Bucket(blogBucket).Get([]byte(id))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	blog := &Blog{}
+	if err := json.Unmarshal(data, blog); err != nil {
+		return nil, fmt.Errorf("decoding blog %s: %w", id, err)
+	}
+	return blog, nil
+}
+
+// getLiveBlog is getBlog for blogs that are not in the trash.
+func getLiveBlog(tx *bolt.Tx, id string) (*Blog, error) {
+	blog, err := getBlog(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !blog.DeletedAt.IsZero() {
+		return nil, ErrNotFound
+	}
+	return blog, nil
+}
+
+// deleteBlog removes a blog, its slug and all of its revisions.
+func deleteBlog(tx *bolt.Tx, blog *Blog) error {
+	id := []byte(blog.ID)
+	revisions := tx.Bucket(revisionBucket)
+	if revisions.Bucket(id) != nil {
+		if err := revisions.DeleteBucket(id); err != nil {
+			return err
+		}
+	}
+	if blog.Slug != "" {
+		if err := tx.Bucket(slugBucket).Delete([]byte(blog.Slug)); err != nil {
+			return err
+		}
+	}
+	comments, err := blogComments(tx, blog.ID)
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		if err := tx.Bucket(commentBucket).Delete([]byte(comment.ID)); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(blogBucket).Delete(id)
+}
+
+func getComment(tx *bolt.Tx, id string) (*Comment, error) {
+	data := tx.Bucket(commentBucket).Get([]byte(id))
+	if data == nil {
+		return nil, ErrCommentNotFound
+	}
+	comment := &Comment{}
+	if err := json.Unmarshal(data, comment); err != nil {
+		return nil, fmt.Errorf("decoding comment %s: %w", id, err)
+	}
+	return comment, nil
+}
+
+func putComment(tx *bolt.Tx, comment *Comment) error {
+	data, err := json.Marshal(comment)
+	if err != nil {
+		return fmt.Errorf("encoding comment %s: %w", comment.ID, err)
+	}
+	return tx.Bucket(commentBucket).Put([]byte(comment.ID), data)
+}
+
+// blogComments returns every comment of a blog.
+func blogComments(tx *bolt.Tx, blogID string) ([]Comment, error) {
+	var comments []Comment
+	err := tx.Bucket(commentBucket).ForEach(func(k, v []byte) error {
+		comment := Comment{}
+		if err := json.Unmarshal(v, &comment); err != nil {
+			return fmt.Errorf("decoding comment %s: %w", k, err)
+		}
+		if comment.BlogID == blogID {
+			comments = append(comments, comment)
+		}
+		return nil
+	})
+	return comments, err
+}
+
+// claimSlug points the slug of blog at it, releasing oldSlug. It fails if
+// another blog already has the slug.
+func claimSlug(tx *bolt.Tx, blog *Blog, oldSlug string) error {
+	if blog.Slug == oldSlug {
+		return nil
+	}
+	slugs := tx.Bucket(slugBucket)
+	if blog.Slug != "" {
+		if owner := slugs.Get([]byte(blog.Slug)); owner != nil && string(owner) != blog.ID {
+			return &SlugTakenError{Slug: blog.Slug}
+		}
+		if err := slugs.Put([]byte(blog.Slug), []byte(blog.ID)); err != nil {
+			return err
+		}
+	}
+	if oldSlug == "" {
+		return nil
+	}
+	return slugs.Delete([]byte(oldSlug))
+}
+
+func putBlog(tx *bolt.Tx, blog *Blog) error {
+	data, err := json.Marshal(blog)
+	if err != nil {
+		return fmt.Errorf("encoding blog %s: %w", blog.ID, err)
+	}
+	if err := tx.Bucket(blogBucket).Put([]byte(blog.ID), data); err != nil {
+		return err
+	}
+
+	// every write also records the revision, in the same transaction
+	revisions, err := tx.Bucket(revisionBucket).CreateBucketIfNotExists([]byte(blog.ID))
+	if err != nil {
+		return err
+	}
+	return revisions.Put(versionKey(blog.Version), data)
+}
+
+func versionKey(version int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(version))
+	return key
+}
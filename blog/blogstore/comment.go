@@ -0,0 +1,88 @@
+package blogstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrCommentNotFound is returned when no comment exists with the requested
+// id, or when a reply names a parent comment on another blog.
+var ErrCommentNotFound = errors.New("comment not found")
+
+// Comment is the stored representation of a comment on a blog.
+type Comment struct {
+	ID     string
+	BlogID string
+	// ParentID is the comment this one replies to, empty for top level
+	// comments.
+	ParentID string
+	AuthorID string
+	Content  string
+	// CreatedAt and ReplyCount are maintained by the store.
+	CreatedAt  time.Time
+	ReplyCount int64
+}
+
+// CommentStore is the persistence layer for comments. Comments live in the
+// same store as their blogs so that deleting or purging a blog takes its
+// comments along.
+type CommentStore interface {
+	// CreateComment stores a new comment and assigns it an id. The blog must
+	// exist and not be in the trash, a parent comment must belong to the
+	// same blog.
+	CreateComment(ctx context.Context, comment *Comment) (*Comment, error)
+	// ReadComment returns the comment with the given id.
+	ReadComment(ctx context.Context, id string) (*Comment, error)
+	// DeleteComment removes a comment together with every reply below it and
+	// returns how many comments were removed.
+	DeleteComment(ctx context.Context, id string) (int, error)
+	// ListComments calls fn for the comments of a blog with the given parent,
+	// oldest first, stopping at the first error.
+	ListComments(ctx context.Context, opts CommentListOptions, fn func(*Comment) error) error
+}
+
+// CommentListOptions selects the comments returned by ListComments.
+type CommentListOptions struct {
+	BlogID string
+	// ParentID lists the replies to a comment, empty lists the top level
+	// comments.
+	ParentID string
+	// After skips every comment up to and including the one with this id.
+	After string
+	// Limit caps the number of comments returned, 0 means no limit.
+	Limit int
+}
+
+// applyCommentListOptions filters, sorts and pages comments in process.
+func applyCommentListOptions(comments []Comment, opts CommentListOptions) []Comment {
+	matched := comments[:0]
+	for _, comment := range comments {
+		if comment.BlogID != opts.BlogID || comment.ParentID != opts.ParentID {
+			continue
+		}
+		if opts.After != "" && comment.ID <= opts.After {
+			continue
+		}
+		matched = append(matched, comment)
+	}
+	// object ids start with a timestamp, so this is creation order
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].ID < matched[j].ID
+	})
+	if opts.Limit > 0 && len(matched) > opts.Limit {
+		matched = matched[:opts.Limit]
+	}
+	return matched
+}
+
+// commentSubtree returns the id of root and of every reply below it, given
+// the replies of each comment.
+func commentSubtree(root string, replies map[string][]string) []string {
+	ids := []string{root}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, replies[ids[i]]...)
+	}
+	return ids
+}
@@ -0,0 +1,133 @@
+package blogstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mustComment stores a comment and fails the test if that doesn't work.
+func mustComment(t *testing.T, store BlogStore, blogID, parentID string) *Comment {
+	t.Helper()
+	comment, err := store.CreateComment(context.Background(), &Comment{BlogID: blogID, ParentID: parentID, AuthorID: "anna", Content: "comment"})
+	if err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+	return comment
+}
+
+// listComments returns the ids of the comments ListComments returns for opts.
+func listComments(t *testing.T, store BlogStore, opts CommentListOptions) []string {
+	t.Helper()
+	ids := []string{}
+	err := store.ListComments(context.Background(), opts, func(comment *Comment) error {
+		ids = append(ids, comment.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListComments failed: %v", err)
+	}
+	return ids
+}
+
+func TestComments(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "content"})
+		other := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Other", Content: "content"})
+		first := mustComment(t, store, blog.ID, "")
+		second := mustComment(t, store, blog.ID, "")
+		reply := mustComment(t, store, blog.ID, first.ID)
+		nested := mustComment(t, store, blog.ID, reply.ID)
+		mustComment(t, store, other.ID, "")
+
+		read, err := store.ReadComment(ctx, first.ID)
+		if err != nil {
+			t.Fatalf("ReadComment failed: %v", err)
+		}
+		if read.ReplyCount != 1 || read.BlogID != blog.ID || read.CreatedAt.IsZero() {
+			t.Errorf("ReadComment() = %+v, want a comment on %s with one reply", read, blog.ID)
+		}
+
+		tests := []struct {
+			name string
+			opts CommentListOptions
+			want []string
+		}{
+			{"top level", CommentListOptions{BlogID: blog.ID}, []string{first.ID, second.ID}},
+			{"replies", CommentListOptions{BlogID: blog.ID, ParentID: first.ID}, []string{reply.ID}},
+			{"after", CommentListOptions{BlogID: blog.ID, After: first.ID}, []string{second.ID}},
+			{"limit", CommentListOptions{BlogID: blog.ID, Limit: 1}, []string{first.ID}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := listComments(t, store, tt.opts); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ListComments() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		//a reply must stay on the blog of its parent
+		_, err = store.CreateComment(ctx, &Comment{BlogID: other.ID, ParentID: first.ID, AuthorID: "anna", Content: "comment"})
+		wantErr(t, err, ErrCommentNotFound)
+		_, err = store.CreateComment(ctx, &Comment{BlogID: missingID, AuthorID: "anna", Content: "comment"})
+		wantErr(t, err, ErrNotFound)
+		err = store.ListComments(ctx, CommentListOptions{BlogID: other.ID, ParentID: first.ID}, func(*Comment) error { return nil })
+		wantErr(t, err, ErrCommentNotFound)
+
+		//deleting a comment takes its replies along
+		n, err := store.DeleteComment(ctx, reply.ID)
+		if err != nil || n != 2 {
+			t.Fatalf("DeleteComment() = %d, %v, want 2 comments deleted", n, err)
+		}
+		_, err = store.ReadComment(ctx, nested.ID)
+		wantErr(t, err, ErrCommentNotFound)
+		if read, err := store.ReadComment(ctx, first.ID); err != nil || read.ReplyCount != 0 {
+			t.Errorf("ReadComment() = %+v, %v, want the parent without replies", read, err)
+		}
+		_, err = store.DeleteComment(ctx, reply.ID)
+		wantErr(t, err, ErrCommentNotFound)
+	})
+}
+
+func TestCommentsFollowTheirBlog(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "content"})
+		comment := mustComment(t, store, blog.ID, "")
+
+		//the trash hides the comments and takes no new ones
+		if _, err := store.SoftDelete(ctx, blog.ID, 0, "peter"); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+		err := store.ListComments(ctx, CommentListOptions{BlogID: blog.ID}, func(*Comment) error { return nil })
+		wantErr(t, err, ErrNotFound)
+		_, err = store.CreateComment(ctx, &Comment{BlogID: blog.ID, AuthorID: "anna", Content: "comment"})
+		wantErr(t, err, ErrNotFound)
+		if _, err := store.Undelete(ctx, blog.ID, 0, "peter"); err != nil {
+			t.Fatalf("Undelete failed: %v", err)
+		}
+		if got := listComments(t, store, CommentListOptions{BlogID: blog.ID}); !reflect.DeepEqual(got, []string{comment.ID}) {
+			t.Errorf("comments after undelete = %v, want %v", got, []string{comment.ID})
+		}
+
+		if err := store.Delete(ctx, blog.ID, 0); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		_, err = store.ReadComment(ctx, comment.ID)
+		wantErr(t, err, ErrCommentNotFound)
+
+		//purging the trash takes the comments along too
+		purged := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Purged", Content: "content"})
+		comment = mustComment(t, store, purged.ID, "")
+		if _, err := store.SoftDelete(ctx, purged.ID, 0, "peter"); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+		if n, err := store.Purge(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+			t.Fatalf("Purge() = %d, %v, want 1 blog purged", n, err)
+		}
+		_, err = store.ReadComment(ctx, comment.ID)
+		wantErr(t, err, ErrCommentNotFound)
+	})
+}
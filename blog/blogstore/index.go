@@ -0,0 +1,114 @@
+package blogstore
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// posting counts how often a term appears in one blog.
+type posting struct {
+	title, content int
+}
+
+// searchIndex is an in-process inverted index over blog titles and content,
+// used by the backends that have no full-text search of their own.
+type searchIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]posting // term -> blog id -> counts
+	terms    map[string][]string           // blog id -> indexed terms
+}
+
+type scoredID struct {
+	id    string
+	score float64
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[string]posting),
+		terms:    make(map[string][]string),
+	}
+}
+
+// put indexes blog, replacing whatever was indexed for it before.
+func (ix *searchIndex) put(blog *Blog) {
+	counts := map[string]posting{}
+	for _, tok := range tokenize(blog.Title) {
+		if !stopWords[tok.term] {
+			p := counts[tok.term]
+			p.title++
+			counts[tok.term] = p
+		}
+	}
+	for _, tok := range tokenize(blog.Content) {
+		if !stopWords[tok.term] {
+			p := counts[tok.term]
+			p.content++
+			counts[tok.term] = p
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(blog.ID)
+	terms := make([]string, 0, len(counts))
+	for term, p := range counts {
+		if ix.postings[term] == nil {
+			ix.postings[term] = make(map[string]posting)
+		}
+		ix.postings[term][blog.ID] = p
+		terms = append(terms, term)
+	}
+	ix.terms[blog.ID] = terms
+}
+
+// remove drops blog id from the index.
+func (ix *searchIndex) remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(id)
+}
+
+func (ix *searchIndex) removeLocked(id string) {
+	for _, term := range ix.terms[id] {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	delete(ix.terms, id)
+}
+
+// search returns the ids of the blogs matching any term of query, best match
+// first. Each term scores its weighted frequency times its inverse document
+// frequency, so rare terms count more than common ones.
+func (ix *searchIndex) search(query string) []scoredID {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	total := float64(len(ix.terms))
+	scores := map[string]float64{}
+	for _, term := range searchTerms(query) {
+		docs := ix.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		idf := math.Log(1 + total/float64(len(docs)))
+		for id, p := range docs {
+			scores[id] += float64(titleWeight*p.title+p.content) * idf
+		}
+	}
+
+	results := make([]scoredID, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, scoredID{id, score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].id < results[j].id
+	})
+	return results
+}
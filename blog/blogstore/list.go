@@ -0,0 +1,140 @@
+package blogstore
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortOrder is the order List walks blogs in.
+type SortOrder int
+
+const (
+	SortCreatedAsc SortOrder = iota
+	SortCreatedDesc
+	SortTitleAsc
+	SortTitleDesc
+)
+
+// DeletedFilter says whether List returns blogs that are in the trash.
+type DeletedFilter int
+
+const (
+	ExcludeDeleted DeletedFilter = iota
+	IncludeDeleted
+	OnlyDeleted
+)
+
+// Cursor marks the last blog a caller has already seen. List resumes right
+// after it. Title is only needed when sorting by title.
+type Cursor struct {
+	ID    string
+	Title string
+}
+
+// TimeRange matches times in [Start, End). A zero Start or End leaves that
+// side of the range open.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (r TimeRange) contains(t time.Time) bool {
+	if !r.Start.IsZero() && t.Before(r.Start) {
+		return false
+	}
+	if !r.End.IsZero() && !t.Before(r.End) {
+		return false
+	}
+	return true
+}
+
+// ListOptions filters, orders and limits the blogs returned by List.
+type ListOptions struct {
+	AuthorID    string
+	TitlePrefix string
+	Created     TimeRange
+	Updated     TimeRange
+	Deleted     DeletedFilter
+	// Tags only matches blogs that have every one of the tags.
+	Tags     []string
+	Category string
+	Order    SortOrder
+	// After skips every blog up to and including this one.
+	After *Cursor
+	// Limit caps the number of blogs returned, 0 means no limit.
+	Limit int
+}
+
+// CursorFor returns the cursor that resumes a listing after blog.
+func CursorFor(blog *Blog) *Cursor {
+	return &Cursor{ID: blog.ID, Title: blog.Title}
+}
+
+// matches reports whether blog passes the filters in opts.
+func (opts *ListOptions) matches(blog *Blog) bool {
+	if opts.Deleted == ExcludeDeleted && !blog.DeletedAt.IsZero() {
+		return false
+	}
+	if opts.Deleted == OnlyDeleted && blog.DeletedAt.IsZero() {
+		return false
+	}
+	if opts.AuthorID != "" && blog.AuthorID != opts.AuthorID {
+		return false
+	}
+	if opts.TitlePrefix != "" && !strings.HasPrefix(blog.Title, opts.TitlePrefix) {
+		return false
+	}
+	if opts.Category != "" && blog.Category != opts.Category {
+		return false
+	}
+	for _, tag := range opts.Tags {
+		if !hasTag(blog, tag) {
+			return false
+		}
+	}
+	return opts.Created.contains(blog.CreatedAt) && opts.Updated.contains(blog.UpdatedAt)
+}
+
+// less reports whether a sorts before b in the order.
+func (order SortOrder) less(a, b *Cursor) bool {
+	switch order {
+	case SortCreatedDesc:
+		return a.ID > b.ID
+	case SortTitleAsc:
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+		return a.ID < b.ID
+	case SortTitleDesc:
+		if a.Title != b.Title {
+			return a.Title > b.Title
+		}
+		return a.ID > b.ID
+	default:
+		// object ids start with a timestamp, so this is creation order
+		return a.ID < b.ID
+	}
+}
+
+// applyListOptions filters, sorts and pages blogs in process. It is used by
+// the backends that cannot push the query down to a database.
+func applyListOptions(blogs []Blog, opts ListOptions) []Blog {
+	matched := blogs[:0]
+	for i := range blogs {
+		if !opts.matches(&blogs[i]) {
+			continue
+		}
+		if opts.After != nil && !opts.Order.less(opts.After, CursorFor(&blogs[i])) {
+			continue
+		}
+		matched = append(matched, blogs[i])
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return opts.Order.less(CursorFor(&matched[i]), CursorFor(&matched[j]))
+	})
+	if opts.Limit > 0 && len(matched) > opts.Limit {
+		matched = matched[:opts.Limit]
+	}
+	return matched
+}
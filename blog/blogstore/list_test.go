@@ -0,0 +1,140 @@
+package blogstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// listTitles returns the titles of the blogs List returns for opts.
+func listTitles(t *testing.T, store BlogStore, opts ListOptions) []string {
+	t.Helper()
+	titles := []string{}
+	err := store.List(context.Background(), opts, func(blog *Blog) error {
+		titles = append(titles, blog.Title)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	return titles
+}
+
+func TestList(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		before := time.Now().Add(-time.Minute)
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Go basics", Content: "content", Tags: []string{"go"}, Category: "tutorial"})
+		mustCreate(t, store, &Blog{AuthorID: "anna", Title: "Alpha", Content: "content", Tags: []string{"go", "grpc"}, Category: "news"})
+		trashed := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Go deeper", Content: "content", Tags: []string{"go", "grpc"}})
+		mustCreate(t, store, &Blog{AuthorID: "anna", Title: "Zulu", Content: "content", Category: "tutorial"})
+		if _, err := store.SoftDelete(context.Background(), trashed.ID, 0, "peter"); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+
+		tests := []struct {
+			name string
+			opts ListOptions
+			want []string
+		}{
+			{"all", ListOptions{}, []string{"Go basics", "Alpha", "Zulu"}},
+			{"newest first", ListOptions{Order: SortCreatedDesc}, []string{"Zulu", "Alpha", "Go basics"}},
+			{"by title", ListOptions{Order: SortTitleAsc}, []string{"Alpha", "Go basics", "Zulu"}},
+			{"by title descending", ListOptions{Order: SortTitleDesc}, []string{"Zulu", "Go basics", "Alpha"}},
+			{"author", ListOptions{AuthorID: "anna"}, []string{"Alpha", "Zulu"}},
+			{"title prefix", ListOptions{TitlePrefix: "Go"}, []string{"Go basics"}},
+			{"tag", ListOptions{Tags: []string{"go"}}, []string{"Go basics", "Alpha"}},
+			{"every tag", ListOptions{Tags: []string{"go", "grpc"}}, []string{"Alpha"}},
+			{"category", ListOptions{Category: "tutorial"}, []string{"Go basics", "Zulu"}},
+			{"with trash", ListOptions{Deleted: IncludeDeleted}, []string{"Go basics", "Alpha", "Go deeper", "Zulu"}},
+			{"only trash", ListOptions{Deleted: OnlyDeleted}, []string{"Go deeper"}},
+			{"created in range", ListOptions{Created: TimeRange{Start: before, End: time.Now().Add(time.Minute)}}, []string{"Go basics", "Alpha", "Zulu"}},
+			{"created before range", ListOptions{Created: TimeRange{End: before}}, []string{}},
+			{"updated after range", ListOptions{Updated: TimeRange{Start: time.Now().Add(time.Minute)}}, []string{}},
+			{"limit", ListOptions{Limit: 2}, []string{"Go basics", "Alpha"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := listTitles(t, store, tt.opts); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("List() = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestListPages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		//equal titles are ordered by id, so pages can't skip or repeat them
+		for _, title := range []string{"b", "a", "b", "c", "a"} {
+			mustCreate(t, store, &Blog{AuthorID: "peter", Title: title, Content: "content"})
+		}
+		for _, order := range []SortOrder{SortCreatedAsc, SortCreatedDesc, SortTitleAsc, SortTitleDesc} {
+			var all []string
+			err := store.List(context.Background(), ListOptions{Order: order}, func(blog *Blog) error {
+				all = append(all, blog.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+
+			var paged []string
+			opts := ListOptions{Order: order, Limit: 2}
+			for {
+				var page []*Blog
+				err := store.List(context.Background(), opts, func(blog *Blog) error {
+					page = append(page, blog)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("List failed: %v", err)
+				}
+				for _, blog := range page {
+					paged = append(paged, blog.ID)
+				}
+				if len(page) < opts.Limit {
+					break
+				}
+				opts.After = CursorFor(page[len(page)-1])
+			}
+			if !reflect.DeepEqual(paged, all) {
+				t.Errorf("pages in order %d = %v, want %v", order, paged, all)
+			}
+		}
+	})
+}
+
+func TestListTags(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "One", Content: "content", Tags: []string{"go", "grpc"}, Category: "news"})
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Two", Content: "content", Tags: []string{"go", "bolt"}})
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Three", Content: "content", Tags: []string{"mongo"}})
+		trashed := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Four", Content: "content", Tags: []string{"mongo", "mongo-driver"}})
+		if _, err := store.SoftDelete(context.Background(), trashed.ID, 0, "peter"); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+
+		tests := []struct {
+			name string
+			opts TagOptions
+			want []TagCount
+		}{
+			{"all", TagOptions{}, []TagCount{{"go", 2}, {"bolt", 1}, {"grpc", 1}, {"mongo", 1}}},
+			{"category", TagOptions{Category: "news"}, []TagCount{{"go", 1}, {"grpc", 1}}},
+			{"unknown category", TagOptions{Category: "none"}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := store.ListTags(context.Background(), tt.opts)
+				if err != nil {
+					t.Fatalf("ListTags failed: %v", err)
+				}
+				//no tags may come back as nil or as an empty slice
+				if (len(got) != 0 || len(tt.want) != 0) && !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ListTags() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,504 @@
+package blogstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps blogs in a map. Everything is lost when the process exits,
+// which makes it handy for local runs and tests.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	blogs     map[string]Blog
+	revisions map[string][]Blog // oldest first
+	slugs     map[string]string // slug to blog id
+	comments  map[string]Comment
+	index     *searchIndex
+	events    *eventBus
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		blogs:     make(map[string]Blog),
+		revisions: make(map[string][]Blog),
+		slugs:     make(map[string]string),
+		comments:  make(map[string]Comment),
+		index:     newSearchIndex(),
+		events:    newEventBus(),
+	}
+}
+
+func (m *MemoryStore) Create(ctx context.Context, blog *Blog) (*Blog, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.create(blog)
+}
+
+// create is Create for a caller holding the write lock.
+func (m *MemoryStore) create(blog *Blog) (*Blog, error) {
+	stored := created(blog)
+	if err := m.claimSlug(stored, ""); err != nil {
+		return nil, err
+	}
+	m.blogs[stored.ID] = *stored
+	m.revisions[stored.ID] = append(m.revisions[stored.ID], *stored)
+	m.index.put(stored)
+	m.events.publish(EventCreated, stored)
+	return stored, nil
+}
+
+func (m *MemoryStore) Read(ctx context.Context, id string) (*Blog, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored, ok := m.blogs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &stored, nil
+}
+
+func (m *MemoryStore) ReadBySlug(ctx context.Context, slug string) (*Blog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored, ok := m.blogs[m.slugs[slug]]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &stored, nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, blog *Blog, expectedVersion int64) (*Blog, error) {
+	if _, err := parseID(blog.ID); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.blogs[blog.ID]
+	if !ok || !current.DeletedAt.IsZero() {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(&current, expectedVersion); err != nil {
+		return nil, err
+	}
+	stored := *blog
+	stored.Tags = append([]string(nil), blog.Tags...)
+	if stored.Slug == "" {
+		stored.Slug = current.Slug
+	}
+	if err := m.claimSlug(&stored, current.Slug); err != nil {
+		return nil, err
+	}
+	stored.Version = current.Version + 1
+	stored.CreatedAt = current.CreatedAt
+	stored.UpdatedAt = now()
+	m.blogs[stored.ID] = stored
+	m.revisions[stored.ID] = append(m.revisions[stored.ID], stored)
+	m.index.put(&stored)
+	m.events.publish(EventUpdated, &stored)
+	return &stored, nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, id string, expectedVersion int64) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.delete(id, expectedVersion)
+	return err
+}
+
+// delete is Delete for a caller holding the write lock. It returns the
+// removed blog.
+func (m *MemoryStore) delete(id string, expectedVersion int64) (*Blog, error) {
+	current, ok := m.blogs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(&current, expectedVersion); err != nil {
+		return nil, err
+	}
+	delete(m.blogs, id)
+	delete(m.revisions, id)
+	delete(m.slugs, current.Slug)
+	m.deleteBlogComments(id)
+	m.index.remove(id)
+	m.events.publish(EventDeleted, &current)
+	return &current, nil
+}
+
+func (m *MemoryStore) SoftDelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	return m.setDeleted(id, true, expectedVersion, modifiedBy)
+}
+
+func (m *MemoryStore) Undelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	return m.setDeleted(id, false, expectedVersion, modifiedBy)
+}
+
+func (m *MemoryStore) setDeleted(id string, deleted bool, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setDeletedLocked(id, deleted, expectedVersion, modifiedBy)
+}
+
+// setDeletedLocked is setDeleted for a caller holding the write lock.
+func (m *MemoryStore) setDeletedLocked(id string, deleted bool, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	current, ok := m.blogs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	stored, err := trashed(&current, deleted, expectedVersion, modifiedBy)
+	if err != nil {
+		return nil, err
+	}
+	m.blogs[id] = *stored
+	m.revisions[id] = append(m.revisions[id], *stored)
+	if deleted {
+		m.index.remove(id)
+		m.events.publish(EventDeleted, stored)
+	} else {
+		m.index.put(stored)
+		m.events.publish(EventUpdated, stored)
+	}
+	return stored, nil
+}
+
+func (m *MemoryStore) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	purged := 0
+	for id, blog := range m.blogs {
+		if blog.DeletedAt.IsZero() || !blog.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		delete(m.blogs, id)
+		delete(m.revisions, id)
+		delete(m.slugs, blog.Slug)
+		m.deleteBlogComments(id)
+		m.index.remove(id)
+		m.events.publish(EventDeleted, &blog)
+		purged++
+	}
+	return purged, nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, opts ListOptions, fn func(*Blog) error) error {
+	// take a snapshot so fn can run without holding the lock
+	m.mu.RLock()
+	blogs := make([]Blog, 0, len(m.blogs))
+	for _, blog := range m.blogs {
+		blogs = append(blogs, blog)
+	}
+	m.mu.RUnlock()
+
+	blogs = applyListOptions(blogs, opts)
+	for i := range blogs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&blogs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListTags(ctx context.Context, opts TagOptions) ([]TagCount, error) {
+	m.mu.RLock()
+	blogs := make([]Blog, 0, len(m.blogs))
+	for _, blog := range m.blogs {
+		blogs = append(blogs, blog)
+	}
+	m.mu.RUnlock()
+	return countTags(blogs, opts), nil
+}
+
+func (m *MemoryStore) Search(ctx context.Context, opts SearchOptions, fn func(*SearchResult) error) error {
+	m.mu.RLock()
+	var results []SearchResult
+	for _, match := range m.index.search(opts.Query) {
+		if opts.Limit > 0 && len(results) == opts.Limit {
+			break
+		}
+		blog := m.blogs[match.id]
+		results = append(results, SearchResult{Blog: &blog, Score: match.score})
+	}
+	m.mu.RUnlock()
+
+	for i := range results {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListRevisions(ctx context.Context, id string, fn func(*Blog) error) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	if current, ok := m.blogs[id]; !ok || !current.DeletedAt.IsZero() {
+		m.mu.RUnlock()
+		return ErrNotFound
+	}
+	// revisions are never modified, only appended, so sharing them is safe
+	revisions := m.revisions[id]
+	m.mu.RUnlock()
+
+	for i := len(revisions) - 1; i >= 0; i-- {
+		revision := revisions[i]
+		if err := fn(&revision); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ReadRevision(ctx context.Context, id string, version int64) (*Blog, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if current, ok := m.blogs[id]; !ok || !current.DeletedAt.IsZero() {
+		return nil, ErrNotFound
+	}
+	for _, revision := range m.revisions[id] {
+		if revision.Version == version {
+			return &revision, nil
+		}
+	}
+	return nil, ErrRevisionNotFound
+}
+
+func (m *MemoryStore) CreateComment(ctx context.Context, comment *Comment) (*Comment, error) {
+	if _, err := parseID(comment.BlogID); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if blog, ok := m.blogs[comment.BlogID]; !ok || !blog.DeletedAt.IsZero() {
+		return nil, ErrNotFound
+	}
+	var parent Comment
+	if comment.ParentID != "" {
+		var ok bool
+		parent, ok = m.comments[comment.ParentID]
+		if !ok || parent.BlogID != comment.BlogID {
+			return nil, ErrCommentNotFound
+		}
+	}
+
+	stored := *comment
+	stored.ID = newID()
+	stored.CreatedAt = now()
+	stored.ReplyCount = 0
+	m.comments[stored.ID] = stored
+	if stored.ParentID != "" {
+		parent.ReplyCount++
+		m.comments[parent.ID] = parent
+	}
+	return &stored, nil
+}
+
+func (m *MemoryStore) ReadComment(ctx context.Context, id string) (*Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored, ok := m.comments[id]
+	if !ok {
+		return nil, ErrCommentNotFound
+	}
+	return &stored, nil
+}
+
+func (m *MemoryStore) DeleteComment(ctx context.Context, id string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	root, ok := m.comments[id]
+	if !ok {
+		return 0, ErrCommentNotFound
+	}
+	replies := make(map[string][]string)
+	for _, comment := range m.comments {
+		if comment.BlogID == root.BlogID && comment.ParentID != "" {
+			replies[comment.ParentID] = append(replies[comment.ParentID], comment.ID)
+		}
+	}
+	ids := commentSubtree(id, replies)
+	for _, commentID := range ids {
+		delete(m.comments, commentID)
+	}
+	if parent, ok := m.comments[root.ParentID]; ok {
+		parent.ReplyCount--
+		m.comments[parent.ID] = parent
+	}
+	return len(ids), nil
+}
+
+func (m *MemoryStore) ListComments(ctx context.Context, opts CommentListOptions, fn func(*Comment) error) error {
+	if _, err := parseID(opts.BlogID); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	if blog, ok := m.blogs[opts.BlogID]; !ok || !blog.DeletedAt.IsZero() {
+		m.mu.RUnlock()
+		return ErrNotFound
+	}
+	if opts.ParentID != "" {
+		if parent, ok := m.comments[opts.ParentID]; !ok || parent.BlogID != opts.BlogID {
+			m.mu.RUnlock()
+			return ErrCommentNotFound
+		}
+	}
+	comments := make([]Comment, 0, len(m.comments))
+	for _, comment := range m.comments {
+		comments = append(comments, comment)
+	}
+	m.mu.RUnlock()
+
+	comments = applyCommentListOptions(comments, opts)
+	for i := range comments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&comments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteBlogComments removes every comment of a blog. The caller holds the
+// write lock.
+func (m *MemoryStore) deleteBlogComments(blogID string) {
+	for id, comment := range m.comments {
+		if comment.BlogID == blogID {
+			delete(m.comments, id)
+		}
+	}
+}
+
+// claimSlug points the slug of blog at it, releasing oldSlug. It fails if
+// another blog already has the slug. The caller holds the write lock.
+func (m *MemoryStore) claimSlug(blog *Blog, oldSlug string) error {
+	if blog.Slug == oldSlug {
+		return nil
+	}
+	if blog.Slug != "" {
+		if owner, ok := m.slugs[blog.Slug]; ok && owner != blog.ID {
+			return &SlugTakenError{Slug: blog.Slug}
+		}
+		m.slugs[blog.Slug] = blog.ID
+	}
+	delete(m.slugs, oldSlug)
+	return nil
+}
+
+func (m *MemoryStore) CreateMany(ctx context.Context, blogs []*Blog, atomic bool) ([]BatchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if atomic {
+		//slugs are the only thing that can make a create fail
+		i, err := firstSlugConflict(blogs, func(slug string) bool {
+			_, ok := m.slugs[slug]
+			return ok
+		})
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+	}
+	results := make([]BatchResult, len(blogs))
+	for i, blog := range blogs {
+		results[i].Blog, results[i].Err = m.create(blog)
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) ReadMany(ctx context.Context, ids []string) ([]BatchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	results := make([]BatchResult, len(ids))
+	for i, id := range ids {
+		if _, err := parseID(id); err != nil {
+			results[i].Err = err
+			continue
+		}
+		stored, ok := m.blogs[id]
+		if !ok {
+			results[i].Err = ErrNotFound
+			continue
+		}
+		results[i].Blog = &stored
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) DeleteMany(ctx context.Context, ids []string, opts DeleteManyOptions) ([]BatchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if opts.Atomic {
+		for i, id := range ids {
+			if err := m.checkDeletable(id, opts.Soft); err != nil {
+				return nil, &BatchError{Index: i, Err: err}
+			}
+		}
+	}
+	results := make([]BatchResult, len(ids))
+	for i, id := range ids {
+		if _, err := parseID(id); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if opts.Soft {
+			results[i].Blog, results[i].Err = m.setDeletedLocked(id, true, 0, opts.ModifiedBy)
+		} else {
+			results[i].Blog, results[i].Err = m.delete(id, 0)
+		}
+	}
+	return results, nil
+}
+
+// checkDeletable reports why the blog with the given id can't be deleted, or
+// moved to the trash when soft is set.
+func (m *MemoryStore) checkDeletable(id string, soft bool) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+	current, ok := m.blogs[id]
+	if !ok || (soft && !current.DeletedAt.IsZero()) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *MemoryStore) Watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error {
+	return m.events.watch(ctx, resumeToken, fn)
+}
+
+func (m *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,208 @@
+package blogstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The metrics recorded by the stores returned by Instrument, labelled with
+// the backend and the BlogStore method.
+var (
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "blog_store_operation_duration_seconds",
+		Help:    "Time taken by blog store operations, streaming ones until their last document.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 4, 9),
+	}, []string{"backend", "operation"})
+	operationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "blog_store_operation_errors_total",
+		Help: "Blog store operations that failed, not counting expected outcomes such as a missing blog or a version mismatch.",
+	}, []string{"backend", "operation"})
+	documentsStreamed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "blog_store_documents_streamed_total",
+		Help: "Documents handed out by the streaming blog store operations, List for ListBlog.",
+	}, []string{"backend", "operation"})
+)
+
+// expectedErrors are the errors a store returns for a request it can't
+// serve, as opposed to the store failing.
+var expectedErrors = []error{
+	ErrNotFound, ErrInvalidID, ErrVersionMismatch, ErrRevisionNotFound, ErrNotDeleted, ErrSlugTaken,
+	ErrCommentNotFound, ErrInvalidResumeToken, ErrResumeTokenExpired,
+	context.Canceled,
+}
+
+// Instrument returns store recording the latency and the failures of every
+// operation, and the documents streamed by List, Search, ListRevisions,
+// ListComments and Watch, under the given backend name.
+func Instrument(store BlogStore, backend string) BlogStore {
+	return &instrumentedStore{store: store, backend: backend}
+}
+
+type instrumentedStore struct {
+	store   BlogStore
+	backend string
+}
+
+// observe records an operation that started at start and ended with *err.
+// *fnErr, when given, is the last error of the callback of a streaming
+// operation, which is the caller failing rather than the store.
+func (s *instrumentedStore) observe(operation string, start time.Time, err, fnErr *error) {
+	operationDuration.WithLabelValues(s.backend, operation).Observe(time.Since(start).Seconds())
+	if *err == nil || (fnErr != nil && *fnErr != nil && errors.Is(*err, *fnErr)) {
+		return
+	}
+	for _, expected := range expectedErrors {
+		if errors.Is(*err, expected) {
+			return
+		}
+	}
+	operationErrors.WithLabelValues(s.backend, operation).Inc()
+}
+
+func (s *instrumentedStore) Create(ctx context.Context, blog *Blog) (_ *Blog, err error) {
+	defer s.observe("Create", time.Now(), &err, nil)
+	return s.store.Create(ctx, blog)
+}
+
+func (s *instrumentedStore) Read(ctx context.Context, id string) (_ *Blog, err error) {
+	defer s.observe("Read", time.Now(), &err, nil)
+	return s.store.Read(ctx, id)
+}
+
+func (s *instrumentedStore) ReadBySlug(ctx context.Context, slug string) (_ *Blog, err error) {
+	defer s.observe("ReadBySlug", time.Now(), &err, nil)
+	return s.store.ReadBySlug(ctx, slug)
+}
+
+func (s *instrumentedStore) Update(ctx context.Context, blog *Blog, expectedVersion int64) (_ *Blog, err error) {
+	defer s.observe("Update", time.Now(), &err, nil)
+	return s.store.Update(ctx, blog, expectedVersion)
+}
+
+func (s *instrumentedStore) Delete(ctx context.Context, id string, expectedVersion int64) (err error) {
+	defer s.observe("Delete", time.Now(), &err, nil)
+	return s.store.Delete(ctx, id, expectedVersion)
+}
+
+func (s *instrumentedStore) SoftDelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (_ *Blog, err error) {
+	defer s.observe("SoftDelete", time.Now(), &err, nil)
+	return s.store.SoftDelete(ctx, id, expectedVersion, modifiedBy)
+}
+
+func (s *instrumentedStore) Undelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (_ *Blog, err error) {
+	defer s.observe("Undelete", time.Now(), &err, nil)
+	return s.store.Undelete(ctx, id, expectedVersion, modifiedBy)
+}
+
+func (s *instrumentedStore) Purge(ctx context.Context, deletedBefore time.Time) (_ int, err error) {
+	defer s.observe("Purge", time.Now(), &err, nil)
+	return s.store.Purge(ctx, deletedBefore)
+}
+
+func (s *instrumentedStore) List(ctx context.Context, opts ListOptions, fn func(*Blog) error) (err error) {
+	var fnErr error
+	defer s.observe("List", time.Now(), &err, &fnErr)
+	streamed := documentsStreamed.WithLabelValues(s.backend, "List")
+	return s.store.List(ctx, opts, func(blog *Blog) error {
+		streamed.Inc()
+		fnErr = fn(blog)
+		return fnErr
+	})
+}
+
+func (s *instrumentedStore) ListTags(ctx context.Context, opts TagOptions) (_ []TagCount, err error) {
+	defer s.observe("ListTags", time.Now(), &err, nil)
+	return s.store.ListTags(ctx, opts)
+}
+
+func (s *instrumentedStore) Search(ctx context.Context, opts SearchOptions, fn func(*SearchResult) error) (err error) {
+	var fnErr error
+	defer s.observe("Search", time.Now(), &err, &fnErr)
+	streamed := documentsStreamed.WithLabelValues(s.backend, "Search")
+	return s.store.Search(ctx, opts, func(result *SearchResult) error {
+		streamed.Inc()
+		fnErr = fn(result)
+		return fnErr
+	})
+}
+
+func (s *instrumentedStore) ListRevisions(ctx context.Context, id string, fn func(*Blog) error) (err error) {
+	var fnErr error
+	defer s.observe("ListRevisions", time.Now(), &err, &fnErr)
+	streamed := documentsStreamed.WithLabelValues(s.backend, "ListRevisions")
+	return s.store.ListRevisions(ctx, id, func(revision *Blog) error {
+		streamed.Inc()
+		fnErr = fn(revision)
+		return fnErr
+	})
+}
+
+func (s *instrumentedStore) ReadRevision(ctx context.Context, id string, version int64) (_ *Blog, err error) {
+	defer s.observe("ReadRevision", time.Now(), &err, nil)
+	return s.store.ReadRevision(ctx, id, version)
+}
+
+func (s *instrumentedStore) CreateMany(ctx context.Context, blogs []*Blog, atomic bool) (_ []BatchResult, err error) {
+	defer s.observe("CreateMany", time.Now(), &err, nil)
+	return s.store.CreateMany(ctx, blogs, atomic)
+}
+
+func (s *instrumentedStore) ReadMany(ctx context.Context, ids []string) (_ []BatchResult, err error) {
+	defer s.observe("ReadMany", time.Now(), &err, nil)
+	return s.store.ReadMany(ctx, ids)
+}
+
+func (s *instrumentedStore) DeleteMany(ctx context.Context, ids []string, opts DeleteManyOptions) (_ []BatchResult, err error) {
+	defer s.observe("DeleteMany", time.Now(), &err, nil)
+	return s.store.DeleteMany(ctx, ids, opts)
+}
+
+func (s *instrumentedStore) Watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) (err error) {
+	var fnErr error
+	defer s.observe("Watch", time.Now(), &err, &fnErr)
+	streamed := documentsStreamed.WithLabelValues(s.backend, "Watch")
+	return s.store.Watch(ctx, resumeToken, func(event *BlogEvent) error {
+		streamed.Inc()
+		fnErr = fn(event)
+		return fnErr
+	})
+}
+
+func (s *instrumentedStore) Ping(ctx context.Context) (err error) {
+	defer s.observe("Ping", time.Now(), &err, nil)
+	return s.store.Ping(ctx)
+}
+
+func (s *instrumentedStore) Close(ctx context.Context) error {
+	return s.store.Close(ctx)
+}
+
+func (s *instrumentedStore) CreateComment(ctx context.Context, comment *Comment) (_ *Comment, err error) {
+	defer s.observe("CreateComment", time.Now(), &err, nil)
+	return s.store.CreateComment(ctx, comment)
+}
+
+func (s *instrumentedStore) ReadComment(ctx context.Context, id string) (_ *Comment, err error) {
+	defer s.observe("ReadComment", time.Now(), &err, nil)
+	return s.store.ReadComment(ctx, id)
+}
+
+func (s *instrumentedStore) DeleteComment(ctx context.Context, id string) (_ int, err error) {
+	defer s.observe("DeleteComment", time.Now(), &err, nil)
+	return s.store.DeleteComment(ctx, id)
+}
+
+func (s *instrumentedStore) ListComments(ctx context.Context, opts CommentListOptions, fn func(*Comment) error) (err error) {
+	var fnErr error
+	defer s.observe("ListComments", time.Now(), &err, &fnErr)
+	streamed := documentsStreamed.WithLabelValues(s.backend, "ListComments")
+	return s.store.ListComments(ctx, opts, func(comment *Comment) error {
+		streamed.Inc()
+		fnErr = fn(comment)
+		return fnErr
+	})
+}
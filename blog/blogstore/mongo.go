@@ -0,0 +1,1161 @@
+package blogstore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+)
+
+// MongoStore keeps blogs in the "blog" collection of a MongoDB database,
+// their revisions in "blog_revisions" and their comments in "blog_comments".
+type MongoStore struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+	revisions  *mongo.Collection
+	comments   *mongo.Collection
+
+	// indexesReady is set once the indexes created by ensureIndexes exist.
+	indexesMu    sync.Mutex
+	indexesReady bool
+}
+
+// blogItem is the document layout stored in mongo.
+type blogItem struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	AuthorID string             `bson:"author_id"`
+	Title    string             `bson:"title"`
+	Content  string             `bson:"content"`
+	Version  int64              `bson:"version"`
+
+	CreatedAt      time.Time `bson:"created_at"`
+	UpdatedAt      time.Time `bson:"updated_at"`
+	LastModifiedBy string    `bson:"last_modified_by"`
+	DeletedAt      time.Time `bson:"deleted_at,omitempty"`
+
+	Tags     []string `bson:"tags,omitempty"`
+	Category string   `bson:"category,omitempty"`
+	Slug     string   `bson:"slug,omitempty"`
+}
+
+// revisionItem is the document layout of a revision: a copy of the blog
+// document as it was written at that version.
+type revisionItem struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	BlogID  primitive.ObjectID `bson:"blog_id"`
+	Version int64              `bson:"version"`
+	Blog    blogItem           `bson:"blog"`
+}
+
+// commentItem is the document layout of a comment.
+type commentItem struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	BlogID     primitive.ObjectID `bson:"blog_id"`
+	ParentID   primitive.ObjectID `bson:"parent_id,omitempty"`
+	AuthorID   string             `bson:"author_id"`
+	Content    string             `bson:"content"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	ReplyCount int64              `bson:"reply_count"`
+}
+
+// NewMongoStore connects to the mongo server at uri and uses the given database.
+func NewMongoStore(ctx context.Context, uri string, database string) (*MongoStore, error) {
+	//every mongo command gets a span, a child of the span of the call
+	//that issued it when tracing is on
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetMonitor(otelmongo.NewMonitor()))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to mongodb: %w", err)
+	}
+	return &MongoStore{
+		client:     client,
+		collection: client.Database(database).Collection("blog"),
+		revisions:  client.Database(database).Collection("blog_revisions"),
+		comments:   client.Database(database).Collection("blog_comments"),
+	}, nil
+}
+
+func (m *MongoStore) Create(ctx context.Context, blog *Blog) (*Blog, error) {
+	//the unique slug index must exist before the first insert
+	if err := m.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+	data := newBlogItem(blog)
+	res, err := m.collection.InsertOne(ctx, data)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, &SlugTakenError{Slug: blog.Slug}
+	}
+	if err != nil {
+		return nil, err
+	}
+	oid, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %v to object id", res.InsertedID)
+	}
+	data.ID = oid
+	if err := m.writeRevision(ctx, &data); err != nil {
+		return nil, err
+	}
+	return data.toBlog(), nil
+}
+
+// newBlogItem is the document of a new blog, without an id.
+func newBlogItem(blog *Blog) blogItem {
+	data := blogItem{
+		AuthorID: blog.AuthorID,
+		Title:    blog.Title,
+		Content:  blog.Content,
+		Version:  1,
+
+		CreatedAt:      now(),
+		LastModifiedBy: blog.LastModifiedBy,
+
+		Tags:     blog.Tags,
+		Category: blog.Category,
+		Slug:     blog.Slug,
+	}
+	data.UpdatedAt = data.CreatedAt
+	return data
+}
+
+func (m *MongoStore) Read(ctx context.Context, id string) (*Blog, error) {
+	oid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &blogItem{}
+	err = m.collection.FindOne(ctx, bson.M{"_id": oid}).Decode(data)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data.toBlog(), nil
+}
+
+func (m *MongoStore) ReadBySlug(ctx context.Context, slug string) (*Blog, error) {
+	if slug == "" {
+		return nil, ErrNotFound
+	}
+	data := &blogItem{}
+	err := m.collection.FindOne(ctx, bson.M{"slug": slug}).Decode(data)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data.toBlog(), nil
+}
+
+// readLive is Read for blogs that are not in the trash.
+func (m *MongoStore) readLive(ctx context.Context, id string) (*Blog, error) {
+	blog, err := m.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !blog.DeletedAt.IsZero() {
+		return nil, ErrNotFound
+	}
+	return blog, nil
+}
+
+func (m *MongoStore) Update(ctx context.Context, blog *Blog, expectedVersion int64) (*Blog, error) {
+	oid, err := parseID(blog.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	//the version check and increment happen in a single atomic update
+	filter := versionFilter(oid, expectedVersion)
+	filter["deleted_at"] = nil
+	set := bson.M{
+		"author_id": blog.AuthorID,
+		"title":     blog.Title,
+		"content":   blog.Content,
+		"tags":      blog.Tags,
+		"category":  blog.Category,
+
+		"updated_at":       now(),
+		"last_modified_by": blog.LastModifiedBy,
+	}
+	if blog.Slug != "" {
+		set["slug"] = blog.Slug
+	}
+	update := bson.M{
+		"$set": set,
+		"$inc": bson.M{"version": 1},
+	}
+	data := &blogItem{}
+	err = m.collection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(data)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, &SlugTakenError{Slug: blog.Slug}
+	}
+	if err == mongo.ErrNoDocuments {
+		return nil, m.writeMissError(ctx, oid, expectedVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := m.writeRevision(ctx, data); err != nil {
+		return nil, err
+	}
+	return data.toBlog(), nil
+}
+
+func (m *MongoStore) Delete(ctx context.Context, id string, expectedVersion int64) error {
+	oid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.collection.DeleteOne(ctx, versionFilter(oid, expectedVersion))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return m.writeMissError(ctx, oid, expectedVersion)
+	}
+	if _, err := m.revisions.DeleteMany(ctx, bson.M{"blog_id": oid}); err != nil {
+		return fmt.Errorf("deleting revisions: %w", err)
+	}
+	if _, err := m.comments.DeleteMany(ctx, bson.M{"blog_id": oid}); err != nil {
+		return fmt.Errorf("deleting comments: %w", err)
+	}
+	return nil
+}
+
+func (m *MongoStore) SoftDelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	oid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := versionFilter(oid, expectedVersion)
+	filter["deleted_at"] = nil
+	deletedAt := now()
+	update := bson.M{
+		"$set": bson.M{
+			"deleted_at":       deletedAt,
+			"updated_at":       deletedAt,
+			"last_modified_by": modifiedBy,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	return m.writeTrash(ctx, oid, filter, update, true, expectedVersion)
+}
+
+func (m *MongoStore) Undelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	oid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := versionFilter(oid, expectedVersion)
+	filter["deleted_at"] = bson.M{"$ne": nil}
+	update := bson.M{
+		"$set": bson.M{
+			"updated_at":       now(),
+			"last_modified_by": modifiedBy,
+		},
+		"$unset": bson.M{"deleted_at": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+	return m.writeTrash(ctx, oid, filter, update, false, expectedVersion)
+}
+
+// writeTrash applies a SoftDelete or Undelete update and records the revision.
+func (m *MongoStore) writeTrash(ctx context.Context, oid primitive.ObjectID, filter bson.M, update bson.M, deleted bool, expectedVersion int64) (*Blog, error) {
+	data := &blogItem{}
+	err := m.collection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(data)
+	if err == mongo.ErrNoDocuments {
+		current, err := m.Read(ctx, oid.Hex())
+		if err != nil {
+			return nil, err
+		}
+		//trashed reports why the move can't happen
+		if _, err := trashed(current, deleted, expectedVersion, ""); err != nil {
+			return nil, err
+		}
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := m.writeRevision(ctx, data); err != nil {
+		return nil, err
+	}
+	return data.toBlog(), nil
+}
+
+func (m *MongoStore) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	filter := bson.M{"deleted_at": bson.M{"$ne": nil, "$lt": deletedBefore}}
+	cursor, err := m.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("creating cursor: %w", err)
+	}
+	var expired []primitive.ObjectID
+	for cursor.Next(ctx) {
+		expired = append(expired, cursor.Current.Lookup("_id").ObjectID())
+	}
+	cursor.Close(ctx)
+	if err := cursor.Err(); err != nil {
+		return 0, err
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	//delete revisions and comments first so a failure never leaves them
+	//without a blog
+	if _, err := m.revisions.DeleteMany(ctx, bson.M{"blog_id": bson.M{"$in": expired}}); err != nil {
+		return 0, fmt.Errorf("deleting revisions: %w", err)
+	}
+	if _, err := m.comments.DeleteMany(ctx, bson.M{"blog_id": bson.M{"$in": expired}}); err != nil {
+		return 0, fmt.Errorf("deleting comments: %w", err)
+	}
+	res, err := m.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": expired}})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+// versionFilter matches the blog with the given id, and only at
+// expectedVersion when that is not 0.
+func versionFilter(oid primitive.ObjectID, expectedVersion int64) bson.M {
+	filter := bson.M{"_id": oid}
+	if expectedVersion != 0 {
+		filter["version"] = expectedVersion
+	}
+	return filter
+}
+
+// writeMissError works out why a versioned write matched no document: either
+// the blog doesn't exist or it is at another version.
+func (m *MongoStore) writeMissError(ctx context.Context, oid primitive.ObjectID, expectedVersion int64) error {
+	current, err := m.Read(ctx, oid.Hex())
+	if err != nil {
+		return err
+	}
+	if !current.DeletedAt.IsZero() {
+		return ErrNotFound
+	}
+	if err := checkVersion(current, expectedVersion); err != nil {
+		return err
+	}
+	//the blog only reached expectedVersion after our write was rejected
+	return ErrVersionMismatch
+}
+
+func (m *MongoStore) List(ctx context.Context, opts ListOptions, fn func(*Blog) error) error {
+	filter, err := listFilter(opts)
+	if err != nil {
+		return err
+	}
+	findOpts := options.Find().SetSort(listSort(opts.Order))
+	if opts.Limit > 0 {
+		findOpts.SetLimit(int64(opts.Limit))
+	}
+
+	cursor, err := m.collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		return fmt.Errorf("creating cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		data := &blogItem{}
+		if err := cursor.Decode(data); err != nil {
+			return fmt.Errorf("decoding blog from cursor: %w", err)
+		}
+		if err := fn(data.toBlog()); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (m *MongoStore) ListTags(ctx context.Context, opts TagOptions) ([]TagCount, error) {
+	match := bson.M{"deleted_at": nil}
+	if opts.Category != "" {
+		match["category"] = opts.Category
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+	}
+	cursor, err := m.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("counting tags: %w", err)
+	}
+	defer cursor.Close(ctx)
+	var tags []TagCount
+	for cursor.Next(ctx) {
+		var group struct {
+			Tag   string `bson:"_id"`
+			Count int64  `bson:"count"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return nil, fmt.Errorf("decoding tag count: %w", err)
+		}
+		tags = append(tags, TagCount{Tag: group.Tag, Count: group.Count})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	sortTagCounts(tags)
+	return tags, nil
+}
+
+// Search uses the text index of the collection. Unlike the index of the
+// other backends it stems words, so "streams" also finds "streaming".
+func (m *MongoStore) Search(ctx context.Context, opts SearchOptions, fn func(*SearchResult) error) error {
+	if err := m.ensureIndexes(ctx); err != nil {
+		return err
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	findOpts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}})
+	if opts.Limit > 0 {
+		findOpts.SetLimit(int64(opts.Limit))
+	}
+
+	filter := bson.M{"$text": bson.M{"$search": opts.Query}, "deleted_at": nil}
+	cursor, err := m.collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		return fmt.Errorf("creating cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		data := &blogItem{}
+		if err := cursor.Decode(data); err != nil {
+			return fmt.Errorf("decoding blog from cursor: %w", err)
+		}
+		score, _ := cursor.Current.Lookup("score").DoubleOK()
+		if err := fn(&SearchResult{Blog: data.toBlog(), Score: score}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (m *MongoStore) ListRevisions(ctx context.Context, id string, fn func(*Blog) error) error {
+	oid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	if _, err := m.readLive(ctx, id); err != nil {
+		return err
+	}
+
+	cursor, err := m.revisions.Find(ctx, bson.M{"blog_id": oid},
+		options.Find().SetSort(bson.D{{Key: "version", Value: -1}}))
+	if err != nil {
+		return fmt.Errorf("creating cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		data := &revisionItem{}
+		if err := cursor.Decode(data); err != nil {
+			return fmt.Errorf("decoding revision from cursor: %w", err)
+		}
+		if err := fn(data.toBlog()); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (m *MongoStore) ReadRevision(ctx context.Context, id string, version int64) (*Blog, error) {
+	oid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.readLive(ctx, id); err != nil {
+		return nil, err
+	}
+
+	data := &revisionItem{}
+	err = m.revisions.FindOne(ctx, bson.M{"blog_id": oid, "version": version}).Decode(data)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data.toBlog(), nil
+}
+
+func (m *MongoStore) CreateComment(ctx context.Context, comment *Comment) (*Comment, error) {
+	blogOID, err := parseID(comment.BlogID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.readLive(ctx, comment.BlogID); err != nil {
+		return nil, err
+	}
+	data := commentItem{
+		BlogID:    blogOID,
+		AuthorID:  comment.AuthorID,
+		Content:   comment.Content,
+		CreatedAt: now(),
+	}
+	if comment.ParentID != "" {
+		parent, err := m.readComment(ctx, comment.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.BlogID != blogOID {
+			return nil, ErrCommentNotFound
+		}
+		data.ParentID = parent.ID
+	}
+
+	res, err := m.comments.InsertOne(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	oid, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %v to object id", res.InsertedID)
+	}
+	data.ID = oid
+	if !data.ParentID.IsZero() {
+		_, err := m.comments.UpdateByID(ctx, data.ParentID, bson.M{"$inc": bson.M{"reply_count": 1}})
+		if err != nil {
+			return nil, fmt.Errorf("counting reply: %w", err)
+		}
+	}
+	return data.toComment(), nil
+}
+
+func (m *MongoStore) ReadComment(ctx context.Context, id string) (*Comment, error) {
+	data, err := m.readComment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return data.toComment(), nil
+}
+
+func (m *MongoStore) readComment(ctx context.Context, id string) (*commentItem, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		//comment ids are only ever handed out by the store
+		return nil, ErrCommentNotFound
+	}
+	data := &commentItem{}
+	err = m.comments.FindOne(ctx, bson.M{"_id": oid}).Decode(data)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (m *MongoStore) DeleteComment(ctx context.Context, id string) (int, error) {
+	root, err := m.readComment(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	//walk the thread down one level of replies at a time
+	ids := []primitive.ObjectID{root.ID}
+	for level := ids; len(level) > 0; {
+		cursor, err := m.comments.Find(ctx, bson.M{"parent_id": bson.M{"$in": level}},
+			options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return 0, fmt.Errorf("creating cursor: %w", err)
+		}
+		var next []primitive.ObjectID
+		for cursor.Next(ctx) {
+			next = append(next, cursor.Current.Lookup("_id").ObjectID())
+		}
+		cursor.Close(ctx)
+		if err := cursor.Err(); err != nil {
+			return 0, err
+		}
+		ids = append(ids, next...)
+		level = next
+	}
+
+	res, err := m.comments.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	if !root.ParentID.IsZero() {
+		_, err := m.comments.UpdateByID(ctx, root.ParentID, bson.M{"$inc": bson.M{"reply_count": -1}})
+		if err != nil {
+			return 0, fmt.Errorf("uncounting reply: %w", err)
+		}
+	}
+	return int(res.DeletedCount), nil
+}
+
+func (m *MongoStore) ListComments(ctx context.Context, opts CommentListOptions, fn func(*Comment) error) error {
+	blogOID, err := parseID(opts.BlogID)
+	if err != nil {
+		return err
+	}
+	if err := m.ensureIndexes(ctx); err != nil {
+		return err
+	}
+	if _, err := m.readLive(ctx, opts.BlogID); err != nil {
+		return err
+	}
+
+	filter := bson.M{"blog_id": blogOID, "parent_id": nil}
+	if opts.ParentID != "" {
+		parent, err := m.readComment(ctx, opts.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent.BlogID != blogOID {
+			return ErrCommentNotFound
+		}
+		filter["parent_id"] = parent.ID
+	}
+	if opts.After != "" {
+		after, err := primitive.ObjectIDFromHex(opts.After)
+		if err != nil {
+			return ErrCommentNotFound
+		}
+		filter["_id"] = bson.M{"$gt": after}
+	}
+	findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if opts.Limit > 0 {
+		findOpts.SetLimit(int64(opts.Limit))
+	}
+
+	cursor, err := m.comments.Find(ctx, filter, findOpts)
+	if err != nil {
+		return fmt.Errorf("creating cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		data := &commentItem{}
+		if err := cursor.Decode(data); err != nil {
+			return fmt.Errorf("decoding comment from cursor: %w", err)
+		}
+		if err := fn(data.toComment()); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// CreateMany inserts the batch with a single InsertMany. An atomic batch
+// runs in a transaction, which needs mongo to run as a replica set.
+func (m *MongoStore) CreateMany(ctx context.Context, blogs []*Blog, atomic bool) ([]BatchResult, error) {
+	if len(blogs) == 0 {
+		//InsertMany refuses to insert nothing
+		return nil, nil
+	}
+	if err := m.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+	items := make([]blogItem, len(blogs))
+	docs := make([]interface{}, len(blogs))
+	for i, blog := range blogs {
+		//ids are picked here so failed inserts can be told apart
+		items[i] = newBlogItem(blog)
+		items[i].ID = primitive.NewObjectID()
+		docs[i] = items[i]
+	}
+
+	results := make([]BatchResult, len(blogs))
+	insert := func(ctx context.Context) error {
+		//a transaction may run this more than once
+		for i := range results {
+			results[i] = BatchResult{}
+		}
+		_, err := m.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(atomic))
+		//without an error bulkErr stays empty and every blog gets its revision
+		var bulkErr mongo.BulkWriteException
+		if err != nil && (!errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil) {
+			return err
+		}
+		for _, writeErr := range bulkErr.WriteErrors {
+			err := error(writeErr.WriteError)
+			if writeErr.Code == 11000 {
+				err = &SlugTakenError{Slug: blogs[writeErr.Index].Slug}
+			}
+			if atomic {
+				return &BatchError{Index: writeErr.Index, Err: err}
+			}
+			results[writeErr.Index].Err = err
+		}
+
+		var revisions []interface{}
+		for i := range items {
+			if results[i].Err == nil {
+				revisions = append(revisions, revisionItem{BlogID: items[i].ID, Version: 1, Blog: items[i]})
+			}
+		}
+		if len(revisions) == 0 {
+			return nil
+		}
+		if _, err := m.revisions.InsertMany(ctx, revisions); err != nil {
+			return fmt.Errorf("writing revisions: %w", err)
+		}
+		return nil
+	}
+	if atomic {
+		if err := m.inTransaction(ctx, insert); err != nil {
+			return nil, err
+		}
+	} else if err := insert(ctx); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		if results[i].Err == nil {
+			results[i].Blog = items[i].toBlog()
+		}
+	}
+	return results, nil
+}
+
+func (m *MongoStore) ReadMany(ctx context.Context, ids []string) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ids))
+	found, err := m.findMany(ctx, ids, results)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		if results[i].Err != nil {
+			continue
+		}
+		data, ok := found[id]
+		if !ok {
+			results[i].Err = ErrNotFound
+			continue
+		}
+		results[i].Blog = data.toBlog()
+	}
+	return results, nil
+}
+
+// findMany reads the blogs with the given ids with a single query, keyed by
+// id. Invalid ids are reported in results.
+func (m *MongoStore) findMany(ctx context.Context, ids []string, results []BatchResult) (map[string]*blogItem, error) {
+	var oids []primitive.ObjectID
+	for i, id := range ids {
+		oid, err := parseID(id)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		oids = append(oids, oid)
+	}
+	found := make(map[string]*blogItem, len(oids))
+	if len(oids) == 0 {
+		return found, nil
+	}
+	cursor, err := m.collection.Find(ctx, bson.M{"_id": bson.M{"$in": oids}})
+	if err != nil {
+		return nil, fmt.Errorf("creating cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		data := &blogItem{}
+		if err := cursor.Decode(data); err != nil {
+			return nil, fmt.Errorf("decoding blog from cursor: %w", err)
+		}
+		found[data.ID.Hex()] = data
+	}
+	return found, cursor.Err()
+}
+
+// DeleteMany removes blogs, their revisions and their comments with one
+// DeleteMany each. Moving blogs to the trash goes blog by blog, as each of
+// them gets a revision of its own. Atomic batches run in a transaction.
+func (m *MongoStore) DeleteMany(ctx context.Context, ids []string, opts DeleteManyOptions) ([]BatchResult, error) {
+	//indexes can't be created inside a transaction
+	if err := m.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult, len(ids))
+	remove := func(ctx context.Context) error {
+		//a transaction may run this more than once
+		for i := range results {
+			results[i] = BatchResult{}
+		}
+		found, err := m.findMany(ctx, ids, results)
+		if err != nil {
+			return err
+		}
+		var oids []primitive.ObjectID
+		for i, id := range ids {
+			if results[i].Err == nil && found[id] == nil {
+				results[i].Err = ErrNotFound
+			}
+			if results[i].Err != nil {
+				if opts.Atomic {
+					return &BatchError{Index: i, Err: results[i].Err}
+				}
+				continue
+			}
+
+			if opts.Soft {
+				trashed, err := m.SoftDelete(ctx, id, 0, opts.ModifiedBy)
+				switch {
+				case errors.Is(err, ErrNotFound) && opts.Atomic:
+					return &BatchError{Index: i, Err: err}
+				case errors.Is(err, ErrNotFound):
+					//already in the trash
+					results[i].Err = err
+				case err != nil:
+					return err
+				}
+				results[i].Blog = trashed
+				continue
+			}
+			results[i].Blog = found[id].toBlog()
+			oids = append(oids, found[id].ID)
+		}
+		if len(oids) == 0 {
+			return nil
+		}
+
+		//like Purge, revisions and comments go first
+		if _, err := m.revisions.DeleteMany(ctx, bson.M{"blog_id": bson.M{"$in": oids}}); err != nil {
+			return fmt.Errorf("deleting revisions: %w", err)
+		}
+		if _, err := m.comments.DeleteMany(ctx, bson.M{"blog_id": bson.M{"$in": oids}}); err != nil {
+			return fmt.Errorf("deleting comments: %w", err)
+		}
+		res, err := m.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": oids}})
+		if err != nil {
+			return err
+		}
+		if opts.Atomic && res.DeletedCount != int64(len(oids)) {
+			return fmt.Errorf("deleted %d of %d blogs, some were deleted meanwhile", res.DeletedCount, len(oids))
+		}
+		return nil
+	}
+	if opts.Atomic {
+		if err := m.inTransaction(ctx, remove); err != nil {
+			return nil, err
+		}
+	} else if err := remove(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// inTransaction runs fn in a transaction, retrying it as mongo advises.
+func (m *MongoStore) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := m.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("starting session: %w", err)
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// changeEvent is the part of a change stream event Watch looks at.
+type changeEvent struct {
+	OperationType string    `bson:"operationType"`
+	FullDocument  *blogItem `bson:"fullDocument"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	UpdateDescription struct {
+		UpdatedFields bson.Raw `bson:"updatedFields"`
+	} `bson:"updateDescription"`
+	ClusterTime primitive.Timestamp `bson:"clusterTime"`
+}
+
+// Watch follows a change stream on the blog collection, which needs mongo to
+// run as a replica set. Resume tokens are the stream's own, so a watch can
+// resume on any server for as long as the oplog still has the change.
+func (m *MongoStore) Watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error {
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != "" {
+		data, err := base64.RawURLEncoding.DecodeString(resumeToken)
+		if err != nil || bson.Raw(data).Validate() != nil {
+			return ErrInvalidResumeToken
+		}
+		opts.SetResumeAfter(bson.Raw(data))
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete", "invalidate"}},
+	}}}}
+
+	stream, err := m.collection.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return changeStreamError(err)
+	}
+	defer stream.Close(context.Background())
+	for stream.Next(ctx) {
+		change := changeEvent{}
+		if err := stream.Decode(&change); err != nil {
+			return fmt.Errorf("decoding change event: %w", err)
+		}
+		event := &BlogEvent{
+			Blog:        &Blog{ID: change.DocumentKey.ID.Hex()},
+			ResumeToken: base64.RawURLEncoding.EncodeToString(stream.ResumeToken()),
+			Time:        time.Unix(int64(change.ClusterTime.T), 0).UTC(),
+		}
+		if change.FullDocument != nil {
+			event.Blog = change.FullDocument.toBlog()
+		}
+		switch change.OperationType {
+		case "insert":
+			event.Type = EventCreated
+		case "update", "replace":
+			event.Type = EventUpdated
+			//SoftDelete is the only write that sets deleted_at
+			if _, err := change.UpdateDescription.UpdatedFields.LookupErr("deleted_at"); err == nil {
+				event.Type = EventDeleted
+			}
+		case "delete":
+			event.Type = EventDeleted
+		default:
+			//the collection was dropped or renamed, nothing follows
+			return ErrResumeTokenExpired
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return changeStreamError(stream.Err())
+}
+
+// changeStreamError maps the errors of a change stream that mean the events
+// after a resume token are gone.
+func changeStreamError(err error) error {
+	var serverErr mongo.ServerError
+	//ChangeStreamFatalError and ChangeStreamHistoryLost
+	if errors.As(err, &serverErr) && (serverErr.HasErrorCode(280) || serverErr.HasErrorCode(286)) {
+		return fmt.Errorf("%w: %v", ErrResumeTokenExpired, err)
+	}
+	if err != nil {
+		return fmt.Errorf("watching blogs: %w", err)
+	}
+	return nil
+}
+
+// writeRevision records data as the revision for its current version.
+func (m *MongoStore) writeRevision(ctx context.Context, data *blogItem) error {
+	if err := m.ensureIndexes(ctx); err != nil {
+		return err
+	}
+	revision := revisionItem{BlogID: data.ID, Version: data.Version, Blog: *data}
+	if _, err := m.revisions.InsertOne(ctx, revision); err != nil {
+		return fmt.Errorf("recording revision: %w", err)
+	}
+	return nil
+}
+
+// ensureIndexes creates the text index used by Search, the unique slug index
+// and the revision and comment lookup indexes the first time they are
+// needed. Creating an index that already exists is a no-op.
+func (m *MongoStore) ensureIndexes(ctx context.Context) error {
+	m.indexesMu.Lock()
+	defer m.indexesMu.Unlock()
+	if m.indexesReady {
+		return nil
+	}
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "title", Value: "text"}, {Key: "content", Value: "text"}},
+		Options: options.Index().
+			SetName("blog_text").
+			SetWeights(bson.M{"title": titleWeight, "content": 1}),
+	})
+	if err != nil {
+		return fmt.Errorf("creating text index: %w", err)
+	}
+	_, err = m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "slug", Value: 1}},
+		//blogs written before slugs existed have none and must not collide
+		Options: options.Index().
+			SetName("blog_slug").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"slug": bson.M{"$gt": ""}}),
+	})
+	if err != nil {
+		return fmt.Errorf("creating slug index: %w", err)
+	}
+	_, err = m.revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "blog_id", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetName("blog_version").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("creating revision index: %w", err)
+	}
+	_, err = m.comments.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "blog_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetName("blog_comments"),
+	})
+	if err != nil {
+		return fmt.Errorf("creating comment index: %w", err)
+	}
+	m.indexesReady = true
+	return nil
+}
+
+func (m *MongoStore) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, nil)
+}
+
+func (m *MongoStore) Close(ctx context.Context) error {
+	return m.client.Disconnect(ctx)
+}
+
+func (item *blogItem) toBlog() *Blog {
+	if item.CreatedAt.IsZero() {
+		//written before blogs had timestamps, the object id knows when
+		item.CreatedAt = item.ID.Timestamp().UTC()
+	}
+	if item.UpdatedAt.IsZero() {
+		item.UpdatedAt = item.CreatedAt
+	}
+	return &Blog{
+		ID:       item.ID.Hex(),
+		AuthorID: item.AuthorID,
+		Title:    item.Title,
+		Content:  item.Content,
+		Version:  item.Version,
+
+		CreatedAt:      item.CreatedAt,
+		UpdatedAt:      item.UpdatedAt,
+		LastModifiedBy: item.LastModifiedBy,
+		DeletedAt:      item.DeletedAt,
+
+		Tags:     item.Tags,
+		Category: item.Category,
+		Slug:     item.Slug,
+	}
+}
+
+// listFilter builds the mongo query for opts, including the keyset condition
+// that skips everything up to opts.After.
+func listFilter(opts ListOptions) (bson.M, error) {
+	filter := bson.M{}
+	if opts.AuthorID != "" {
+		filter["author_id"] = opts.AuthorID
+	}
+	if opts.TitlePrefix != "" {
+		filter["title"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(opts.TitlePrefix)}
+	}
+	if len(opts.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": opts.Tags}
+	}
+	if opts.Category != "" {
+		filter["category"] = opts.Category
+	}
+	if r := timeRangeFilter(opts.Created); r != nil {
+		filter["created_at"] = r
+	}
+	if r := timeRangeFilter(opts.Updated); r != nil {
+		filter["updated_at"] = r
+	}
+	switch opts.Deleted {
+	case ExcludeDeleted:
+		filter["deleted_at"] = nil
+	case OnlyDeleted:
+		filter["deleted_at"] = bson.M{"$ne": nil}
+	}
+	if opts.After == nil {
+		return filter, nil
+	}
+
+	oid, err := parseID(opts.After.ID)
+	if err != nil {
+		return nil, err
+	}
+	var after bson.M
+	switch opts.Order {
+	case SortCreatedDesc:
+		after = bson.M{"_id": bson.M{"$lt": oid}}
+	case SortTitleAsc:
+		after = bson.M{"$or": bson.A{
+			bson.M{"title": bson.M{"$gt": opts.After.Title}},
+			bson.M{"title": opts.After.Title, "_id": bson.M{"$gt": oid}},
+		}}
+	case SortTitleDesc:
+		after = bson.M{"$or": bson.A{
+			bson.M{"title": bson.M{"$lt": opts.After.Title}},
+			bson.M{"title": opts.After.Title, "_id": bson.M{"$lt": oid}},
+		}}
+	default:
+		after = bson.M{"_id": bson.M{"$gt": oid}}
+	}
+	return bson.M{"$and": bson.A{filter, after}}, nil
+}
+
+func timeRangeFilter(r TimeRange) bson.M {
+	filter := bson.M{}
+	if !r.Start.IsZero() {
+		filter["$gte"] = r.Start
+	}
+	if !r.End.IsZero() {
+		filter["$lt"] = r.End
+	}
+	if len(filter) == 0 {
+		return nil
+	}
+	return filter
+}
+
+func listSort(order SortOrder) bson.D {
+	switch order {
+	case SortCreatedDesc:
+		return bson.D{{Key: "_id", Value: -1}}
+	case SortTitleAsc:
+		return bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}
+	case SortTitleDesc:
+		return bson.D{{Key: "title", Value: -1}, {Key: "_id", Value: -1}}
+	default:
+		return bson.D{{Key: "_id", Value: 1}}
+	}
+}
+
+func (item *revisionItem) toBlog() *Blog {
+	return item.Blog.toBlog()
+}
+
+func (item *commentItem) toComment() *Comment {
+	comment := &Comment{
+		ID:         item.ID.Hex(),
+		BlogID:     item.BlogID.Hex(),
+		AuthorID:   item.AuthorID,
+		Content:    item.Content,
+		CreatedAt:  item.CreatedAt,
+		ReplyCount: item.ReplyCount,
+	}
+	if !item.ParentID.IsZero() {
+		comment.ParentID = item.ParentID.Hex()
+	}
+	return comment
+}
@@ -0,0 +1,103 @@
+package blogstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newMockMongoStore returns a MongoStore on the mock deployment of mt,
+// which answers the commands with the responses added to mt.
+func newMockMongoStore(mt *mtest.T) *MongoStore {
+	db := mt.Client.Database("blogdb")
+	return &MongoStore{
+		client:     mt.Client,
+		collection: db.Collection("blog"),
+		revisions:  db.Collection("blog_revisions"),
+		comments:   db.Collection("blog_comments"),
+		//the mock has no indexes to create
+		indexesReady: true,
+	}
+}
+
+// insertedRevisions returns how many revisions the commands started on mt
+// inserted.
+func insertedRevisions(mt *mtest.T) int {
+	n := 0
+	for _, event := range mt.GetAllStartedEvents() {
+		if event.CommandName != "insert" || event.Command.Lookup("insert").StringValue() != "blog_revisions" {
+			continue
+		}
+		docs, _ := event.Command.Lookup("documents").Array().Values()
+		n += len(docs)
+	}
+	return n
+}
+
+func TestMongoCreateManyRecordsRevisions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	blogs := []*Blog{
+		{AuthorID: "peter", Title: "One", Content: "one", Slug: "one"},
+		{AuthorID: "peter", Title: "Two", Content: "two", Slug: "two"},
+	}
+
+	mt.Run("all inserted", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+		results, err := newMockMongoStore(mt).CreateMany(context.Background(), blogs, false)
+		if err != nil {
+			mt.Fatalf("CreateMany failed: %v", err)
+		}
+		for i, result := range results {
+			if result.Err != nil || result.Blog == nil {
+				mt.Errorf("result %d = %+v, want a blog", i, result)
+			}
+		}
+		if n := insertedRevisions(mt); n != 2 {
+			mt.Errorf("inserted %d revisions, want 2", n)
+		}
+	})
+
+	mt.Run("some inserted", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 11000, Message: "duplicate key"}),
+			mtest.CreateSuccessResponse(),
+		)
+		results, err := newMockMongoStore(mt).CreateMany(context.Background(), blogs, false)
+		if err != nil {
+			mt.Fatalf("CreateMany failed: %v", err)
+		}
+		if results[0].Err != nil {
+			mt.Errorf("result 0 failed: %v", results[0].Err)
+		}
+		if !errors.Is(results[1].Err, ErrSlugTaken) {
+			mt.Errorf("result 1 failed with %v, want %v", results[1].Err, ErrSlugTaken)
+		}
+		if n := insertedRevisions(mt); n != 1 {
+			mt.Errorf("inserted %d revisions, want 1", n)
+		}
+	})
+
+	mt.Run("empty batch", func(mt *mtest.T) {
+		results, err := newMockMongoStore(mt).CreateMany(context.Background(), nil, false)
+		if err != nil || len(results) != 0 {
+			mt.Errorf("CreateMany() = %v, %v, want no results and no error", results, err)
+		}
+		if n := len(mt.GetAllStartedEvents()); n != 0 {
+			mt.Errorf("started %d commands, want none", n)
+		}
+	})
+
+	mt.Run("insert failed", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}, {Key: "code", Value: 2}, {Key: "errmsg", Value: "bad"}})
+		if _, err := newMockMongoStore(mt).CreateMany(context.Background(), blogs, false); err == nil {
+			mt.Error("CreateMany succeeded, want an error")
+		}
+		if n := insertedRevisions(mt); n != 0 {
+			mt.Errorf("inserted %d revisions, want none", n)
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package blogstore
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Highlight markers wrapped around matched terms by Highlight and Snippets.
+const (
+	HighlightPre  = "<em>"
+	HighlightPost = "</em>"
+)
+
+// titleWeight is how much more a term in the title counts than one in the
+// content. The mongo text index uses the same weight.
+const titleWeight = 3
+
+// SearchOptions describes a full-text search.
+type SearchOptions struct {
+	Query string
+	// Limit caps the number of results, 0 means no limit.
+	Limit int
+}
+
+// SearchResult is a blog matching a search, with its relevance score.
+type SearchResult struct {
+	Blog  *Blog
+	Score float64
+}
+
+// stopWords are skipped when indexing and searching, like mongo does.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true,
+	"their": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "to": true, "was": true, "will": true, "with": true,
+}
+
+// token is a single word found in a text, with its byte offsets.
+type token struct {
+	start, end int
+	term       string
+}
+
+// tokenize splits text into lower cased words.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{start, i, strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start, len(text), strings.ToLower(text[start:])})
+	}
+	return tokens
+}
+
+// searchTerms returns the distinct, searchable terms of a query.
+func searchTerms(query string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, tok := range tokenize(query) {
+		if stopWords[tok.term] || seen[tok.term] {
+			continue
+		}
+		seen[tok.term] = true
+		terms = append(terms, tok.term)
+	}
+	return terms
+}
+
+// Highlight returns text with every word matching the query wrapped in
+// HighlightPre and HighlightPost. Words match when their stems do, so that
+// the hits of the mongo store, whose text index stems words, have their
+// highlights too.
+func Highlight(text string, query string) string {
+	terms := termSet(query)
+	tokens := tokenize(text)
+	return highlightRange(text, tokens, 0, len(text), terms)
+}
+
+// Snippets returns up to max fragments of text surrounding the words that
+// match the query, with the matches highlighted. When nothing in text
+// matches, the start of the text is returned instead.
+func Snippets(text string, query string, max int) []string {
+	const around = 6 // words kept on each side of a match
+
+	terms := termSet(query)
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	type window struct{ lo, hi int }
+	var windows []window
+	for i, tok := range tokens {
+		if !terms[stem(tok.term)] {
+			continue
+		}
+		lo, hi := i-around, i+around
+		if lo < 0 {
+			lo = 0
+		}
+		if hi >= len(tokens) {
+			hi = len(tokens) - 1
+		}
+		if n := len(windows); n > 0 && lo <= windows[n-1].hi {
+			windows[n-1].hi = hi
+			continue
+		}
+		if len(windows) == max {
+			break
+		}
+		windows = append(windows, window{lo, hi})
+	}
+	if len(windows) == 0 {
+		hi := 2 * around
+		if hi >= len(tokens) {
+			hi = len(tokens) - 1
+		}
+		windows = append(windows, window{0, hi})
+	}
+
+	snippets := make([]string, 0, len(windows))
+	for _, w := range windows {
+		snippet := highlightRange(text, tokens, tokens[w.lo].start, tokens[w.hi].end, terms)
+		if w.lo > 0 {
+			snippet = "..." + snippet
+		}
+		if w.hi < len(tokens)-1 {
+			snippet += "..."
+		}
+		snippets = append(snippets, snippet)
+	}
+	return snippets
+}
+
+// termSet returns the stems of the searchable terms of a query.
+func termSet(query string) map[string]bool {
+	terms := map[string]bool{}
+	for _, term := range searchTerms(query) {
+		terms[stem(term)] = true
+	}
+	return terms
+}
+
+// stem strips the common English inflections off a lower cased word, so
+// that e.g. "stream", "streams" and "streaming" share a stem. It is much
+// cruder than the snowball stemmer of mongo, but it only decides what gets
+// highlighted, never what matches.
+func stem(word string) string {
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ies"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && len(word) > 3:
+		word = word[:len(word)-1]
+	}
+	for _, suffix := range []string{"ing", "ed"} {
+		if base := strings.TrimSuffix(word, suffix); base != word && len(base) >= 3 && strings.ContainsAny(base, "aeiouy") {
+			word = base
+			//running -> runn -> run
+			if n := len(base); base[n-1] == base[n-2] && !strings.ContainsAny(base[n-1:], "aeiouylsz") {
+				word = base[:n-1]
+			}
+			break
+		}
+	}
+	//write and writing share writ, query and queries queri
+	word = strings.TrimSuffix(word, "e")
+	if n := len(word); n > 2 && word[n-1] == 'y' && !strings.ContainsAny(word[n-2:n-1], "aeiou") {
+		word = word[:n-1] + "i"
+	}
+	return word
+}
+
+// highlightRange returns text[from:to] with the matching tokens highlighted.
+func highlightRange(text string, tokens []token, from, to int, terms map[string]bool) string {
+	var b strings.Builder
+	last := from
+	for _, tok := range tokens {
+		if tok.start < from || tok.end > to || !terms[stem(tok.term)] {
+			continue
+		}
+		b.WriteString(text[last:tok.start])
+		b.WriteString(HighlightPre)
+		b.WriteString(text[tok.start:tok.end])
+		b.WriteString(HighlightPost)
+		last = tok.end
+	}
+	b.WriteString(text[last:to])
+	return b.String()
+}
@@ -0,0 +1,161 @@
+package blogstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Streams", Content: "about grpc"})
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Unary calls", Content: "grpc streams are covered elsewhere"})
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Mongo", Content: "documents"})
+		trashed := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Old streams", Content: "streams"})
+		updated := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Draft", Content: "streams"})
+		if _, err := store.SoftDelete(ctx, trashed.ID, 0, "peter"); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+		//the index follows updates
+		update := *updated
+		update.Content = "nothing to see"
+		if _, err := store.Update(ctx, &update, 0); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		tests := []struct {
+			name string
+			opts SearchOptions
+			want []string
+		}{
+			{"title first", SearchOptions{Query: "streams"}, []string{"Streams", "Unary calls"}},
+			{"any term", SearchOptions{Query: "documents grpc"}, []string{"Mongo", "Streams", "Unary calls"}},
+			{"case", SearchOptions{Query: "MONGO"}, []string{"Mongo"}},
+			{"limit", SearchOptions{Query: "streams", Limit: 1}, []string{"Streams"}},
+			{"stop words", SearchOptions{Query: "the"}, []string{}},
+			{"no match", SearchOptions{Query: "kafka"}, []string{}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got := []string{}
+				err := store.Search(ctx, tt.opts, func(result *SearchResult) error {
+					got = append(got, result.Blog.Title)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Search(%q) = %q, want %q", tt.opts.Query, got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestStem(t *testing.T) {
+	groups := [][]string{
+		{"stream", "streams", "streaming", "streamed"},
+		{"run", "runs", "running"},
+		{"write", "writes", "writing"},
+		{"query", "queries", "queried"},
+		{"class", "classes"},
+	}
+	for _, group := range groups {
+		want := stem(group[0])
+		for _, word := range group[1:] {
+			if got := stem(word); got != want {
+				t.Errorf("stem(%q) = %q, want %q like %q", word, got, want, group[0])
+			}
+		}
+	}
+	for _, word := range []string{"status", "bus", "grpc", "go"} {
+		if got := stem(word); got != word {
+			t.Errorf("stem(%q) = %q, want it unchanged", word, got)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name, text, query, want string
+	}{
+		{"exact", "Hello gRPC world", "grpc", "Hello <em>gRPC</em> world"},
+		{"several terms", "Go and gRPC", "go grpc", "<em>Go</em> and <em>gRPC</em>"},
+		{"stemmed", "Streaming blogs", "streams blog", "<em>Streaming</em> <em>blogs</em>"},
+		{"stop words skipped", "The end", "the end", "The <em>end</em>"},
+		{"no match", "Hello world", "mongo", "Hello world"},
+		{"punctuation kept", "(grpc), grpc!", "grpc", "(<em>grpc</em>), <em>grpc</em>!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.text, tt.query); got != tt.want {
+				t.Errorf("Highlight(%q, %q) = %q, want %q", tt.text, tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		max   int
+		want  []string
+	}{
+		{
+			name:  "whole short text",
+			text:  "streaming with grpc",
+			query: "grpc",
+			max:   3,
+			want:  []string{"streaming with <em>grpc</em>"},
+		},
+		{
+			name:  "window around the match",
+			text:  "one two three four five six seven eight nine ten grpc eleven",
+			query: "grpc",
+			max:   3,
+			want:  []string{"...five six seven eight nine ten <em>grpc</em> eleven"},
+		},
+		{
+			name:  "close matches merged",
+			text:  "grpc one two grpc",
+			query: "grpc",
+			max:   3,
+			want:  []string{"<em>grpc</em> one two <em>grpc</em>"},
+		},
+		{
+			name:  "capped at max",
+			text:  "grpc a1 a2 a3 a4 a5 a6 a7 a8 a9 a10 a11 a12 a13 grpc b1 b2 b3 b4 b5 b6 b7 b8 b9 b10 b11 b12 b13 grpc",
+			query: "grpc",
+			max:   2,
+			want: []string{
+				"<em>grpc</em> a1 a2 a3 a4 a5 a6...",
+				"...a8 a9 a10 a11 a12 a13 <em>grpc</em> b1 b2 b3 b4 b5 b6...",
+			},
+		},
+		{
+			name:  "start of the text without a match",
+			text:  "nothing to see here",
+			query: "grpc",
+			max:   3,
+			want:  []string{"nothing to see here"},
+		},
+		{
+			name:  "empty text",
+			text:  "",
+			query: "grpc",
+			max:   3,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snippets(tt.text, tt.query, tt.max); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Snippets(%q, %q, %d) = %q, want %q", tt.text, tt.query, tt.max, got, tt.want)
+			}
+		})
+	}
+}
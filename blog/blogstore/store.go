@@ -0,0 +1,236 @@
+// Package blogstore holds the storage backends used by the blog server.
+//
+// Every backend implements BlogStore so the server handlers never need to know
+// whether blogs live in MongoDB, in memory or in a local bolt file.
+package blogstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when no blog exists with the requested id.
+	ErrNotFound = errors.New("blog not found")
+	// ErrInvalidID is returned when the id is not a valid hex object id.
+	ErrInvalidID = errors.New("invalid blog id")
+	// ErrVersionMismatch is returned when a write expected a different version
+	// of the blog than the one stored, i.e. someone else changed it first.
+	ErrVersionMismatch = errors.New("blog version mismatch")
+	// ErrRevisionNotFound is returned when a blog has no revision with the
+	// requested version.
+	ErrRevisionNotFound = errors.New("blog revision not found")
+	// ErrNotDeleted is returned when undeleting a blog that is not in the
+	// trash.
+	ErrNotDeleted = errors.New("blog is not deleted")
+	// ErrSlugTaken is matched by the SlugTakenError returned when a write
+	// would give a blog the slug of another blog.
+	ErrSlugTaken = errors.New("blog slug already taken")
+)
+
+// SlugTakenError reports which slug a write tried to reuse.
+type SlugTakenError struct {
+	Slug string
+}
+
+func (e *SlugTakenError) Error() string {
+	return fmt.Sprintf("%v: %q", ErrSlugTaken, e.Slug)
+}
+
+// Is makes errors.Is(err, ErrSlugTaken) work.
+func (e *SlugTakenError) Is(target error) bool {
+	return target == ErrSlugTaken
+}
+
+// Blog is the stored representation of a blog post.
+type Blog struct {
+	ID       string
+	AuthorID string
+	Title    string
+	Content  string
+	// Version starts at 1 and is incremented by the store on every write.
+	Version int64
+	// CreatedAt and UpdatedAt are set by the store, UpdatedAt on every write.
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// LastModifiedBy identifies whoever made the latest write. The caller
+	// fills it in, the store just keeps it.
+	LastModifiedBy string
+	// DeletedAt is set while the blog is in the trash after a SoftDelete.
+	DeletedAt time.Time
+
+	Tags     []string
+	Category string
+	// Slug addresses the blog by a readable name. No two blogs, including
+	// the ones in the trash, share a slug.
+	Slug string
+}
+
+// BlogStore is the persistence layer for blogs and their comments.
+type BlogStore interface {
+	CommentStore
+
+	// Create stores a new blog, assigns it an id and timestamps and returns
+	// the stored copy. Like every write it also records a revision.
+	Create(ctx context.Context, blog *Blog) (*Blog, error)
+	// Read returns the blog with the given id, even if it is in the trash.
+	Read(ctx context.Context, id string) (*Blog, error)
+	// ReadBySlug is Read for the blog with the given slug.
+	ReadBySlug(ctx context.Context, slug string) (*Blog, error)
+	// Update replaces the blog with the same id as blog, keeping its creation
+	// time, and its slug when blog.Slug is empty. When expectedVersion is not
+	// 0 the update only happens if the stored blog is at that version. Blogs
+	// in the trash can't be updated.
+	Update(ctx context.Context, blog *Blog, expectedVersion int64) (*Blog, error)
+	// Delete removes the blog with the given id, its revisions and its
+	// comments. When expectedVersion is not 0 the delete only happens if the
+	// stored blog is at that version.
+	Delete(ctx context.Context, id string, expectedVersion int64) error
+	// SoftDelete moves a blog to the trash by setting DeletedAt. It is a
+	// write like Update, so it checks expectedVersion and bumps the version.
+	SoftDelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (*Blog, error)
+	// Undelete takes a blog back out of the trash.
+	Undelete(ctx context.Context, id string, expectedVersion int64, modifiedBy string) (*Blog, error)
+	// Purge permanently deletes every blog that went to the trash before
+	// deletedBefore, with its comments, and returns how many blogs were
+	// removed.
+	Purge(ctx context.Context, deletedBefore time.Time) (int, error)
+	// List calls fn for every blog matching opts, in the requested order,
+	// stopping at the first error.
+	List(ctx context.Context, opts ListOptions, fn func(*Blog) error) error
+	// ListTags counts the blogs carrying each tag, most used tag first.
+	// Blogs in the trash are not counted.
+	ListTags(ctx context.Context, opts TagOptions) ([]TagCount, error)
+	// Search calls fn for every blog matching the full-text query, best match
+	// first, stopping at the first error. Blogs in the trash never match.
+	Search(ctx context.Context, opts SearchOptions, fn func(*SearchResult) error) error
+	// ListRevisions calls fn for every recorded revision of a blog, newest
+	// first, stopping at the first error. A revision is the blog exactly as it
+	// was written at that version and is never changed afterwards. Blogs in
+	// the trash report ErrNotFound here and in ReadRevision.
+	ListRevisions(ctx context.Context, id string, fn func(*Blog) error) error
+	// ReadRevision returns the revision of a blog at the given version.
+	ReadRevision(ctx context.Context, id string, version int64) (*Blog, error)
+	// CreateMany is Create for several blogs, returning a result for each.
+	// With atomic set either every blog is stored or, when one of them can't
+	// be, none and the error is a *BatchError naming it.
+	CreateMany(ctx context.Context, blogs []*Blog, atomic bool) ([]BatchResult, error)
+	// ReadMany is Read for several blogs, returning a result for each.
+	ReadMany(ctx context.Context, ids []string) ([]BatchResult, error)
+	// DeleteMany is Delete, or SoftDelete, for several blogs without a
+	// version check, returning a result for each. An atomic batch fails
+	// like one of CreateMany. The ids must not repeat.
+	DeleteMany(ctx context.Context, ids []string, opts DeleteManyOptions) ([]BatchResult, error)
+	// Watch calls fn for every change to a blog, in the order they happened,
+	// until fn or the store fails or ctx ends. It starts after the event
+	// resumeToken was handed out with, or with the next change when
+	// resumeToken is empty.
+	Watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error
+	// Ping fails when the store can't be reached, e.g. when the database
+	// server is down.
+	Ping(ctx context.Context) error
+	// Close releases any resources held by the store.
+	Close(ctx context.Context) error
+}
+
+// Backend names accepted by Open.
+const (
+	BackendMongo  = "mongo"
+	BackendMemory = "memory"
+	BackendBolt   = "bolt"
+)
+
+// Options selects and configures a backend for Open.
+type Options struct {
+	Backend string
+
+	// MongoURI and MongoDatabase are used by the mongo backend.
+	MongoURI      string
+	MongoDatabase string
+
+	// BoltPath is the database file used by the bolt backend.
+	BoltPath string
+}
+
+// Open creates the store described by opts.
+func Open(ctx context.Context, opts Options) (BlogStore, error) {
+	switch opts.Backend {
+	case BackendMongo:
+		return NewMongoStore(ctx, opts.MongoURI, opts.MongoDatabase)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendBolt:
+		return NewBoltStore(opts.BoltPath)
+	default:
+		return nil, fmt.Errorf("unknown blog store backend %q", opts.Backend)
+	}
+}
+
+// now returns the time stored in CreatedAt and UpdatedAt. It is truncated to
+// milliseconds, the precision mongo keeps, so every backend behaves the same.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// newID returns a fresh blog id. All backends use hex object ids so that ids
+// look the same no matter where the blog is stored.
+func newID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// parseID turns a hex blog id back into an object id.
+func parseID(id string) (primitive.ObjectID, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return oid, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	return oid, nil
+}
+
+// checkVersion makes sure stored is at the version the caller expects.
+func checkVersion(stored *Blog, expectedVersion int64) error {
+	if expectedVersion != 0 && stored.Version != expectedVersion {
+		return fmt.Errorf("%w: expected version %d but blog %s is at version %d",
+			ErrVersionMismatch, expectedVersion, stored.ID, stored.Version)
+	}
+	return nil
+}
+
+// created returns the first version of a new blog, with a fresh id.
+func created(blog *Blog) *Blog {
+	stored := *blog
+	stored.ID = newID()
+	stored.Tags = append([]string(nil), blog.Tags...)
+	stored.Version = 1
+	stored.CreatedAt = now()
+	stored.UpdatedAt = stored.CreatedAt
+	return &stored
+}
+
+// trashed returns the next version of current moved into (deleted) or out of
+// the trash, after checking the move makes sense.
+func trashed(current *Blog, deleted bool, expectedVersion int64, modifiedBy string) (*Blog, error) {
+	if deleted && !current.DeletedAt.IsZero() {
+		return nil, ErrNotFound
+	}
+	if !deleted && current.DeletedAt.IsZero() {
+		return nil, ErrNotDeleted
+	}
+	if err := checkVersion(current, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	next := *current
+	next.Version++
+	next.UpdatedAt = now()
+	next.LastModifiedBy = modifiedBy
+	next.DeletedAt = time.Time{}
+	if deleted {
+		next.DeletedAt = next.UpdatedAt
+	}
+	return &next, nil
+}
@@ -0,0 +1,517 @@
+package blogstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBackends open an empty store of each backend that runs without an
+// external service.
+var testBackends = []struct {
+	name string
+	open func(t *testing.T) BlogStore
+}{
+	{BackendMemory, func(t *testing.T) BlogStore { return NewMemoryStore() }},
+	{BackendBolt, func(t *testing.T) BlogStore {
+		store, err := NewBoltStore(filepath.Join(t.TempDir(), "blog.db"))
+		if err != nil {
+			t.Fatalf("NewBoltStore failed: %v", err)
+		}
+		//nothing survives the test anyway, and racing writes race harder
+		store.db.NoSync = true
+		return store
+	}},
+}
+
+// forEachBackend runs test as a subtest against a new store of every test
+// backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, store BlogStore)) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			store := backend.open(t)
+			t.Cleanup(func() { store.Close(context.Background()) })
+			test(t, store)
+		})
+	}
+}
+
+// mustCreate creates a blog, failing the test if it can't.
+func mustCreate(t *testing.T, store BlogStore, blog *Blog) *Blog {
+	t.Helper()
+	created, err := store.Create(context.Background(), blog)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return created
+}
+
+// wantErr fails the test unless errors.Is(err, want).
+func wantErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
+
+// missingID is a valid blog id no store knows.
+const missingID = "5f0000000000000000000000"
+
+func TestCreateRead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		created := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Hello", Content: "content"})
+		if _, err := parseID(created.ID); err != nil {
+			t.Errorf("created id %q is not an object id: %v", created.ID, err)
+		}
+
+		read, err := store.Read(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if read.ID != created.ID || read.AuthorID != "peter" || read.Title != "Hello" || read.Content != "content" {
+			t.Errorf("Read() = %+v, want %+v", read, created)
+		}
+
+		_, err = store.Read(ctx, missingID)
+		wantErr(t, err, ErrNotFound)
+		_, err = store.Read(ctx, "not-an-id")
+		wantErr(t, err, ErrInvalidID)
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		created := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Hello", Content: "content"})
+
+		updated, err := store.Update(ctx, &Blog{ID: created.ID, AuthorID: "peter", Title: "Hello again", Content: "new content"}, 0)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if updated.ID != created.ID || updated.Title != "Hello again" || updated.Content != "new content" {
+			t.Errorf("Update() = %+v, want the new fields", updated)
+		}
+		if read, err := store.Read(ctx, created.ID); err != nil || read.Title != "Hello again" {
+			t.Errorf("Read() = %+v, %v, want the updated blog", read, err)
+		}
+
+		_, err = store.Update(ctx, &Blog{ID: missingID, Title: "x"}, 0)
+		wantErr(t, err, ErrNotFound)
+		_, err = store.Update(ctx, &Blog{ID: "bad", Title: "x"}, 0)
+		wantErr(t, err, ErrInvalidID)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "content"})
+		kept := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Kept", Content: "content"})
+
+		if err := store.Delete(ctx, blog.ID, 0); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		_, err := store.Read(ctx, blog.ID)
+		wantErr(t, err, ErrNotFound)
+		if _, err := store.Read(ctx, kept.ID); err != nil {
+			t.Errorf("Read of the other blog failed: %v", err)
+		}
+		wantErr(t, store.Delete(ctx, blog.ID, 0), ErrNotFound)
+		wantErr(t, store.Delete(ctx, "bad", 0), ErrInvalidID)
+	})
+}
+
+func TestVersions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		created := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Hello", Content: "content"})
+		if created.Version != 1 {
+			t.Errorf("created version = %d, want 1", created.Version)
+		}
+
+		update := &Blog{ID: created.ID, AuthorID: "peter", Title: "Hello again", Content: "new content"}
+		updated, err := store.Update(ctx, update, 1)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if updated.Version != 2 {
+			t.Errorf("updated version = %d, want 2", updated.Version)
+		}
+		//version 1 is gone, the blog changed in between
+		_, err = store.Update(ctx, update, 1)
+		wantErr(t, err, ErrVersionMismatch)
+		//no expected version always writes
+		if updated, err := store.Update(ctx, update, 0); err != nil || updated.Version != 3 {
+			t.Errorf("Update without a version = %+v, %v, want version 3", updated, err)
+		}
+
+		wantErr(t, store.Delete(ctx, created.ID, 2), ErrVersionMismatch)
+		if err := store.Delete(ctx, created.ID, 3); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	})
+}
+
+func TestTimestamps(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		created := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Hello", Content: "content", LastModifiedBy: "peter"})
+		if created.CreatedAt.IsZero() || !created.UpdatedAt.Equal(created.CreatedAt) {
+			t.Errorf("created at %v and updated at %v, want the same time", created.CreatedAt, created.UpdatedAt)
+		}
+		read, err := store.Read(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if !read.CreatedAt.Equal(created.CreatedAt) || !read.UpdatedAt.Equal(created.UpdatedAt) || read.LastModifiedBy != "peter" {
+			t.Errorf("Read() = %+v, want the times of %+v modified by peter", read, created)
+		}
+
+		updated, err := store.Update(ctx, &Blog{ID: created.ID, AuthorID: "peter", Title: "Hello again", Content: "content", LastModifiedBy: "anna"}, 0)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if !updated.CreatedAt.Equal(created.CreatedAt) || updated.UpdatedAt.Before(created.UpdatedAt) {
+			t.Errorf("updated times %v, %v, want creation kept and update moved on", updated.CreatedAt, updated.UpdatedAt)
+		}
+		if updated.LastModifiedBy != "anna" {
+			t.Errorf("updated last modified by %q, want anna", updated.LastModifiedBy)
+		}
+	})
+}
+
+func TestRevisions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "v1"})
+		for _, content := range []string{"v2", "v3"} {
+			update := *blog
+			update.Content = content
+			if _, err := store.Update(ctx, &update, 0); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+		}
+
+		var contents []string
+		var versions []int64
+		err := store.ListRevisions(ctx, blog.ID, func(revision *Blog) error {
+			contents = append(contents, revision.Content)
+			versions = append(versions, revision.Version)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ListRevisions failed: %v", err)
+		}
+		if !reflect.DeepEqual(contents, []string{"v3", "v2", "v1"}) || !reflect.DeepEqual(versions, []int64{3, 2, 1}) {
+			t.Errorf("revisions %q at %v, want v3, v2, v1 at 3, 2, 1", contents, versions)
+		}
+
+		revision, err := store.ReadRevision(ctx, blog.ID, 2)
+		if err != nil {
+			t.Fatalf("ReadRevision failed: %v", err)
+		}
+		if revision.Content != "v2" || revision.Version != 2 {
+			t.Errorf("ReadRevision() = %+v, want the blog at version 2", revision)
+		}
+		_, err = store.ReadRevision(ctx, blog.ID, 9)
+		wantErr(t, err, ErrRevisionNotFound)
+		_, err = store.ReadRevision(ctx, missingID, 1)
+		wantErr(t, err, ErrNotFound)
+
+		//the trash hides the revisions, deleting removes them
+		if _, err := store.SoftDelete(ctx, blog.ID, 0, "peter"); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+		err = store.ListRevisions(ctx, blog.ID, func(*Blog) error { return nil })
+		wantErr(t, err, ErrNotFound)
+		if _, err := store.Undelete(ctx, blog.ID, 0, "peter"); err != nil {
+			t.Fatalf("Undelete failed: %v", err)
+		}
+		if _, err := store.ReadRevision(ctx, blog.ID, 5); err != nil {
+			t.Errorf("ReadRevision of the undelete failed: %v", err)
+		}
+		if err := store.Delete(ctx, blog.ID, 0); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		_, err = store.ReadRevision(ctx, blog.ID, 1)
+		wantErr(t, err, ErrNotFound)
+	})
+}
+
+func TestTrash(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "content"})
+
+		_, err := store.SoftDelete(ctx, blog.ID, 2, "anna")
+		wantErr(t, err, ErrVersionMismatch)
+		trashed, err := store.SoftDelete(ctx, blog.ID, 1, "anna")
+		if err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+		if trashed.DeletedAt.IsZero() || trashed.Version != 2 || trashed.LastModifiedBy != "anna" {
+			t.Errorf("SoftDelete() = %+v, want version 2 deleted by anna", trashed)
+		}
+		//Read still finds it, writes don't
+		if read, err := store.Read(ctx, blog.ID); err != nil || read.DeletedAt.IsZero() {
+			t.Errorf("Read() = %+v, %v, want the trashed blog", read, err)
+		}
+		_, err = store.SoftDelete(ctx, blog.ID, 0, "anna")
+		wantErr(t, err, ErrNotFound)
+		_, err = store.Update(ctx, &Blog{ID: blog.ID, Title: "x"}, 0)
+		wantErr(t, err, ErrNotFound)
+
+		restored, err := store.Undelete(ctx, blog.ID, 2, "peter")
+		if err != nil {
+			t.Fatalf("Undelete failed: %v", err)
+		}
+		if !restored.DeletedAt.IsZero() || restored.Version != 3 {
+			t.Errorf("Undelete() = %+v, want version 3 out of the trash", restored)
+		}
+		_, err = store.Undelete(ctx, blog.ID, 0, "peter")
+		wantErr(t, err, ErrNotDeleted)
+		_, err = store.Undelete(ctx, missingID, 0, "peter")
+		wantErr(t, err, ErrNotFound)
+	})
+}
+
+func TestPurge(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		kept := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Kept", Content: "content"})
+		var trashed []*Blog
+		for i := 0; i < 2; i++ {
+			blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Trashed", Content: "content"})
+			if _, err := store.SoftDelete(ctx, blog.ID, 0, "peter"); err != nil {
+				t.Fatalf("SoftDelete failed: %v", err)
+			}
+			trashed = append(trashed, blog)
+		}
+
+		//nothing went to the trash that long ago
+		if n, err := store.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Fatalf("Purge() = %d, %v, want 0 blogs purged", n, err)
+		}
+		if n, err := store.Purge(ctx, time.Now().Add(time.Second)); err != nil || n != 2 {
+			t.Fatalf("Purge() = %d, %v, want 2 blogs purged", n, err)
+		}
+		for _, blog := range trashed {
+			_, err := store.Read(ctx, blog.ID)
+			wantErr(t, err, ErrNotFound)
+		}
+		if _, err := store.Read(ctx, kept.ID); err != nil {
+			t.Errorf("Read of the blog outside the trash failed: %v", err)
+		}
+	})
+}
+
+func TestSlugs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		first := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "First", Content: "content", Slug: "first"})
+		second := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Second", Content: "content", Slug: "second"})
+
+		bySlug, err := store.ReadBySlug(ctx, "first")
+		if err != nil {
+			t.Fatalf("ReadBySlug failed: %v", err)
+		}
+		if bySlug.ID != first.ID {
+			t.Errorf("ReadBySlug() = blog %s, want %s", bySlug.ID, first.ID)
+		}
+		_, err = store.ReadBySlug(ctx, "missing")
+		wantErr(t, err, ErrNotFound)
+
+		_, err = store.Create(ctx, &Blog{AuthorID: "peter", Title: "Copy", Content: "content", Slug: "first"})
+		var taken *SlugTakenError
+		if !errors.As(err, &taken) || taken.Slug != "first" || !errors.Is(err, ErrSlugTaken) {
+			t.Fatalf("Create with a taken slug failed with %v, want a SlugTakenError for first", err)
+		}
+		update := *second
+		update.Slug = "first"
+		_, err = store.Update(ctx, &update, 0)
+		wantErr(t, err, ErrSlugTaken)
+
+		//renaming frees the old slug, deleting frees the new one
+		update = *first
+		update.Slug = "renamed"
+		if _, err := store.Update(ctx, &update, 0); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Reuse", Content: "content", Slug: "first"})
+		if err := store.Delete(ctx, first.ID, 0); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Reuse", Content: "content", Slug: "renamed"})
+
+		//blogs without a slug don't collide
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "No slug", Content: "content"})
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "No slug", Content: "content"})
+	})
+}
+
+func TestSlugsInTheTrash(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "content", Slug: "trashed"})
+		if _, err := store.SoftDelete(ctx, blog.ID, 0, "peter"); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+
+		//the slug stays taken while the blog is in the trash
+		_, err := store.Create(ctx, &Blog{AuthorID: "peter", Title: "Title", Content: "content", Slug: "trashed"})
+		wantErr(t, err, ErrSlugTaken)
+		//purged slugs are free again
+		if _, err := store.Purge(ctx, time.Now().Add(time.Second)); err != nil {
+			t.Fatalf("Purge failed: %v", err)
+		}
+		mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "content", Slug: "trashed"})
+	})
+}
+
+func TestTagsAndCategory(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		tags := []string{"go", "grpc"}
+		created := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "content", Tags: tags, Category: "news"})
+		//the store keeps its own copy of the tags
+		tags[0] = "changed"
+
+		read, err := store.Read(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if !reflect.DeepEqual(read.Tags, []string{"go", "grpc"}) || read.Category != "news" {
+			t.Errorf("Read() = %+v, want the tags go and grpc in news", read)
+		}
+
+		update := *read
+		update.Tags, update.Category = nil, ""
+		if _, err := store.Update(ctx, &update, 0); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if read, err := store.Read(ctx, created.ID); err != nil || len(read.Tags) != 0 || read.Category != "" {
+			t.Errorf("Read() = %+v, %v, want the tags and category cleared", read, err)
+		}
+	})
+}
+
+// watchEvents watches store until the test ends and returns the channel
+// the events arrive on. The watch is running once it returns.
+func watchEvents(t *testing.T, store BlogStore) <-chan *BlogEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan *BlogEvent, 200)
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	go func() {
+		defer close(done)
+		store.Watch(ctx, "", func(event *BlogEvent) error {
+			select {
+			case events <- event:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	//the watch has started once it sees a blog created after it
+	deadline := time.After(5 * time.Second)
+	for {
+		probe := mustCreate(t, store, &Blog{AuthorID: "probe", Title: "Probe", Content: "probe"})
+		retry := time.After(50 * time.Millisecond)
+	wait:
+		for {
+			select {
+			case event := <-events:
+				if event.Blog.ID == probe.ID {
+					return events
+				}
+			case <-retry:
+				break wait
+			case <-deadline:
+				t.Fatal("watch did not start")
+			}
+		}
+	}
+}
+
+// nextEvent returns the next event, failing the test when none comes.
+func nextEvent(t *testing.T, events <-chan *BlogEvent) *BlogEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func TestConcurrentWritesKeepOrder(t *testing.T) {
+	const writers = 100
+	//interleave the writers even on a single CPU
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		events := watchEvents(t, store)
+		blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Race", Content: "start"})
+		nextEvent(t, events)
+
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				update := *blog
+				update.Content = fmt.Sprintf("word%d", i)
+				if _, err := store.Update(ctx, &update, 0); err != nil {
+					t.Errorf("Update failed: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		var last *BlogEvent
+		for i := 0; i < writers; i++ {
+			event := nextEvent(t, events)
+			if want := int64(i + 2); event.Blog.Version != want {
+				t.Fatalf("event %d is of version %d, want %d", i, event.Blog.Version, want)
+			}
+			last = event
+		}
+
+		//the index holds the content of the last write
+		stored, err := store.Read(ctx, blog.ID)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if stored.Content != last.Blog.Content {
+			t.Fatalf("stored content %q, last event has %q", stored.Content, last.Blog.Content)
+		}
+		for i := 0; i < writers; i++ {
+			word := fmt.Sprintf("word%d", i)
+			found := false
+			err := store.Search(ctx, SearchOptions{Query: word}, func(*SearchResult) error {
+				found = true
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if want := word == stored.Content; found != want {
+				t.Errorf("Search(%q) found the blog: %v, want %v", word, found, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package blogstore
+
+import "sort"
+
+// TagOptions filters the blogs counted by ListTags.
+type TagOptions struct {
+	Category string
+}
+
+// TagCount is the number of blogs carrying a tag.
+type TagCount struct {
+	Tag   string
+	Count int64
+}
+
+func hasTag(blog *Blog, tag string) bool {
+	for _, t := range blog.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// countTags counts tags in process for the backends without a query engine.
+func countTags(blogs []Blog, opts TagOptions) []TagCount {
+	counts := make(map[string]int64)
+	for i := range blogs {
+		if !blogs[i].DeletedAt.IsZero() {
+			continue
+		}
+		if opts.Category != "" && blogs[i].Category != opts.Category {
+			continue
+		}
+		for _, tag := range blogs[i].Tags {
+			counts[tag]++
+		}
+	}
+	tags := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, TagCount{Tag: tag, Count: count})
+	}
+	sortTagCounts(tags)
+	return tags
+}
+
+// sortTagCounts puts the most used tags first, ties in alphabetical order.
+func sortTagCounts(tags []TagCount) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+}
@@ -0,0 +1,182 @@
+package blogstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidResumeToken is returned by Watch for a resume token it did
+	// not hand out.
+	ErrInvalidResumeToken = errors.New("invalid resume token")
+	// ErrResumeTokenExpired is returned by Watch when the events after a
+	// resume token are no longer available, e.g. after a restart. The
+	// watcher has to list the blogs again and start a new watch.
+	ErrResumeTokenExpired = errors.New("resume token expired")
+)
+
+// EventType says what happened to a blog.
+type EventType int
+
+const (
+	EventCreated EventType = iota + 1
+	// EventUpdated is sent for every write that keeps the blog, including
+	// taking it out of the trash.
+	EventUpdated
+	// EventDeleted is sent when a blog goes to the trash and again when it
+	// is removed for good.
+	EventDeleted
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventCreated:
+		return "created"
+	case EventUpdated:
+		return "updated"
+	case EventDeleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// BlogEvent is one change reported by Watch.
+type BlogEvent struct {
+	Type EventType
+	// Blog is the blog as it was written. When a blog is removed for good
+	// the mongo backend only knows its ID.
+	Blog *Blog
+	// ResumeToken is passed back to Watch to receive the events following
+	// this one.
+	ResumeToken string
+	Time        time.Time
+}
+
+// eventHistory is how many events the in-process bus keeps for watchers
+// that fall behind or reconnect.
+const eventHistory = 1024
+
+// eventBus is the change feed of the backends without one of their own. It
+// keeps the latest events, watchers read them at their own pace and wait
+// for a signal once they caught up.
+type eventBus struct {
+	mu sync.Mutex
+	// epoch tells tokens of this bus apart from those of an earlier process.
+	epoch  string
+	next   uint64 // sequence number of the next event
+	events []BlogEvent
+	// changed is closed, and replaced, whenever an event is published.
+	changed chan struct{}
+}
+
+func newEventBus() *eventBus {
+	epoch := make([]byte, 8)
+	if _, err := rand.Read(epoch); err != nil {
+		panic(fmt.Sprintf("reading random epoch: %v", err))
+	}
+	return &eventBus{
+		epoch:   hex.EncodeToString(epoch),
+		next:    1,
+		events:  make([]BlogEvent, 0, eventHistory),
+		changed: make(chan struct{}),
+	}
+}
+
+// publish records that blog changed. The blog is copied.
+func (bus *eventBus) publish(t EventType, blog *Blog) {
+	stored := *blog
+	stored.Tags = append([]string(nil), blog.Tags...)
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	event := BlogEvent{
+		Type:        t,
+		Blog:        &stored,
+		ResumeToken: bus.token(bus.next),
+		Time:        now(),
+	}
+	bus.next++
+	if len(bus.events) == eventHistory {
+		copy(bus.events, bus.events[1:])
+		bus.events = bus.events[:eventHistory-1]
+	}
+	bus.events = append(bus.events, event)
+	close(bus.changed)
+	bus.changed = make(chan struct{})
+}
+
+// watch calls fn for every event after the one resumeToken points to, or
+// for the events published from now on when resumeToken is empty. It only
+// returns on an error, including the end of ctx.
+func (bus *eventBus) watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error {
+	bus.mu.Lock()
+	from := bus.next
+	bus.mu.Unlock()
+	if resumeToken != "" {
+		last, err := bus.parseToken(resumeToken)
+		if err != nil {
+			return err
+		}
+		from = last + 1
+	}
+
+	for {
+		bus.mu.Lock()
+		first := bus.next - uint64(len(bus.events))
+		if from < first || from > bus.next {
+			bus.mu.Unlock()
+			return ErrResumeTokenExpired
+		}
+		pending := append([]BlogEvent(nil), bus.events[from-first:]...)
+		changed := bus.changed
+		bus.mu.Unlock()
+
+		for i := range pending {
+			if err := fn(&pending[i]); err != nil {
+				return err
+			}
+		}
+		from += uint64(len(pending))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (bus *eventBus) token(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(bus.epoch + "." + strconv.FormatUint(seq, 10)))
+}
+
+// parseToken returns the sequence number of the event a token was handed
+// out with.
+func (bus *eventBus) parseToken(token string) (uint64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidResumeToken
+	}
+	parts := strings.SplitN(string(data), ".", 2)
+	if len(parts) != 2 {
+		return 0, ErrInvalidResumeToken
+	}
+	epoch, seq := parts[0], parts[1]
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || n == 0 {
+		return 0, ErrInvalidResumeToken
+	}
+	if epoch != bus.epoch {
+		return 0, ErrResumeTokenExpired
+	}
+	return n, nil
+}
@@ -0,0 +1,130 @@
+package blogstore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+// collectEvents watches store from resumeToken until n events arrived.
+func collectEvents(t *testing.T, store BlogStore, resumeToken string, n int) []*BlogEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var events []*BlogEvent
+	done := errors.New("done")
+	err := store.Watch(ctx, resumeToken, func(event *BlogEvent) error {
+		events = append(events, event)
+		if len(events) == n {
+			return done
+		}
+		return nil
+	})
+	if err != done {
+		t.Fatalf("Watch stopped after %d events with %v, want %d events", len(events), err, n)
+	}
+	return events
+}
+
+func TestWatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store BlogStore) {
+		ctx := context.Background()
+		events := watchEvents(t, store)
+
+		blog := mustCreate(t, store, &Blog{AuthorID: "peter", Title: "Title", Content: "content"})
+		update := *blog
+		update.Content = "changed"
+		if _, err := store.Update(ctx, &update, 0); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if _, err := store.SoftDelete(ctx, blog.ID, 0, "peter"); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+		if _, err := store.Undelete(ctx, blog.ID, 0, "peter"); err != nil {
+			t.Fatalf("Undelete failed: %v", err)
+		}
+		if err := store.Delete(ctx, blog.ID, 0); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+
+		want := []struct {
+			typ     EventType
+			version int64
+		}{
+			{EventCreated, 1},
+			{EventUpdated, 2},
+			{EventDeleted, 3},
+			{EventUpdated, 4},
+			{EventDeleted, 4},
+		}
+		var seen []*BlogEvent
+		for i, w := range want {
+			event := nextEvent(t, events)
+			if event.Type != w.typ || event.Blog.ID != blog.ID || event.Blog.Version != w.version {
+				t.Errorf("event %d is %v of %s at version %d, want %v of %s at version %d", i, event.Type, event.Blog.ID, event.Blog.Version, w.typ, blog.ID, w.version)
+			}
+			if event.ResumeToken == "" || event.Time.IsZero() {
+				t.Errorf("event %d has no resume token or time", i)
+			}
+			seen = append(seen, event)
+		}
+
+		//resuming replays everything after the token
+		resumed := collectEvents(t, store, seen[1].ResumeToken, 3)
+		for i, event := range resumed {
+			if event.ResumeToken != seen[i+2].ResumeToken {
+				t.Errorf("resumed event %d has token %q, want %q", i, event.ResumeToken, seen[i+2].ResumeToken)
+			}
+		}
+	})
+}
+
+func TestWatchResumeTokens(t *testing.T) {
+	other := newEventBus()
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"not base64", "!!!", ErrInvalidResumeToken},
+		{"no sequence", base64.RawURLEncoding.EncodeToString([]byte("epoch")), ErrInvalidResumeToken},
+		{"bad sequence", base64.RawURLEncoding.EncodeToString([]byte("epoch.x")), ErrInvalidResumeToken},
+		{"sequence 0", base64.RawURLEncoding.EncodeToString([]byte("epoch.0")), ErrInvalidResumeToken},
+		{"other store", other.token(1), ErrResumeTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, store BlogStore) {
+				err := store.Watch(context.Background(), tt.token, func(*BlogEvent) error { return nil })
+				wantErr(t, err, tt.want)
+			})
+		})
+	}
+}
+
+func TestEventBusHistory(t *testing.T) {
+	bus := newEventBus()
+	blog := &Blog{ID: missingID, Title: "Title"}
+	watch := func(token string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return bus.watch(ctx, token, func(*BlogEvent) error { return nil })
+	}
+
+	bus.publish(EventCreated, blog)
+	first := bus.token(1)
+	wantErr(t, watch(first), context.DeadlineExceeded)
+	//a token past the latest event was not handed out by this bus
+	wantErr(t, watch(bus.token(3)), ErrResumeTokenExpired)
+
+	//the token stays good as long as the event after it is kept
+	for i := 0; i < eventHistory; i++ {
+		bus.publish(EventUpdated, blog)
+	}
+	wantErr(t, watch(first), context.DeadlineExceeded)
+	bus.publish(EventUpdated, blog)
+	wantErr(t, watch(first), ErrResumeTokenExpired)
+	wantErr(t, watch(bus.token(2)), context.DeadlineExceeded)
+}
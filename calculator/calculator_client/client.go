@@ -6,11 +6,14 @@ import (
 	"io"
 	"log"
 	"math"
+	"os"
 	"time"
 
 	"github.com/Peter-Yocum/grpc-go-course/calculator/calculatorpb"
+	"github.com/Peter-Yocum/grpc-go-course/config"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"github.com/Peter-Yocum/grpc-go-course/tracing"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 )
@@ -18,7 +21,26 @@ import (
 func main() {
 	fmt.Println("Hello I'm a client")
 
-	conn, err := grpc.Dial("0.0.0.0:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	cfg := config.New("calculator_client")
+	tracingCfg := tracing.AddSettings(cfg)
+	if err := cfg.Load(os.Args[1:]); err != nil {
+		log.Fatalln(err)
+	}
+	stopTracing, err := tracing.Start(context.Background(), "calculator_client", tracingCfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer func() {
+		//export the spans still buffered
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := stopTracing(ctx); err != nil {
+			log.Printf("Failed to flush traces: %v\n", err)
+		}
+	}()
+
+	opts := append(tracing.DialOptions(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial("0.0.0.0:50051", opts...)
 	if err != nil {
 		log.Fatalf("could not connect: %v\n", err)
 	}
@@ -181,12 +203,11 @@ func sendSquareRootRequest(client calculatorpb.CalculatorServiceClient) {
 }
 
 func processSquareRootError(err error) {
-	respErr, ok := status.FromError(err)
+	_, ok := status.FromError(err)
 	if ok {
 		// my error from grpc
-		fmt.Println(respErr.Message())
-		fmt.Println(respErr.Code())
-		if respErr.Code() == codes.InvalidArgument {
+		fmt.Println(rpcerr.Decode(err))
+		if rpcerr.Reason(err) == "NEGATIVE_NUMBER" {
 			fmt.Println("We sent a negative number!")
 		}
 	} else {
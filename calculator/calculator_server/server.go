@@ -2,122 +2,81 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"io"
 	"log"
-	"math"
 	"net"
+	"os"
+	"time"
 
 	"github.com/Peter-Yocum/grpc-go-course/calculator/calculatorpb"
+	"github.com/Peter-Yocum/grpc-go-course/calculator/calculatorservice"
+	"github.com/Peter-Yocum/grpc-go-course/config"
+	"github.com/Peter-Yocum/grpc-go-course/graceful"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/metrics"
+	"github.com/Peter-Yocum/grpc-go-course/recovery"
+	"github.com/Peter-Yocum/grpc-go-course/tracing"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
-type server struct {
-	calculatorpb.CalculatorServiceServer
-}
-
-func (*server) Calculate(ctx context.Context, req *calculatorpb.CalculatorRequest) (*calculatorpb.CalculatorResponse, error) {
-	fmt.Printf("Calculate function was invoked with %v\n", req)
-	first_number := req.GetCalculation().GetFirstNumber()
-	second_number := req.GetCalculation().GetSecondNumber()
-	res := &calculatorpb.CalculatorResponse{
-		Result: first_number + second_number,
+func main() {
+	cfg := config.New("calculator")
+	srv := cfg.Server("0.0.0.0:50051")
+	tracingCfg := tracing.AddSettings(cfg)
+	logCfg := logging.AddSettings(cfg)
+	if err := cfg.Load(os.Args[1:]); err != nil {
+		//there is no logger before the settings are loaded
+		log.Fatalln(err)
 	}
-	return res, nil
-}
-
-func (*server) PrimeNumberDecomposition(req *calculatorpb.PrimeDecompositionRequest, stream calculatorpb.CalculatorService_PrimeNumberDecompositionServer) error {
-	fmt.Printf("Prime Decomposition function was invoked with %v\n", req)
-	prime := req.GetPrimeNumber()
-	factor := int64(2)
-	for prime > 1 {
-		if prime%factor == 0 {
-			res := &calculatorpb.PrimeDecompositionResponse{
-				Factor: factor,
-			}
-			stream.Send(res)
-			prime = prime / factor
-		} else {
-			factor++
-		}
-
+	logger, err := logging.New(logCfg)
+	if err != nil {
+		log.Fatalln(err)
 	}
-	return nil
-}
-
-func (*server) Average(stream calculatorpb.CalculatorService_AverageServer) error {
-	fmt.Print("Starting average calculation\n")
-	total := float32(0)
-	num_req := 0
-	for {
-		req, err := stream.Recv()
-		if err == io.EOF {
-			result := total / float32(num_req)
-			return stream.SendAndClose(&calculatorpb.AverageResponse{
-				Result: float32(result),
-			})
-		}
-		if err != nil {
-			log.Fatalf("error when trying to receive stream message in average: %v", err)
-		}
-		total += float32(req.GetNumber())
-		num_req++
-		fmt.Printf("Received another number to average, running total: %v, total numbers received: %v\n", total, num_req)
+	defer logger.Sync()
+	logger.Info("Calculator Service started", zap.String("listen", srv.Listen))
+	stopTracing, err := tracing.Start(context.Background(), "calculator_server", tracingCfg)
+	if err != nil {
+		logger.Fatal("Failed to start tracing", zap.Error(err))
 	}
-}
 
-func (*server) FindMaximum(stream calculatorpb.CalculatorService_FindMaximumServer) error {
-	fmt.Print("Starting Find Maximum\n")
-	current_max := float32(math.Inf(-1))
-	for {
-		req, recv_err := stream.Recv()
-		if recv_err == io.EOF {
-			return nil
-		}
-		if recv_err != nil {
-			log.Fatalf("error when trying to receive stream message in find maximum: %v", recv_err)
-			return recv_err
-		}
-		new_number := req.GetNextNumber()
-		if new_number > current_max {
-			current_max = new_number
-		}
-		send_err := stream.SendMsg(&calculatorpb.FindMaximumResponse{
-			CurrentMax: current_max,
-		})
-		if send_err != nil {
-			log.Fatalf("error when trying to send stream message in find maximum: %v", send_err)
-			return send_err
-		}
+	lis, err := net.Listen("tcp", srv.Listen)
+	if err != nil {
+		logger.Fatal("Failed to listen", zap.Error(err))
 	}
-}
 
-func (*server) SquareRoot(ctx context.Context, req *calculatorpb.SquareRootRequest) (*calculatorpb.SquareRootResponse, error) {
-	fmt.Printf("Square root function was invoked with %v\n", req)
-	number := req.GetNumber()
-	if number < 0 {
-		return nil, status.Errorf(codes.InvalidArgument, fmt.Sprintf("Received a negative number: %v", number))
-	}
-	res := &calculatorpb.SquareRootResponse{
-		NumberRoot: math.Sqrt(float64(number)),
+	opts, err := srv.ServerOptions()
+	if err != nil {
+		logger.Fatal("Failed to properly create credentials", zap.Error(err))
 	}
-	return res, nil
-}
-
-func main() {
-	fmt.Println("Hello World")
+	drain := graceful.New(srv.DrainTimeout)
+	opts = append(opts, tracing.ServerOptions()...)
+	opts = append(opts, logging.ServerOptions(logger)...)
+	opts = append(opts, metrics.ServerOptions()...)
+	opts = append(opts, recovery.ServerOptions()...)
+	s := grpc.NewServer(append(opts, drain.ServerOptions()...)...)
+	calculatorservice.Register(s)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(calculatorpb.CalculatorService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+	metrics.Register(s)
 
-	lis, err := net.Listen("tcp", "0.0.0.0:50051")
+	metricsServer, err := metrics.Serve(srv.MetricsListen)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v\n", err)
+		//the calls are served all the same, only without metrics
+		logger.Error("Failed to serve metrics", zap.Error(err))
+	} else if metricsServer != nil {
+		logger.Info("Serving metrics", zap.String("url", "http://"+srv.MetricsListen+"/metrics"))
 	}
 
-	s := grpc.NewServer()
-	calculatorpb.RegisterCalculatorServiceServer(s, &server{})
-
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve: %v\n", err)
+	if err := drain.Serve(s, lis, healthServer); err != nil {
+		logger.Fatal("Failed to serve", zap.Error(err))
+	}
+	metricsServer.Close()
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := stopTracing(flushCtx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
 	}
 }
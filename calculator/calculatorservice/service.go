@@ -0,0 +1,118 @@
+// Package calculatorservice implements the CalculatorService of calculator/calculatorpb.
+package calculatorservice
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/Peter-Yocum/grpc-go-course/calculator/calculatorpb"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// errorDomain is the ErrorInfo domain of errors returned by the service.
+const errorDomain = "calculator.CalculatorService"
+
+type server struct {
+	calculatorpb.CalculatorServiceServer
+}
+
+func (*server) Calculate(ctx context.Context, req *calculatorpb.CalculatorRequest) (*calculatorpb.CalculatorResponse, error) {
+	logging.FromContext(ctx).Debug("Calculate function was invoked", zap.Stringer("request", req))
+	first_number := req.GetCalculation().GetFirstNumber()
+	second_number := req.GetCalculation().GetSecondNumber()
+	res := &calculatorpb.CalculatorResponse{
+		Result: first_number + second_number,
+	}
+	return res, nil
+}
+
+func (*server) PrimeNumberDecomposition(req *calculatorpb.PrimeDecompositionRequest, stream calculatorpb.CalculatorService_PrimeNumberDecompositionServer) error {
+	logging.FromContext(stream.Context()).Debug("Prime Decomposition function was invoked", zap.Stringer("request", req))
+	prime := req.GetPrimeNumber()
+	factor := int64(2)
+	for prime > 1 {
+		if prime%factor == 0 {
+			res := &calculatorpb.PrimeDecompositionResponse{
+				Factor: factor,
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+			prime = prime / factor
+		} else {
+			factor++
+		}
+
+	}
+	return nil
+}
+
+func (*server) Average(stream calculatorpb.CalculatorService_AverageServer) error {
+	logger := logging.FromContext(stream.Context())
+	logger.Debug("Starting average calculation")
+	total := float32(0)
+	num_req := 0
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			result := total / float32(num_req)
+			return stream.SendAndClose(&calculatorpb.AverageResponse{
+				Result: float32(result),
+			})
+		}
+		if err != nil {
+			//the status of the stream, e.g. CANCELLED when the client went away
+			return err
+		}
+		total += float32(req.GetNumber())
+		num_req++
+		logger.Debug("Received another number to average", zap.Float32("running_total", total), zap.Int("numbers_received", num_req))
+	}
+}
+
+func (*server) FindMaximum(stream calculatorpb.CalculatorService_FindMaximumServer) error {
+	logging.FromContext(stream.Context()).Debug("Starting Find Maximum")
+	current_max := float32(math.Inf(-1))
+	for {
+		req, recv_err := stream.Recv()
+		if recv_err == io.EOF {
+			return nil
+		}
+		if recv_err != nil {
+			return recv_err
+		}
+		new_number := req.GetNextNumber()
+		if new_number > current_max {
+			current_max = new_number
+		}
+		send_err := stream.SendMsg(&calculatorpb.FindMaximumResponse{
+			CurrentMax: current_max,
+		})
+		if send_err != nil {
+			return send_err
+		}
+	}
+}
+
+func (*server) SquareRoot(ctx context.Context, req *calculatorpb.SquareRootRequest) (*calculatorpb.SquareRootResponse, error) {
+	logging.FromContext(ctx).Debug("Square root function was invoked", zap.Stringer("request", req))
+	number := req.GetNumber()
+	if number < 0 {
+		return nil, rpcerr.BadRequest(errorDomain, "NEGATIVE_NUMBER",
+			rpcerr.FieldViolation("number", fmt.Sprintf("cannot be negative, received %v", number)))
+	}
+	res := &calculatorpb.SquareRootResponse{
+		NumberRoot: math.Sqrt(float64(number)),
+	}
+	return res, nil
+}
+
+// Register registers the CalculatorService with s.
+func Register(s grpc.ServiceRegistrar) {
+	calculatorpb.RegisterCalculatorServiceServer(s, &server{})
+}
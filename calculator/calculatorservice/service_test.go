@@ -0,0 +1,164 @@
+package calculatorservice
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/calculator/calculatorpb"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient serves the CalculatorService and returns a client connected to
+// it.
+func newClient(t *testing.T) calculatorpb.CalculatorServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	Register(s)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return calculatorpb.NewCalculatorServiceClient(conn)
+}
+
+func TestCalculate(t *testing.T) {
+	client := newClient(t)
+	res, err := client.Calculate(context.Background(), &calculatorpb.CalculatorRequest{
+		Calculation: &calculatorpb.Calculation{FirstNumber: 3, SecondNumber: -10},
+	})
+	if err != nil {
+		t.Fatalf("Calculate failed: %v", err)
+	}
+	if res.GetResult() != -7 {
+		t.Errorf("Calculate() = %d, want -7", res.GetResult())
+	}
+}
+
+func TestPrimeNumberDecomposition(t *testing.T) {
+	client := newClient(t)
+	tests := []struct {
+		number int64
+		want   []int64
+	}{
+		{120, []int64{2, 2, 2, 3, 5}},
+		{13, []int64{13}},
+		{1, nil},
+		{0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatInt(tt.number, 10), func(t *testing.T) {
+			stream, err := client.PrimeNumberDecomposition(context.Background(), &calculatorpb.PrimeDecompositionRequest{PrimeNumber: tt.number})
+			if err != nil {
+				t.Fatalf("PrimeNumberDecomposition failed: %v", err)
+			}
+			var got []int64
+			for {
+				res, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Recv failed: %v", err)
+				}
+				got = append(got, res.GetFactor())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("factors of %d = %v, want %v", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAverage(t *testing.T) {
+	client := newClient(t)
+	stream, err := client.Average(context.Background())
+	if err != nil {
+		t.Fatalf("Average failed: %v", err)
+	}
+	for _, n := range []int64{1, 2, 3, 4} {
+		if err := stream.Send(&calculatorpb.AverageRequest{Number: n}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv failed: %v", err)
+	}
+	if res.GetResult() != 2.5 {
+		t.Errorf("Average() = %v, want 2.5", res.GetResult())
+	}
+}
+
+func TestFindMaximum(t *testing.T) {
+	client := newClient(t)
+	stream, err := client.FindMaximum(context.Background())
+	if err != nil {
+		t.Fatalf("FindMaximum failed: %v", err)
+	}
+	var got []float32
+	for _, n := range []float32{-3, 5, 1, 6, 2} {
+		if err := stream.Send(&calculatorpb.FindMaximumRequest{NextNumber: n}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		res, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		got = append(got, res.GetCurrentMax())
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Recv after CloseSend = %v, want EOF", err)
+	}
+	if want := []float32{-3, 5, 5, 6, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("maximums = %v, want %v", got, want)
+	}
+}
+
+func TestSquareRoot(t *testing.T) {
+	client := newClient(t)
+	tests := []struct {
+		number   int32
+		want     float64
+		wantCode codes.Code
+	}{
+		{16, 4, codes.OK},
+		{0, 0, codes.OK},
+		{-4, 0, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(int(tt.number)), func(t *testing.T) {
+			res, err := client.SquareRoot(context.Background(), &calculatorpb.SquareRootRequest{Number: tt.number})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("SquareRoot(%d) failed with %v, want %v", tt.number, err, tt.wantCode)
+			}
+			if tt.wantCode != codes.OK {
+				if !rpcerr.Is(err, errorDomain, "NEGATIVE_NUMBER") || rpcerr.FieldViolations(err)["number"] == nil {
+					t.Errorf("SquareRoot(%d) failed with %v, want NEGATIVE_NUMBER on number", tt.number, rpcerr.Decode(err))
+				}
+				return
+			}
+			if res.GetNumberRoot() != tt.want {
+				t.Errorf("SquareRoot(%d) = %v, want %v", tt.number, res.GetNumberRoot(), tt.want)
+			}
+		})
+	}
+}
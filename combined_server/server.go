@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/blog/blogservice"
+	"github.com/Peter-Yocum/grpc-go-course/calculator/calculatorpb"
+	"github.com/Peter-Yocum/grpc-go-course/calculator/calculatorservice"
+	"github.com/Peter-Yocum/grpc-go-course/config"
+	"github.com/Peter-Yocum/grpc-go-course/graceful"
+	"github.com/Peter-Yocum/grpc-go-course/greet/greetpb"
+	"github.com/Peter-Yocum/grpc-go-course/greet/greetservice"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/metrics"
+	"github.com/Peter-Yocum/grpc-go-course/recovery"
+	"github.com/Peter-Yocum/grpc-go-course/tracing"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+func main() {
+	cfg := config.New("combined")
+	srv := cfg.Server("localhost:50051")
+	var enableGreet, enableCalculator, enableBlog bool
+	cfg.Bool(&enableGreet, "enable-greet", true, "serve GreetService")
+	cfg.Bool(&enableCalculator, "enable-calculator", true, "serve CalculatorService")
+	cfg.Bool(&enableBlog, "enable-blog", true, "serve BlogService and CommentService")
+	blogCfg := blogservice.AddSettings(cfg)
+	tracingCfg := tracing.AddSettings(cfg)
+	logCfg := logging.AddSettings(cfg)
+	cfg.Check(func() error {
+		if !enableBlog {
+			return nil
+		}
+		return blogCfg.Validate()
+	})
+	cfg.Check(func() error {
+		if !enableGreet && !enableCalculator && !enableBlog {
+			return errors.New("every service is disabled, enable at least one")
+		}
+		return nil
+	})
+	if err := cfg.Load(os.Args[1:]); err != nil {
+		//there is no logger before the settings are loaded
+		log.Fatalln(err)
+	}
+	logger, err := logging.New(logCfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer logger.Sync()
+	logger.Info("Combined server started", zap.String("listen", srv.Listen))
+	stopTracing, err := tracing.Start(context.Background(), "combined_server", tracingCfg)
+	if err != nil {
+		logger.Fatal("Failed to start tracing", zap.Error(err))
+	}
+
+	opts, err := srv.ServerOptions()
+	if err != nil {
+		logger.Fatal("Failed to load the server options", zap.Error(err))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	drain := graceful.New(srv.DrainTimeout)
+	//tracing, logging, metrics and recovery first, so they see the calls
+	//refused by the blog auth too
+	opts = append(opts, tracing.ServerOptions()...)
+	opts = append(opts, logging.ServerOptions(logger)...)
+	opts = append(opts, metrics.ServerOptions()...)
+	opts = append(opts, recovery.ServerOptions()...)
+	opts = append(opts, drain.ServerOptions()...)
+	var blogs *blogservice.Service
+	if enableBlog {
+		blogs, err = blogservice.New(ctx, blogCfg)
+		if err != nil {
+			logger.Fatal("Failed to start the blog service", zap.Error(err))
+		}
+		drain.OnDrain(blogs.Drain)
+		//the blog interceptors pass calls to the other services through
+		opts = append(opts, blogs.ServerOptions()...)
+	}
+
+	lis, err := net.Listen("tcp", srv.Listen)
+	if err != nil {
+		logger.Fatal("Failed to listen", zap.Error(err))
+	}
+
+	s := grpc.NewServer(opts...)
+	healthServer := health.NewServer()
+	if enableGreet {
+		logger.Info("Serving GreetService")
+		greetservice.Register(s)
+		healthServer.SetServingStatus(greetpb.GreetService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	}
+	if enableCalculator {
+		logger.Info("Serving CalculatorService")
+		calculatorservice.Register(s)
+		healthServer.SetServingStatus(calculatorpb.CalculatorService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	}
+	if enableBlog {
+		logger.Info("Serving BlogService and CommentService")
+		blogs.Register(s)
+		//follows the blog store, NOT_SERVING while it is unreachable
+		blogs.StartHealthReports(healthServer)
+	}
+	healthpb.RegisterHealthServer(s, healthServer)
+	reflection.Register(s)
+	metrics.Register(s)
+
+	metricsServer, err := metrics.Serve(srv.MetricsListen)
+	if err != nil {
+		//the calls are served all the same, only without metrics
+		logger.Error("Failed to serve metrics", zap.Error(err))
+	} else if metricsServer != nil {
+		logger.Info("Serving metrics", zap.String("url", "http://"+srv.MetricsListen+"/metrics"))
+	}
+	logger.Info("Starting Server...")
+	if err := drain.Serve(s, lis, healthServer); err != nil {
+		logger.Fatal("Failed to serve", zap.Error(err))
+	}
+	metricsServer.Close()
+
+	if blogs != nil {
+		//every handler has returned, nothing uses the store any more
+		logger.Info("Closing the blog store...")
+		closeCtx, cancelClose := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelClose()
+		if err := blogs.Close(closeCtx); err != nil {
+			logger.Error("Failed to close the blog store", zap.Error(err))
+		}
+	}
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := stopTracing(flushCtx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
+	}
+	logger.Info("Ending the program.")
+}
@@ -0,0 +1,158 @@
+// Package config loads the settings of the blog, calculator and greet
+// servers, and the few settings of their clients.
+//
+// A server registers each setting once under a name like "mongo-uri". The
+// setting can then be given, from lowest to highest precedence, as its
+// default, in a YAML file passed with -config, in an environment variable
+// named after the server and the setting, e.g. BLOG_MONGO_URI, or as the
+// flag -mongo-uri. The config file is a flat mapping of setting names to
+// values:
+//
+//	listen: 0.0.0.0:50051
+//	mongo-uri: mongodb://db:27017
+//	trash-retention: 168h
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Loader collects the settings of a server and loads their values.
+type Loader struct {
+	envPrefix  string
+	flags      *flag.FlagSet
+	configPath string
+	checks     []func() error
+}
+
+// New returns a loader for the server called name. Its environment variables
+// start with name in upper case, e.g. BLOG_ for "blog".
+func New(name string) *Loader {
+	l := &Loader{
+		envPrefix: strings.ToUpper(name) + "_",
+		flags:     flag.NewFlagSet(name, flag.ExitOnError),
+	}
+	l.flags.StringVar(&l.configPath, "config", os.Getenv(l.envPrefix+"CONFIG"),
+		"YAML file with settings, flags and environment variables override it (env "+l.envPrefix+"CONFIG)")
+	return l
+}
+
+// String registers a string setting stored in p.
+func (l *Loader) String(p *string, name, value, usage string) {
+	l.flags.StringVar(p, name, value, l.usage(name, usage))
+}
+
+// Bool registers a bool setting stored in p.
+func (l *Loader) Bool(p *bool, name string, value bool, usage string) {
+	l.flags.BoolVar(p, name, value, l.usage(name, usage))
+}
+
+// Duration registers a duration setting stored in p, written like "90s".
+func (l *Loader) Duration(p *time.Duration, name string, value time.Duration, usage string) {
+	l.flags.DurationVar(p, name, value, l.usage(name, usage))
+}
+
+// Check adds a validation run by Load once every setting has its value.
+func (l *Loader) Check(fn func() error) {
+	l.checks = append(l.checks, fn)
+}
+
+// Load parses the command line args, without the program name, then fills
+// the settings not given as flags from the environment and the config file,
+// and validates the result.
+func (l *Loader) Load(args []string) error {
+	l.flags.Parse(args)
+	if l.flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", l.flags.Args())
+	}
+	set := map[string]bool{}
+	l.flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if l.configPath != "" {
+		values, err := readFile(l.configPath)
+		if err != nil {
+			return err
+		}
+		for name, value := range values {
+			if name == "config" || l.flags.Lookup(name) == nil {
+				return fmt.Errorf("%v: unknown setting %q", l.configPath, name)
+			}
+			if set[name] {
+				continue
+			}
+			if err := l.flags.Set(name, value); err != nil {
+				return fmt.Errorf("%v: %v: invalid value %q: %w", l.configPath, name, value, err)
+			}
+		}
+	}
+
+	var err error
+	l.flags.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] || f.Name == "config" {
+			return
+		}
+		env := l.envName(f.Name)
+		if value, ok := os.LookupEnv(env); ok {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("%v: invalid value %q: %w", env, value, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, check := range l.checks {
+		if err := check(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// envName returns the environment variable of a setting, e.g. BLOG_MONGO_URI
+// for "mongo-uri".
+func (l *Loader) envName(name string) string {
+	return l.envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func (l *Loader) usage(name, usage string) string {
+	return fmt.Sprintf("%v (env %v)", usage, l.envName(name))
+}
+
+// readFile reads the setting values of a config file.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("%v: %v: must be a single value", path, name)
+		case nil:
+			values[name] = ""
+		default:
+			values[name] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
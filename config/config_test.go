@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// settings are the values of a loader registering a few test settings.
+type settings struct {
+	uri     string
+	soft    bool
+	timeout time.Duration
+}
+
+func newTestLoader() (*Loader, *settings) {
+	l := New("test")
+	s := &settings{}
+	l.String(&s.uri, "mongo-uri", "default", "")
+	l.Bool(&s.soft, "soft-delete", false, "")
+	l.Duration(&s.timeout, "drain-timeout", time.Second, "")
+	return l, s
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeConfig(t, "mongo-uri: file\nsoft-delete: true\ndrain-timeout: 1m\n")
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want settings
+	}{
+		{"defaults", nil, nil, settings{"default", false, time.Second}},
+		{"file", nil, []string{"-config", file}, settings{"file", true, time.Minute}},
+		{"file from env", map[string]string{"TEST_CONFIG": file}, nil, settings{"file", true, time.Minute}},
+		{"env over file", map[string]string{"TEST_MONGO_URI": "env", "TEST_SOFT_DELETE": "false"}, []string{"-config", file}, settings{"env", false, time.Minute}},
+		{"flag over env", map[string]string{"TEST_MONGO_URI": "env"}, []string{"-config", file, "-mongo-uri", "flag"}, settings{"flag", true, time.Minute}},
+		{"flag over file", nil, []string{"-config", file, "-drain-timeout", "5s"}, settings{"file", true, 5 * time.Second}},
+		{"env without file", map[string]string{"TEST_DRAIN_TIMEOUT": "2s"}, nil, settings{"default", false, 2 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			l, got := newTestLoader()
+			if err := l.Load(tt.args); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("Load() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		// checks are the results of the checks added to the loader.
+		checks []error
+		want   string
+	}{
+		{"unknown setting", "mongo: x\n", nil, nil, nil, `unknown setting "mongo"`},
+		{"config in file", "config: other.yaml\n", nil, nil, nil, `unknown setting "config"`},
+		{"nested value", "mongo-uri:\n  host: db\n", nil, nil, nil, "must be a single value"},
+		{"invalid file value", "drain-timeout: soon\n", nil, nil, nil, `drain-timeout: invalid value "soon"`},
+		{"invalid env value", "", map[string]string{"TEST_SOFT_DELETE": "maybe"}, nil, nil, `TEST_SOFT_DELETE: invalid value "maybe"`},
+		{"arguments", "", nil, []string{"extra"}, nil, "unexpected arguments"},
+		{"failed checks", "", nil, nil, []error{errors.New("first"), nil, errors.New("second")}, "invalid configuration: first; second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			l, _ := newTestLoader()
+			for _, err := range tt.checks {
+				err := err
+				l.Check(func() error { return err })
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfig(t, tt.file)}, args...)
+			}
+			err := l.Load(args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() failed with %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  Server
+		wantErr bool
+	}{
+		{"valid", Server{Listen: "0.0.0.0:50051", MetricsListen: "localhost:9090"}, false},
+		{"no metrics", Server{Listen: ":50051"}, false},
+		{"no port", Server{Listen: "localhost"}, true},
+		{"bad port", Server{Listen: "localhost:70000"}, true},
+		{"bad metrics port", Server{Listen: ":50051", MetricsListen: "localhost:x"}, true},
+		{"negative drain timeout", Server{Listen: ":50051", DrainTimeout: -time.Second}, true},
+		{"tls without key", Server{Listen: ":50051", TLS: true, TLSCert: "server.crt"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.server.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerDefaults(t *testing.T) {
+	l := New("test")
+	s := l.Server(":50051")
+	if err := l.Load(nil); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	//several servers may run on one host, none may claim a metrics port
+	if s.MetricsListen != "" {
+		t.Errorf("metrics-listen defaults to %q, want it off", s.MetricsListen)
+	}
+}
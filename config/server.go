@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Server holds the settings every server has: where it listens, whether it
+// serves TLS and how it shuts down.
+type Server struct {
+	Listen  string
+	TLS     bool
+	TLSCert string
+	TLSKey  string
+	// DrainTimeout is how long a shutdown waits for calls in flight.
+	DrainTimeout time.Duration
+	// MetricsListen is where the Prometheus metrics are served over HTTP,
+	// empty when they are not.
+	MetricsListen string
+}
+
+// Server registers the shared server settings, listening on listen unless
+// configured otherwise.
+func (l *Loader) Server(listen string) *Server {
+	s := &Server{}
+	l.String(&s.Listen, "listen", listen, "host:port to listen on")
+	l.Bool(&s.TLS, "tls", false, "serve over TLS with tls-cert and tls-key")
+	l.String(&s.TLSCert, "tls-cert", "ssl/server.crt", "PEM certificate served with tls")
+	l.String(&s.TLSKey, "tls-key", "ssl/server.pem", "PEM private key of tls-cert")
+	l.Duration(&s.DrainTimeout, "drain-timeout", 15*time.Second, "how long a shutdown waits for calls in flight before cancelling them")
+	l.String(&s.MetricsListen, "metrics-listen", "", "host:port serving Prometheus metrics on /metrics, off when empty")
+	l.Check(s.validate)
+	return s
+}
+
+func (s *Server) validate() error {
+	if err := checkAddr(s.Listen); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	if s.MetricsListen != "" {
+		if err := checkAddr(s.MetricsListen); err != nil {
+			return fmt.Errorf("metrics-listen: %w", err)
+		}
+	}
+	if s.DrainTimeout < 0 {
+		return fmt.Errorf("drain-timeout can't be negative, got %v", s.DrainTimeout)
+	}
+	if s.TLS && (s.TLSCert == "" || s.TLSKey == "") {
+		return errors.New("tls needs both tls-cert and tls-key")
+	}
+	return nil
+}
+
+// checkAddr makes sure addr is a host:port with a valid port.
+func checkAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// ServerOptions returns the grpc options applying the settings, the TLS
+// credentials when TLS is on.
+func (s *Server) ServerOptions() ([]grpc.ServerOption, error) {
+	if !s.TLS {
+		return nil, nil
+	}
+	creds, err := credentials.NewServerTLSFromFile(s.TLSCert, s.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS credentials: %w", err)
+	}
+	return []grpc.ServerOption{grpc.Creds(creds)}, nil
+}
@@ -0,0 +1,107 @@
+// Package graceful serves a grpc server until SIGINT or SIGTERM and then
+// shuts it down without cutting off calls in flight, unless they take too
+// long to finish.
+package graceful
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+)
+
+// Server tracks the handlers running on a grpc server so that Serve can
+// wait for them.
+type Server struct {
+	drainTimeout time.Duration
+	handlers     sync.WaitGroup
+	onDrain      []func()
+}
+
+// New returns a Server giving calls in flight drainTimeout to finish once a
+// shutdown starts.
+func New(drainTimeout time.Duration) *Server {
+	return &Server{drainTimeout: drainTimeout}
+}
+
+// ServerOptions returns the interceptors counting the running handlers,
+// they must be installed on the grpc server passed to Serve.
+func (g *Server) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			g.handlers.Add(1)
+			defer g.handlers.Done()
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			g.handlers.Add(1)
+			defer g.handlers.Done()
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// OnDrain adds fn to the functions called when the shutdown starts, e.g. to
+// end streams that would otherwise never finish.
+func (g *Server) OnDrain(fn func()) {
+	g.onDrain = append(g.onDrain, fn)
+}
+
+// Serve serves s on lis until the process gets SIGINT or SIGTERM. It then
+// reports every service of hs, if not nil, as NOT_SERVING, stops accepting
+// calls and waits up to the drain timeout for those in flight before
+// cancelling them. A second signal cancels them right away. Serve returns
+// once every handler has returned, so whatever they use can be closed.
+func (g *Server) Serve(s *grpc.Server, lis net.Listener, hs *health.Server) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(lis)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case sig := <-signals:
+		zap.L().Info("Received signal, stopping the server", zap.Stringer("signal", sig))
+	}
+
+	if hs != nil {
+		hs.Shutdown()
+	}
+	for _, fn := range g.onDrain {
+		fn()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	timer := time.NewTimer(g.drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		zap.L().Warn("Calls still running after the drain timeout, cancelling them", zap.Duration("drain_timeout", g.drainTimeout))
+		s.Stop()
+	case sig := <-signals:
+		zap.L().Warn("Received signal again, cancelling the calls still running", zap.Stringer("signal", sig))
+		s.Stop()
+	}
+	<-stopped
+
+	//Stop cancels the calls but doesn't wait for their handlers to return
+	g.handlers.Wait()
+	zap.L().Info("Server stopped")
+	return nil
+}
@@ -0,0 +1,142 @@
+package graceful
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/greet/greetpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// slowServer answers Greet once release is closed, or gives up when the
+// call is cancelled.
+type slowServer struct {
+	greetpb.UnimplementedGreetServiceServer
+	started chan struct{}
+	release chan struct{}
+	// returned is set once the handler has returned.
+	returned int32
+}
+
+func (s *slowServer) Greet(ctx context.Context, req *greetpb.GreetRequest) (*greetpb.GreetResponse, error) {
+	defer atomic.StoreInt32(&s.returned, 1)
+	close(s.started)
+	select {
+	case <-s.release:
+		return &greetpb.GreetResponse{Result: "done"}, nil
+	case <-ctx.Done():
+		//cleaning up takes a moment, Serve must wait for it
+		time.Sleep(50 * time.Millisecond)
+		return nil, ctx.Err()
+	}
+}
+
+// interrupt sends SIGINT to the test process, which Serve catches.
+func interrupt(t *testing.T) {
+	t.Helper()
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Fatalf("sending SIGINT failed: %v", err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name string
+		// finish tells whether the call finishes within the drain timeout.
+		finish bool
+	}{
+		{"drained", true},
+		{"cancelled after the drain timeout", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New(200 * time.Millisecond)
+			slow := &slowServer{started: make(chan struct{}), release: make(chan struct{})}
+			drained := make(chan struct{})
+			g.OnDrain(func() {
+				//the signal gives the race detector no order between the
+				//call starting and the drain, waiting for started does
+				<-slow.started
+				close(drained)
+			})
+			s := grpc.NewServer(g.ServerOptions()...)
+			greetpb.RegisterGreetServiceServer(s, slow)
+			hs := health.NewServer()
+			healthpb.RegisterHealthServer(s, hs)
+
+			lis := bufconn.Listen(1 << 20)
+			served := make(chan error, 1)
+			go func() {
+				served <- g.Serve(s, lis, hs)
+			}()
+			conn, err := grpc.Dial("bufnet",
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+				grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			//Serve catches signals once the server runs
+			called := make(chan error, 1)
+			go func() {
+				_, err := greetpb.NewGreetServiceClient(conn).Greet(context.Background(), &greetpb.GreetRequest{})
+				called <- err
+			}()
+			select {
+			case <-slow.started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("call did not start")
+			}
+			interrupt(t)
+
+			select {
+			case <-drained:
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnDrain functions were not called")
+			}
+			res, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			if err != nil || res.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+				t.Errorf("health = %v, %v, want NOT_SERVING while draining", res.GetStatus(), err)
+			}
+			if tt.finish {
+				close(slow.release)
+			}
+
+			select {
+			case err := <-served:
+				if err != nil {
+					t.Errorf("Serve failed: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Serve did not return")
+			}
+			if atomic.LoadInt32(&slow.returned) == 0 {
+				t.Error("Serve returned before the handler did")
+			}
+			if err := <-called; (err == nil) != tt.finish {
+				t.Errorf("call failed with %v, want it to finish %v", err, tt.finish)
+			}
+		})
+	}
+}
+
+func TestServeListenerError(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	lis.Close()
+	if err := New(time.Second).Serve(grpc.NewServer(), lis, nil); err == nil {
+		t.Error("Serve on a closed listener succeeded")
+	}
+}
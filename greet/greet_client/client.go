@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"time"
 
+	"github.com/Peter-Yocum/grpc-go-course/config"
 	"github.com/Peter-Yocum/grpc-go-course/greet/greetpb"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"github.com/Peter-Yocum/grpc-go-course/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -17,6 +21,24 @@ import (
 func main() {
 	fmt.Println("Hello I'm a client")
 
+	cfg := config.New("greet_client")
+	tracingCfg := tracing.AddSettings(cfg)
+	if err := cfg.Load(os.Args[1:]); err != nil {
+		log.Fatalln(err)
+	}
+	stopTracing, err := tracing.Start(context.Background(), "greet_client", tracingCfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer func() {
+		//export the spans still buffered
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := stopTracing(ctx); err != nil {
+			log.Printf("Failed to flush traces: %v\n", err)
+		}
+	}()
+
 	tls := false
 	opts := grpc.WithInsecure()
 	if tls {
@@ -28,7 +50,7 @@ func main() {
 		opts = grpc.WithTransportCredentials(creds)
 	}
 
-	conn, err := grpc.Dial("localhost:50051", opts)
+	conn, err := grpc.Dial("localhost:50051", append(tracing.DialOptions(), opts)...)
 	if err != nil {
 		log.Fatalf("could not connect: %v\n", err)
 	}
@@ -207,7 +229,7 @@ func sendGreetWithDeadline(client greetpb.GreetServiceClient, seconds time.Durat
 		statusErr, ok := status.FromError(err)
 		if ok {
 			if statusErr.Code() == codes.DeadlineExceeded {
-				log.Println("Deadline was exceeded")
+				log.Printf("Deadline was exceeded: %v\n", rpcerr.Decode(err))
 			} else {
 				log.Fatalf("grpc error while calling greet with deadline rpc: %v\n", err)
 			}
@@ -2,136 +2,84 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"io"
 	"log"
 	"net"
-	"strconv"
+	"os"
 	"time"
 
+	"github.com/Peter-Yocum/grpc-go-course/config"
+	"github.com/Peter-Yocum/grpc-go-course/graceful"
 	"github.com/Peter-Yocum/grpc-go-course/greet/greetpb"
+	"github.com/Peter-Yocum/grpc-go-course/greet/greetservice"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/metrics"
+	"github.com/Peter-Yocum/grpc-go-course/recovery"
+	"github.com/Peter-Yocum/grpc-go-course/tracing"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
-	"google.golang.org/grpc/status"
 )
 
-type server struct {
-	greetpb.GreetServiceServer
-}
-
-func (*server) Greet(ctx context.Context, req *greetpb.GreetRequest) (*greetpb.GreetResponse, error) {
-	fmt.Printf("Greet function was invoked with %v\n", req)
-	firstname := req.GetGreeting().GetFirstName()
-	result := "hello " + firstname
-	res := &greetpb.GreetResponse{
-		Result: result,
+func main() {
+	cfg := config.New("greet")
+	srv := cfg.Server("localhost:50051")
+	tracingCfg := tracing.AddSettings(cfg)
+	logCfg := logging.AddSettings(cfg)
+	if err := cfg.Load(os.Args[1:]); err != nil {
+		//there is no logger before the settings are loaded
+		log.Fatalln(err)
 	}
-	return res, nil
-}
-
-func (*server) GreetManyTimes(req *greetpb.GreetManyTimesRequest, stream greetpb.GreetService_GreetManyTimesServer) error {
-	fmt.Printf("GreetManyTimes function was invoked with %v\n", req)
-	firstname := req.GetGreeting().GetFirstName()
-	for i := 0; i < 10; i++ {
-		result := "Hello " + firstname + " number " + strconv.Itoa(i)
-		res := &greetpb.GreetManyTimesResponse{
-			Result: result,
-		}
-		stream.Send(res)
-		time.Sleep(1000 * time.Millisecond)
+	logger, err := logging.New(logCfg)
+	if err != nil {
+		log.Fatalln(err)
 	}
-	return nil
-}
-
-func (*server) LongGreet(stream greetpb.GreetService_LongGreetServer) error {
-	fmt.Printf("LongGreet function was invoked with %v\n", stream)
-	result := ""
-	for {
-		req, err := stream.Recv()
-		if err == io.EOF {
-			//finished reading client stream
-			return stream.SendAndClose(&greetpb.LongGreetResponse{
-				Result: result,
-			})
-		}
-		if err != nil {
-			log.Fatalf("Error while reading client stream: %v", err)
-		}
-		firstname := req.Greeting.GetFirstName()
-		result += "Hello " + firstname + "! "
+	defer logger.Sync()
+	logger.Info("Greet Service started", zap.String("listen", srv.Listen))
+	stopTracing, err := tracing.Start(context.Background(), "greet_server", tracingCfg)
+	if err != nil {
+		logger.Fatal("Failed to start tracing", zap.Error(err))
 	}
-}
-
-func (*server) GreetEveryone(stream greetpb.GreetService_GreetEveryoneServer) error {
-	fmt.Printf("GreetEveryone function was invoked with %v\n", stream)
 
-	for {
-		req, recv_err := stream.Recv()
-		if recv_err == io.EOF {
-			return nil
-		}
-		if recv_err != nil {
-			log.Fatalf("Error when trying to receive req from stream: %v\n", recv_err)
-			return recv_err
-		}
-		firstname := req.GetGreeting().FirstName
-		result := "Hello " + firstname + "! "
-		send_err := stream.SendMsg(&greetpb.GreetEveryoneResponse{
-			Result: result,
-		})
-		if send_err != nil {
-			log.Fatalf("Error when trying to send response to stream: %v\n", send_err)
-			return send_err
-		}
+	lis, err := net.Listen("tcp", srv.Listen)
+	if err != nil {
+		logger.Fatal("Failed to listen", zap.Error(err))
 	}
-}
 
-func (*server) GreetWithDeadline(ctx context.Context, req *greetpb.GreetWithDeadlineRequest) (*greetpb.GreetWithDeadlineResponse, error) {
-	log.Printf("Greet with deadline function was invoked with %v\n", req)
-	for i := 0; i < 3; i++ {
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Println("The client deadline has been exceeded!")
-			return nil, status.Error(codes.DeadlineExceeded, "The client deadline was exceeded")
-		}
-		log.Println("sleeping for 1 second...")
-		time.Sleep(1 * time.Second)
-	}
-	firstname := req.GetGreeting().GetFirstName()
-	result := "hello " + firstname
-	res := &greetpb.GreetWithDeadlineResponse{
-		Result: result,
+	opts, err := srv.ServerOptions()
+	if err != nil {
+		logger.Fatal("Failed to properly create credentials", zap.Error(err))
 	}
-	return res, nil
-}
+	drain := graceful.New(srv.DrainTimeout)
+	opts = append(opts, tracing.ServerOptions()...)
+	opts = append(opts, logging.ServerOptions(logger)...)
+	opts = append(opts, metrics.ServerOptions()...)
+	opts = append(opts, recovery.ServerOptions()...)
+	s := grpc.NewServer(append(opts, drain.ServerOptions()...)...)
+	greetservice.Register(s)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(greetpb.GreetService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
 
-func main() {
-	fmt.Println("Hello World")
+	reflection.Register(s)
+	metrics.Register(s)
 
-	lis, err := net.Listen("tcp", "localhost:50051")
+	metricsServer, err := metrics.Serve(srv.MetricsListen)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v\n", err)
+		//the calls are served all the same, only without metrics
+		logger.Error("Failed to serve metrics", zap.Error(err))
+	} else if metricsServer != nil {
+		logger.Info("Serving metrics", zap.String("url", "http://"+srv.MetricsListen+"/metrics"))
 	}
 
-	tls := false
-	opts := []grpc.ServerOption{}
-	if tls {
-		cert_file := "ssl/server.crt"
-		key_file := "ssl/server.pem"
-		creds, sslErr := credentials.NewServerTLSFromFile(cert_file, key_file)
-		if sslErr != nil {
-			log.Fatalf("Failed to properly create credentials: %v", sslErr)
-		}
-		opts = append(opts, grpc.Creds(creds))
+	if err := drain.Serve(s, lis, healthServer); err != nil {
+		logger.Fatal("Failed to serve", zap.Error(err))
 	}
-
-	s := grpc.NewServer(opts...)
-	greetpb.RegisterGreetServiceServer(s, &server{})
-
-	reflection.Register(s)
-
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve: %v\n", err)
+	metricsServer.Close()
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := stopTracing(flushCtx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
 	}
 }
@@ -0,0 +1,115 @@
+// Package greetservice implements the GreetService of greet/greetpb.
+package greetservice
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/greet/greetpb"
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// errorDomain is the ErrorInfo domain of errors returned by the service.
+const errorDomain = "greet.GreetService"
+
+type server struct {
+	greetpb.GreetServiceServer
+}
+
+func (*server) Greet(ctx context.Context, req *greetpb.GreetRequest) (*greetpb.GreetResponse, error) {
+	logging.FromContext(ctx).Debug("Greet function was invoked", zap.Stringer("request", req))
+	firstname := req.GetGreeting().GetFirstName()
+	result := "hello " + firstname
+	res := &greetpb.GreetResponse{
+		Result: result,
+	}
+	return res, nil
+}
+
+func (*server) GreetManyTimes(req *greetpb.GreetManyTimesRequest, stream greetpb.GreetService_GreetManyTimesServer) error {
+	logging.FromContext(stream.Context()).Debug("GreetManyTimes function was invoked", zap.Stringer("request", req))
+	firstname := req.GetGreeting().GetFirstName()
+	for i := 0; i < 10; i++ {
+		result := "Hello " + firstname + " number " + strconv.Itoa(i)
+		res := &greetpb.GreetManyTimesResponse{
+			Result: result,
+		}
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+		time.Sleep(1000 * time.Millisecond)
+	}
+	return nil
+}
+
+func (*server) LongGreet(stream greetpb.GreetService_LongGreetServer) error {
+	logging.FromContext(stream.Context()).Debug("LongGreet function was invoked")
+	result := ""
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			//finished reading client stream
+			return stream.SendAndClose(&greetpb.LongGreetResponse{
+				Result: result,
+			})
+		}
+		if err != nil {
+			//the status of the stream, e.g. CANCELLED when the client went away
+			return err
+		}
+		firstname := req.Greeting.GetFirstName()
+		result += "Hello " + firstname + "! "
+	}
+}
+
+func (*server) GreetEveryone(stream greetpb.GreetService_GreetEveryoneServer) error {
+	logging.FromContext(stream.Context()).Debug("GreetEveryone function was invoked")
+
+	for {
+		req, recv_err := stream.Recv()
+		if recv_err == io.EOF {
+			return nil
+		}
+		if recv_err != nil {
+			return recv_err
+		}
+		firstname := req.GetGreeting().FirstName
+		result := "Hello " + firstname + "! "
+		send_err := stream.SendMsg(&greetpb.GreetEveryoneResponse{
+			Result: result,
+		})
+		if send_err != nil {
+			return send_err
+		}
+	}
+}
+
+func (*server) GreetWithDeadline(ctx context.Context, req *greetpb.GreetWithDeadlineRequest) (*greetpb.GreetWithDeadlineResponse, error) {
+	logger := logging.FromContext(ctx)
+	logger.Debug("Greet with deadline function was invoked", zap.Stringer("request", req))
+	for i := 0; i < 3; i++ {
+		if ctx.Err() == context.DeadlineExceeded {
+			logger.Info("The client deadline has been exceeded!")
+			return nil, rpcerr.New(codes.DeadlineExceeded, errorDomain, "DEADLINE_EXCEEDED", "The client deadline was exceeded")
+		}
+		logger.Debug("sleeping for 1 second...")
+		time.Sleep(1 * time.Second)
+	}
+	firstname := req.GetGreeting().GetFirstName()
+	result := "hello " + firstname
+	res := &greetpb.GreetWithDeadlineResponse{
+		Result: result,
+	}
+	return res, nil
+}
+
+// Register registers the GreetService with s.
+func Register(s grpc.ServiceRegistrar) {
+	greetpb.RegisterGreetServiceServer(s, &server{})
+}
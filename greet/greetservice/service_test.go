@@ -0,0 +1,106 @@
+package greetservice
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/greet/greetpb"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient serves the GreetService and returns a client connected to it.
+func newClient(t *testing.T) greetpb.GreetServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	Register(s)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return greetpb.NewGreetServiceClient(conn)
+}
+
+func TestGreet(t *testing.T) {
+	client := newClient(t)
+	res, err := client.Greet(context.Background(), &greetpb.GreetRequest{Greeting: &greetpb.Greeting{FirstName: "Peter"}})
+	if err != nil {
+		t.Fatalf("Greet failed: %v", err)
+	}
+	if res.GetResult() != "hello Peter" {
+		t.Errorf("Greet() = %q, want hello Peter", res.GetResult())
+	}
+}
+
+func TestLongGreet(t *testing.T) {
+	client := newClient(t)
+	stream, err := client.LongGreet(context.Background())
+	if err != nil {
+		t.Fatalf("LongGreet failed: %v", err)
+	}
+	for _, name := range []string{"Peter", "Anna"} {
+		if err := stream.Send(&greetpb.LongGreetRequest{Greeting: &greetpb.Greeting{FirstName: name}}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv failed: %v", err)
+	}
+	if want := "Hello Peter! Hello Anna! "; res.GetResult() != want {
+		t.Errorf("LongGreet() = %q, want %q", res.GetResult(), want)
+	}
+}
+
+func TestGreetEveryone(t *testing.T) {
+	client := newClient(t)
+	stream, err := client.GreetEveryone(context.Background())
+	if err != nil {
+		t.Fatalf("GreetEveryone failed: %v", err)
+	}
+	var got []string
+	for _, name := range []string{"Peter", "Anna"} {
+		if err := stream.Send(&greetpb.GreetEveryoneRequest{Greeting: &greetpb.Greeting{FirstName: name}}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		res, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		got = append(got, res.GetResult())
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Recv after CloseSend = %v, want EOF", err)
+	}
+	if want := []string{"Hello Peter! ", "Hello Anna! "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("greetings = %q, want %q", got, want)
+	}
+}
+
+func TestGreetWithDeadlineExceeded(t *testing.T) {
+	//the deadline has passed before the call even starts
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	_, err := (&server{}).GreetWithDeadline(ctx, &greetpb.GreetWithDeadlineRequest{Greeting: &greetpb.Greeting{FirstName: "Peter"}})
+	if status.Code(err) != codes.DeadlineExceeded || !rpcerr.Is(err, errorDomain, "DEADLINE_EXCEEDED") {
+		t.Errorf("GreetWithDeadline() failed with %v, want DEADLINE_EXCEEDED", rpcerr.Decode(err))
+	}
+}
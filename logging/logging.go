@@ -0,0 +1,199 @@
+// Package logging sets up the structured, leveled logger of the servers and
+// the interceptors giving every call a logger of its own, carrying the
+// request id, the method and the peer of the call.
+//
+// Code handling a call logs with FromContext(ctx), code outside of calls
+// with zap.L(), the logger installed by New.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/config"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Formats accepted by the log-format setting.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// RequestIDKey is the metadata key of the request id. A client may send
+// one, otherwise the server picks one. Either way the server returns it in
+// the response header.
+const RequestIDKey = "x-request-id"
+
+// maxRequestIDLength caps the length of the request ids taken from clients.
+const maxRequestIDLength = 128
+
+// healthCheckMethod is logged at debug level, load balancers and
+// orchestrators call it every few seconds.
+const healthCheckMethod = "/grpc.health.v1.Health/Check"
+
+// Config holds the logging settings.
+type Config struct {
+	Level  string
+	Format string
+}
+
+// AddSettings registers the logging settings with cfg and validates them.
+func AddSettings(cfg *config.Loader) *Config {
+	c := &Config{}
+	cfg.String(&c.Level, "log-level", "info", "lowest level logged: debug, info, warn or error")
+	cfg.String(&c.Format, "log-format", FormatConsole, "log format: console or json")
+	cfg.Check(c.validate)
+	return c
+}
+
+func (c *Config) validate() error {
+	if _, err := parseLevel(c.Level); err != nil {
+		return err
+	}
+	if c.Format != FormatConsole && c.Format != FormatJSON {
+		return fmt.Errorf("log-format must be console or json, got %q", c.Format)
+	}
+	return nil
+}
+
+func parseLevel(s string) (zapcore.Level, error) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("log-level must be debug, info, warn or error, got %q", s)
+	}
+	return level, nil
+}
+
+// New returns the logger described by c, writing to stderr, and installs it
+// as the global zap logger.
+func New(c *Config) (*zap.Logger, error) {
+	level, err := parseLevel(c.Level)
+	if err != nil {
+		return nil, err
+	}
+	zc := zap.NewProductionConfig()
+	zc.Level = zap.NewAtomicLevelAt(level)
+	//every call gets logged, none may be dropped
+	zc.Sampling = nil
+	//the stack of the interceptor logging a failed call says nothing
+	zc.DisableStacktrace = true
+	zc.EncoderConfig.TimeKey = "time"
+	zc.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	zc.EncoderConfig.EncodeDuration = zapcore.StringDurationEncoder
+	if c.Format == FormatConsole {
+		zc.Encoding = "console"
+		zc.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	}
+	logger, err := zc.Build()
+	if err != nil {
+		return nil, fmt.Errorf("creating logger: %w", err)
+	}
+	zap.ReplaceGlobals(logger)
+	return logger, nil
+}
+
+type loggerKey struct{}
+
+// FromContext returns the logger of the call ctx belongs to, or the global
+// logger outside of calls.
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return zap.L()
+}
+
+// ServerOptions returns the interceptors giving every call its logger and
+// logging how each call ended. They should come after the tracing
+// interceptors, so that the logger of a call carries its trace id, and
+// before the metrics and recovery interceptors.
+func ServerOptions(logger *zap.Logger) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, l, id := callLogger(ctx, logger, info.FullMethod)
+			grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+			start := time.Now()
+			res, err := handler(ctx, req)
+			logCall(l, info.FullMethod, start, err)
+			return res, err
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, l, id := callLogger(ss.Context(), logger, info.FullMethod)
+			ss.SetHeader(metadata.Pairs(RequestIDKey, id))
+			start := time.Now()
+			err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+			logCall(l, info.FullMethod, start, err)
+			return err
+		}),
+	}
+}
+
+// serverStream replaces the context of a stream with one carrying the
+// logger of the call.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// callLogger returns ctx with the logger of the call, which it also returns
+// together with the request id.
+func callLogger(ctx context.Context, logger *zap.Logger, method string) (context.Context, *zap.Logger, string) {
+	id := requestID(ctx)
+	fields := []zap.Field{zap.String("request_id", id), zap.String("method", method)}
+	if p, ok := peer.FromContext(ctx); ok {
+		fields = append(fields, zap.Stringer("peer", p.Addr))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, zap.Stringer("trace_id", sc.TraceID()))
+	}
+	l := logger.With(fields...)
+	return context.WithValue(ctx, loggerKey{}, l), l, id
+}
+
+// requestID returns the request id sent by the client, or a new one.
+func requestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(RequestIDKey); len(ids) > 0 && ids[0] != "" && len(ids[0]) <= maxRequestIDLength {
+		return ids[0]
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// logCall logs the end of a call: failures the server is to blame for as
+// errors, those of the client as warnings and the others as info.
+func logCall(l *zap.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	fields := []zap.Field{zap.Stringer("code", code), zap.Duration("duration", time.Since(start))}
+	if err != nil {
+		fields = append(fields, zap.String("error", status.Convert(err).Message()))
+	}
+	switch code {
+	case codes.OK, codes.Canceled:
+		if method == healthCheckMethod {
+			l.Debug("call finished", fields...)
+			return
+		}
+		l.Info("call finished", fields...)
+	case codes.Unknown, codes.Internal, codes.Unimplemented, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded:
+		l.Error("call failed", fields...)
+	default:
+		l.Warn("call failed", fields...)
+	}
+}
@@ -0,0 +1,194 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"console", Config{Level: "info", Format: FormatConsole}, false},
+		{"json debug", Config{Level: "debug", Format: FormatJSON}, false},
+		{"unknown level", Config{Level: "verbose", Format: FormatConsole}, true},
+		{"unknown format", Config{Level: "info", Format: "logfmt"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	lis := bufconn.Listen(1 << 20)
+	opts := append(ServerOptions(zap.New(core)),
+		//a handler logs with the logger of its call
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			FromContext(ctx).Info("handling")
+			return handler(ctx, req)
+		}))
+	s := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := healthpb.NewHealthClient(conn)
+
+	t.Run("request id from the client", func(t *testing.T) {
+		logs.TakeAll()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIDKey, "req-1")
+		var header metadata.MD
+		if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if got := header.Get(RequestIDKey); len(got) != 1 || got[0] != "req-1" {
+			t.Errorf("header %v = %v, want [req-1]", RequestIDKey, got)
+		}
+		entries := logs.TakeAll()
+		if len(entries) != 2 {
+			t.Fatalf("logged %d entries, want 2: %v", len(entries), entries)
+		}
+		for _, e := range entries {
+			fields := e.ContextMap()
+			if fields["request_id"] != "req-1" || fields["method"] != healthCheckMethod || fields["peer"] == nil {
+				t.Errorf("entry %q has fields %v, want the request id, method and peer of the call", e.Message, fields)
+			}
+		}
+		//successful health checks would flood the log at info
+		if end := entries[1]; end.Message != "call finished" || end.Level != zapcore.DebugLevel {
+			t.Errorf("health check ended with %v %q, want debug call finished", end.Level, end.Message)
+		}
+	})
+
+	t.Run("request id from the server", func(t *testing.T) {
+		logs.TakeAll()
+		var header metadata.MD
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"}, grpc.Header(&header))
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("Check of an unknown service = %v, want NotFound", err)
+		}
+		ids := header.Get(RequestIDKey)
+		if len(ids) != 1 || ids[0] == "" {
+			t.Fatalf("header %v = %v, want a new request id", RequestIDKey, ids)
+		}
+		entries := logs.FilterMessage("call failed").AllUntimed()
+		if len(entries) != 1 {
+			t.Fatalf("logged %d failed calls, want 1", len(entries))
+		}
+		e := entries[0]
+		fields := e.ContextMap()
+		if e.Level != zapcore.WarnLevel || fields["request_id"] != ids[0] || fields["code"] != "NotFound" || fields["error"] == nil {
+			t.Errorf("failed call logged as %v %v, want a warning with the request id, code and error", e.Level, fields)
+		}
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		sent   string
+		wantIt bool
+	}{
+		{"sent", "req-1", true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("x", maxRequestIDLength+1), false},
+		{"longest", strings.Repeat("x", maxRequestIDLength), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, tt.sent))
+			got := requestID(ctx)
+			if (got == tt.sent) != tt.wantIt || got == "" {
+				t.Errorf("requestID() = %q with %q sent, want it used %v", got, tt.sent, tt.wantIt)
+			}
+		})
+	}
+	if a, b := requestID(context.Background()), requestID(context.Background()); a == b {
+		t.Errorf("requestID() returned %q twice", a)
+	}
+}
+
+func TestCallLoggerTraceID(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	ctx, _, _ = callLogger(ctx, zap.New(core), "/blog.BlogService/ReadBlog")
+	FromContext(ctx).Info("handling")
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	if got := entries[0].ContextMap()["trace_id"]; got != sc.TraceID().String() {
+		t.Errorf("trace_id = %v, want %v", got, sc.TraceID())
+	}
+}
+
+func TestFromContextOutsideCalls(t *testing.T) {
+	if got := FromContext(context.Background()); got != zap.L() {
+		t.Errorf("FromContext() outside of calls = %p, want the global logger %p", got, zap.L())
+	}
+}
+
+func TestLogCallLevels(t *testing.T) {
+	tests := []struct {
+		method string
+		err    error
+		want   zapcore.Level
+	}{
+		{"/blog.BlogService/ReadBlog", nil, zapcore.InfoLevel},
+		{healthCheckMethod, nil, zapcore.DebugLevel},
+		{"/blog.BlogService/WatchBlogs", status.Error(codes.Canceled, "gone"), zapcore.InfoLevel},
+		{"/blog.BlogService/ReadBlog", status.Error(codes.NotFound, "no blog"), zapcore.WarnLevel},
+		{"/blog.BlogService/CreateBlog", status.Error(codes.InvalidArgument, "no title"), zapcore.WarnLevel},
+		{"/blog.BlogService/ReadBlog", status.Error(codes.Internal, "store down"), zapcore.ErrorLevel},
+		{"/blog.BlogService/ReadBlog", status.Error(codes.Unavailable, "draining"), zapcore.ErrorLevel},
+		{"/blog.BlogService/ReadBlog", errors.New("not a status"), zapcore.ErrorLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+status.Code(tt.err).String(), func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			logCall(zap.New(core), tt.method, time.Now(), tt.err)
+			entries := logs.AllUntimed()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			if entries[0].Level != tt.want {
+				t.Errorf("logged at %v, want %v", entries[0].Level, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package metrics records Prometheus metrics of the calls to a grpc server
+// and serves them, together with everything else in the default registry
+// such as the blog store metrics, over HTTP on /metrics.
+//
+// Per method it counts the calls started, the calls handled by status code
+// and the messages received and sent on streams, and keeps a histogram of
+// how long the calls took.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	//the latency histogram is off unless asked for
+	grpc_prometheus.EnableHandlingTimeHistogram()
+}
+
+// ServerOptions returns the interceptors recording the call metrics. They
+// should come after the tracing and logging interceptors and before the
+// recovery interceptors. Tracing and logging go first so that every log line
+// of a call carries its trace id, which the metrics have no use for. Before
+// recovery, a panic is counted as the INTERNAL status it turns into, and the
+// calls refused by later interceptors, e.g. for auth, are counted too.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(grpc_prometheus.UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(grpc_prometheus.StreamServerInterceptor),
+	}
+}
+
+// Register exports the metrics of every method registered with s, with
+// zero values until they are called. Call it once the services are
+// registered.
+func Register(s *grpc.Server) {
+	grpc_prometheus.Register(s)
+}
+
+// Server serves the metrics over HTTP.
+type Server struct {
+	http *http.Server
+}
+
+// Serve starts serving the metrics on addr in the background. It returns a
+// nil Server, which is safe to close, when addr is empty.
+func Serve(addr string) (*Server, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening for metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	s := &Server{http: &http.Server{Handler: mux}}
+	go func() {
+		if err := s.http.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zap.L().Error("Failed to serve metrics", zap.Error(err))
+		}
+	}()
+	return s, nil
+}
+
+// Close stops serving the metrics.
+func (s *Server) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.http.Close()
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	return addr
+}
+
+// scrape returns the metrics served on addr.
+func scrape(t *testing.T, addr string) string {
+	t.Helper()
+	res, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("scraping the metrics failed: %v", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("scraping the metrics returned %v", res.Status)
+	}
+	return string(body)
+}
+
+// value returns the value of series in the scraped metrics, 0 when they
+// lack it.
+func value(t *testing.T, metrics, series string) float64 {
+	t.Helper()
+	for _, line := range strings.Split(metrics, "\n") {
+		if v := strings.TrimPrefix(line, series+" "); v != line {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				t.Fatalf("bad value of %s: %v", series, err)
+			}
+			return f
+		}
+	}
+	return 0
+}
+
+func TestCallsAreCounted(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(ServerOptions()...)
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	Register(s)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := healthpb.NewHealthClient(conn)
+
+	addr := freeAddr(t)
+	ms, err := Serve(addr)
+	if err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	t.Cleanup(func() { ms.Close() })
+	//the metrics are global, compare with what earlier tests left behind
+	before := scrape(t, addr)
+	if !strings.Contains(before, `grpc_server_started_total{grpc_method="Watch",grpc_service="grpc.health.v1.Health",grpc_type="server_stream"}`) {
+		t.Error("registered methods are not exported before they are called")
+	}
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	//an unknown service is NOT_FOUND
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"}); err == nil {
+		t.Fatal("Check of an unknown service succeeded")
+	}
+
+	after := scrape(t, addr)
+	for _, tc := range []struct {
+		series string
+		want   float64
+	}{
+		{`grpc_server_started_total{grpc_method="Check",grpc_service="grpc.health.v1.Health",grpc_type="unary"}`, 2},
+		{`grpc_server_handled_total{grpc_code="OK",grpc_method="Check",grpc_service="grpc.health.v1.Health",grpc_type="unary"}`, 1},
+		{`grpc_server_handled_total{grpc_code="NotFound",grpc_method="Check",grpc_service="grpc.health.v1.Health",grpc_type="unary"}`, 1},
+		{`grpc_server_handling_seconds_count{grpc_method="Check",grpc_service="grpc.health.v1.Health",grpc_type="unary"}`, 2},
+	} {
+		if got := value(t, after, tc.series) - value(t, before, tc.series); got != tc.want {
+			t.Errorf("%s went up by %v, want %v", tc.series, got, tc.want)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Run("off", func(t *testing.T) {
+		s, err := Serve("")
+		if err != nil || s != nil {
+			t.Fatalf("Serve(\"\") = %v, %v, want nil, nil", s, err)
+		}
+		if err := s.Close(); err != nil {
+			t.Errorf("closing a nil Server failed: %v", err)
+		}
+	})
+	t.Run("address in use", func(t *testing.T) {
+		lis, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lis.Close()
+		if _, err := Serve(lis.Addr().String()); err == nil {
+			t.Fatal("Serve on an address in use succeeded")
+		}
+	})
+	t.Run("closed", func(t *testing.T) {
+		addr := freeAddr(t)
+		s, err := Serve(addr)
+		if err != nil {
+			t.Fatalf("Serve failed: %v", err)
+		}
+		scrape(t, addr)
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if _, err := http.Get("http://" + addr + "/metrics"); err == nil {
+			t.Error("metrics are still served after Close")
+		}
+	})
+}
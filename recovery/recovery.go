@@ -0,0 +1,79 @@
+// Package recovery keeps a panicking call handler from taking the whole
+// server down. The panic only fails its own call, with INTERNAL, and is
+// logged with its stack trace and counted in grpc_server_panics_total.
+//
+// Panics in goroutines started by a handler are not recovered, those still
+// crash the server.
+package recovery
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Peter-Yocum/grpc-go-course/logging"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// reasonInternal is the ErrorInfo reason of the errors replacing panics.
+// Their domain is the service of the call, like the other errors it
+// returns.
+const reasonInternal = "INTERNAL"
+
+var panics = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "grpc_server_panics_total",
+	Help: "Calls whose handler panicked, by method.",
+}, []string{"grpc_service", "grpc_method"})
+
+// ServerOptions returns the interceptors recovering the panics of the
+// interceptors after them and of the handlers. They should come after the
+// logging and metrics interceptors, which then see the INTERNAL status of
+// the call instead of the panic.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					res, err = nil, recovered(ctx, info.FullMethod, p)
+				}
+			}()
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = recovered(ss.Context(), info.FullMethod, p)
+				}
+			}()
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// recovered logs and counts the panic p of a call to method and returns the
+// error the call fails with. It must be called by the deferred function
+// that recovered p, so that the logged stack trace leads to the panic.
+func recovered(ctx context.Context, method string, p interface{}) error {
+	service, name := splitMethod(method)
+	panics.WithLabelValues(service, name).Inc()
+	logging.FromContext(ctx).
+		WithOptions(zap.AddStacktrace(zapcore.ErrorLevel)).
+		Error("Handler panicked", zap.Any("panic", p))
+	//the panic value may hold anything, it stays in the server log
+	return rpcerr.New(codes.Internal, service, reasonInternal, "Internal error")
+}
+
+// splitMethod splits a full method name like "/greet.GreetService/Greet"
+// into its service and method.
+func splitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
@@ -0,0 +1,116 @@
+package recovery
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/Peter-Yocum/grpc-go-course/greet/greetpb"
+	"github.com/Peter-Yocum/grpc-go-course/rpcerr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// panickingServer panics in every call it implements.
+type panickingServer struct {
+	greetpb.UnimplementedGreetServiceServer
+}
+
+func (panickingServer) Greet(context.Context, *greetpb.GreetRequest) (*greetpb.GreetResponse, error) {
+	panic("unary handler failed")
+}
+
+func (panickingServer) GreetManyTimes(*greetpb.GreetManyTimesRequest, greetpb.GreetService_GreetManyTimesServer) error {
+	var m map[string]int
+	m["boom"]++
+	return nil
+}
+
+// newClient serves a panickingServer with the recovery interceptors and
+// returns a client connected to it.
+func newClient(t *testing.T) greetpb.GreetServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(ServerOptions()...)
+	greetpb.RegisterGreetServiceServer(s, panickingServer{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return greetpb.NewGreetServiceClient(conn)
+}
+
+func TestRecovery(t *testing.T) {
+	client := newClient(t)
+	tests := []struct {
+		name   string
+		method string
+		call   func(ctx context.Context) error
+	}{
+		{"unary", "Greet", func(ctx context.Context) error {
+			_, err := client.Greet(ctx, &greetpb.GreetRequest{})
+			return err
+		}},
+		{"stream", "GreetManyTimes", func(ctx context.Context) error {
+			stream, err := client.GreetManyTimes(ctx, &greetpb.GreetManyTimesRequest{})
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := panics.WithLabelValues("greet.GreetService", tt.method)
+			before := testutil.ToFloat64(counter)
+
+			//the server survives the panic and keeps answering
+			for i := 1; i <= 2; i++ {
+				err := tt.call(context.Background())
+				if status.Code(err) != codes.Internal {
+					t.Fatalf("call %d failed with %v, want Internal", i, err)
+				}
+				if !rpcerr.Is(err, "greet.GreetService", reasonInternal) {
+					t.Errorf("call %d failed with %v, want reason %v of greet.GreetService", i, rpcerr.Decode(err), reasonInternal)
+				}
+				//the panic value stays in the server log
+				if msg := status.Convert(err).Message(); msg != "Internal error" {
+					t.Errorf("call %d failed with message %q, want Internal error", i, msg)
+				}
+			}
+			if got := testutil.ToFloat64(counter) - before; got != 2 {
+				t.Errorf("counted %v panics, want 2", got)
+			}
+		})
+	}
+}
+
+func TestSplitMethod(t *testing.T) {
+	tests := []struct {
+		fullMethod    string
+		service, name string
+	}{
+		{"/greet.GreetService/Greet", "greet.GreetService", "Greet"},
+		{"/blog.BlogService/ListBlog", "blog.BlogService", "ListBlog"},
+		{"Greet", "unknown", "Greet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fullMethod, func(t *testing.T) {
+			service, name := splitMethod(tt.fullMethod)
+			if service != tt.service || name != tt.name {
+				t.Errorf("splitMethod(%q) = %q, %q, want %q, %q", tt.fullMethod, service, name, tt.service, tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package rpcerr
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Details is the decoded form of an error returned by a service.
+type Details struct {
+	Code    codes.Code
+	Message string
+	// The details below are nil when the error did not carry them.
+	ErrorInfo    *errdetails.ErrorInfo
+	BadRequest   *errdetails.BadRequest
+	ResourceInfo *errdetails.ResourceInfo
+}
+
+// Decode unpacks the status and known error details of err. It returns nil
+// for a nil error; errors that are not grpc statuses decode as Unknown.
+func Decode(err error) *Details {
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	d := &Details{Code: st.Code(), Message: st.Message()}
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			d.ErrorInfo = detail
+		case *errdetails.BadRequest:
+			d.BadRequest = detail
+		case *errdetails.ResourceInfo:
+			d.ResourceInfo = detail
+		}
+	}
+	return d
+}
+
+// Reason returns the ErrorInfo reason of err, or "" when it has none.
+func Reason(err error) string {
+	return Decode(err).reason()
+}
+
+// Is reports whether err was returned by domain with the given reason.
+func Is(err error, domain, reason string) bool {
+	d := Decode(err)
+	return d.reason() == reason && d.ErrorInfo.GetDomain() == domain
+}
+
+// FieldViolations returns the BadRequest descriptions of err by field.
+func FieldViolations(err error) map[string][]string {
+	d := Decode(err)
+	if d == nil || d.BadRequest == nil {
+		return nil
+	}
+	fields := make(map[string][]string)
+	for _, v := range d.BadRequest.GetFieldViolations() {
+		fields[v.GetField()] = append(fields[v.GetField()], v.GetDescription())
+	}
+	return fields
+}
+
+func (d *Details) reason() string {
+	if d == nil {
+		return ""
+	}
+	return d.ErrorInfo.GetReason()
+}
+
+// String formats the error for people, one detail per line.
+func (d *Details) String() string {
+	if d == nil {
+		return "OK"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v: %v", d.Code, d.Message)
+	if d.ErrorInfo != nil {
+		fmt.Fprintf(&b, "\n  reason: %v (%v)", d.ErrorInfo.GetReason(), d.ErrorInfo.GetDomain())
+	}
+	if d.ResourceInfo != nil {
+		fmt.Fprintf(&b, "\n  resource: %v %q", d.ResourceInfo.GetResourceType(), d.ResourceInfo.GetResourceName())
+	}
+	for _, v := range d.BadRequest.GetFieldViolations() {
+		fmt.Fprintf(&b, "\n  %v: %v", v.GetField(), v.GetDescription())
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+// Package rpcerr builds and decodes grpc errors carrying google.rpc error
+// details, shared by the blog, calculator and greet services.
+//
+// Every error made here has an ErrorInfo whose Reason is a stable UPPER_SNAKE
+// code clients can switch on, and whose Domain names the service that
+// returned it. Depending on the failure it also has a BadRequest listing the
+// offending request fields or a ResourceInfo naming the missing resource.
+package rpcerr
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+// New returns a status error with the given code and message, an ErrorInfo
+// for domain and reason, and any further details.
+func New(code codes.Code, domain, reason, msg string, details ...protoiface.MessageV1) error {
+	return NewWithMetadata(code, domain, reason, nil, msg, details...)
+}
+
+// NewWithMetadata is New with metadata added to the ErrorInfo.
+func NewWithMetadata(code codes.Code, domain, reason string, metadata map[string]string, msg string, details ...protoiface.MessageV1) error {
+	st := status.New(code, msg)
+	all := append([]protoiface.MessageV1{&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   domain,
+		Metadata: metadata,
+	}}, details...)
+	detailed, err := st.WithDetails(all...)
+	if err != nil {
+		//the details could not be marshalled, the plain status is still
+		//better than nothing
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// FieldViolation describes what is wrong with one request field. Nested
+// fields are written as a dotted path, e.g. "blog.title".
+func FieldViolation(field, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{Field: field, Description: description}
+}
+
+// BadRequest returns an InvalidArgument error listing the violations, or nil
+// when there are none.
+func BadRequest(domain, reason string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(violations))
+	for _, v := range violations {
+		msgs = append(msgs, v.GetField()+" "+v.GetDescription())
+	}
+	return New(codes.InvalidArgument, domain, reason,
+		fmt.Sprintf("Invalid request sent: %v", strings.Join(msgs, "; ")),
+		&errdetails.BadRequest{FieldViolations: violations},
+	)
+}
+
+// NotFound returns a NotFound error naming the missing resource.
+func NotFound(domain, reason, resourceType, resourceName, msg string) error {
+	return Resource(codes.NotFound, domain, reason, resourceType, resourceName, msg)
+}
+
+// Resource returns an error about a specific resource, e.g. one that exists
+// but is in the wrong state for the request.
+func Resource(code codes.Code, domain, reason, resourceType, resourceName, msg string) error {
+	return New(code, domain, reason, msg, &errdetails.ResourceInfo{
+		ResourceType: resourceType,
+		ResourceName: resourceName,
+		Description:  msg,
+	})
+}
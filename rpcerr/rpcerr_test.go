@@ -0,0 +1,81 @@
+package rpcerr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+const domain = "test.TestService"
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		code     codes.Code
+		reason   string
+		resource string
+		fields   map[string][]string
+	}{
+		{"new", New(codes.Unavailable, domain, "DOWN", "Service is down"), codes.Unavailable, "DOWN", "", nil},
+		{"metadata", NewWithMetadata(codes.Aborted, domain, "RETRY", map[string]string{"after": "1s"}, "Retry later"), codes.Aborted, "RETRY", "", nil},
+		{"not found", NotFound(domain, "ITEM_NOT_FOUND", "test.Item", "item-1", "No such item"), codes.NotFound, "ITEM_NOT_FOUND", "item-1", nil},
+		{"resource", Resource(codes.FailedPrecondition, domain, "ITEM_LOCKED", "test.Item", "item-2", "Item is locked"), codes.FailedPrecondition, "ITEM_LOCKED", "item-2", nil},
+		{"bad request", BadRequest(domain, "INVALID", FieldViolation("item.name", "is required"), FieldViolation("item.size", "must be positive"), FieldViolation("item.name", "is too short")),
+			codes.InvalidArgument, "INVALID", "", map[string][]string{"item.name": {"is required", "is too short"}, "item.size": {"must be positive"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Decode(tt.err)
+			if d.Code != tt.code {
+				t.Errorf("code = %v, want %v", d.Code, tt.code)
+			}
+			if !Is(tt.err, domain, tt.reason) || Reason(tt.err) != tt.reason {
+				t.Errorf("reason = %v, want %v of %v", d.ErrorInfo, tt.reason, domain)
+			}
+			if Is(tt.err, "other.Service", tt.reason) {
+				t.Errorf("Is() matched the reason of another domain")
+			}
+			if got := d.ResourceInfo.GetResourceName(); got != tt.resource {
+				t.Errorf("resource = %q, want %q", got, tt.resource)
+			}
+			if got := FieldViolations(tt.err); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("FieldViolations() = %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestBadRequestWithoutViolations(t *testing.T) {
+	if err := BadRequest(domain, "INVALID"); err != nil {
+		t.Errorf("BadRequest() without violations = %v, want nil", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if d := Decode(nil); d != nil || d.String() != "OK" || Reason(nil) != "" {
+		t.Errorf("Decode(nil) = %v, want nil", d)
+	}
+	//errors from outside grpc have no details
+	d := Decode(errors.New("disk full"))
+	if d.Code != codes.Unknown || d.Message != "disk full" || d.ErrorInfo != nil {
+		t.Errorf("Decode() of a plain error = %+v, want Unknown without details", d)
+	}
+
+	err := BadRequest(domain, "INVALID", FieldViolation("item.name", "is required"))
+	want := "InvalidArgument: Invalid request sent: item.name is required\n" +
+		"  reason: INVALID (test.TestService)\n" +
+		"  item.name: is required"
+	if got := Decode(err).String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	err = NotFound(domain, "ITEM_NOT_FOUND", "test.Item", "item-1", "No such item")
+	want = "NotFound: No such item\n" +
+		"  reason: ITEM_NOT_FOUND (test.TestService)\n" +
+		"  resource: test.Item \"item-1\""
+	if got := Decode(err).String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+// Package tracing traces grpc calls with OpenTelemetry. Clients start a span
+// for every call and pass its W3C trace context to the server in the call
+// metadata, where the server continues the trace, so that a call shows up
+// as one trace from the client down to the blog store.
+//
+// Spans are exported to an OTLP collector or appended as JSON lines to a
+// local file for offline debugging. With tracing off the trace context is
+// still passed on, so a server in the middle doesn't break a trace.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/Peter-Yocum/grpc-go-course/config"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"google.golang.org/grpc"
+)
+
+// Exporters accepted by the tracing setting.
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// Config selects where spans are exported.
+type Config struct {
+	Exporter string
+	// Endpoint is the host:port of the OTLP collector, reached over grpc
+	// without TLS.
+	Endpoint string
+	// File is the file spans are appended to.
+	File string
+}
+
+// AddSettings registers the tracing settings with cfg and validates them.
+func AddSettings(cfg *config.Loader) *Config {
+	c := &Config{}
+	cfg.String(&c.Exporter, "tracing", ExporterNone, "where spans are exported: none, otlp or file")
+	cfg.String(&c.Endpoint, "tracing-endpoint", "localhost:4317", "host:port of the OTLP collector used by tracing=otlp")
+	cfg.String(&c.File, "tracing-file", "traces.json", "file the spans are appended to by tracing=file")
+	cfg.Check(c.validate)
+	return c
+}
+
+func (c *Config) validate() error {
+	switch c.Exporter {
+	case ExporterNone, ExporterOTLP, ExporterFile:
+	default:
+		return fmt.Errorf("tracing must be none, otlp or file, got %q", c.Exporter)
+	}
+	if c.Exporter == ExporterOTLP && c.Endpoint == "" {
+		return errors.New("tracing=otlp needs a tracing-endpoint")
+	}
+	if c.Exporter == ExporterFile && c.File == "" {
+		return errors.New("tracing=file needs a tracing-file")
+	}
+	return nil
+}
+
+// Start installs the global tracer provider of the program called service,
+// exporting the spans as c says, and the W3C trace context propagator. The
+// returned function flushes the spans still buffered and stops exporting,
+// it should be called before the program exits.
+func Start(ctx context.Context, service string, c *Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	switch c.Exporter {
+	case ExporterOTLP:
+		var err error
+		exporter, err = otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(c.Endpoint), otlptracegrpc.WithInsecure())
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+		}
+	case ExporterFile:
+		var err error
+		file, err = os.OpenFile(c.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("opening tracing-file: %w", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("creating file exporter: %w", err)
+		}
+	default:
+		//nothing to export, the default provider drops every span
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(service)))
+	if err != nil {
+		return nil, fmt.Errorf("creating tracing resource: %w", err)
+	}
+	open := &openSpans{}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(open),
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		//otelgrpc ends the span of a client stream in a goroutine of its
+		//own, give the spans about to end a moment to do so
+		open.wait(ctx, time.Second)
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// openSpans counts the spans started but not ended yet.
+type openSpans struct {
+	n int64
+}
+
+func (o *openSpans) OnStart(context.Context, sdktrace.ReadWriteSpan) { atomic.AddInt64(&o.n, 1) }
+func (o *openSpans) OnEnd(sdktrace.ReadOnlySpan)                     { atomic.AddInt64(&o.n, -1) }
+func (o *openSpans) Shutdown(context.Context) error                  { return nil }
+func (o *openSpans) ForceFlush(context.Context) error                { return nil }
+
+// wait waits up to max, or until ctx ends, for every span to end.
+func (o *openSpans) wait(ctx context.Context, max time.Duration) {
+	deadline := time.NewTimer(max)
+	defer deadline.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&o.n) > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServerOptions returns the interceptors continuing the traces of incoming
+// calls. They should come first on the server, before the logging, metrics
+// and recovery interceptors, so that the span covers the whole call and the
+// logger of the call can carry its trace id.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(otelgrpc.StreamServerInterceptor()),
+	}
+}
+
+// DialOptions returns the interceptors starting a span for every call and
+// sending its trace context along.
+func DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(otelgrpc.StreamClientInterceptor()),
+	}
+}
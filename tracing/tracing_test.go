@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"none", Config{Exporter: ExporterNone}, false},
+		{"otlp", Config{Exporter: ExporterOTLP, Endpoint: "localhost:4317"}, false},
+		{"file", Config{Exporter: ExporterFile, File: "traces.json"}, false},
+		{"unknown exporter", Config{Exporter: "jaeger"}, true},
+		{"otlp without endpoint", Config{Exporter: ExporterOTLP}, true},
+		{"file without file", Config{Exporter: ExporterFile}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// exportedSpan holds the fields of a span written by the file exporter that
+// the tests look at.
+type exportedSpan struct {
+	Name        string
+	SpanKind    trace.SpanKind
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		TraceID string
+		SpanID  string
+	}
+}
+
+// readSpans returns the spans exported to path.
+func readSpans(t *testing.T, path string) []exportedSpan {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var spans []exportedSpan
+	dec := json.NewDecoder(f)
+	for {
+		var span exportedSpan
+		if err := dec.Decode(&span); errors.Is(err, io.EOF) {
+			return spans
+		} else if err != nil {
+			t.Fatalf("decoding the exported spans failed: %v", err)
+		}
+		spans = append(spans, span)
+	}
+}
+
+func TestTraceContinuesOnTheServer(t *testing.T) {
+	//Start replaces the global provider, put the default one back
+	provider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Start(context.Background(), "test", &Config{Exporter: ExporterFile, File: path})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(ServerOptions()...)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+	opts := append(DialOptions(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutting down tracing failed: %v", err)
+	}
+
+	spans := readSpans(t, path)
+	byKind := map[trace.SpanKind]exportedSpan{}
+	for _, span := range spans {
+		if span.Name != "grpc.health.v1.Health/Check" {
+			t.Errorf("unexpected span %q", span.Name)
+		}
+		byKind[span.SpanKind] = span
+	}
+	client, ok := byKind[trace.SpanKindClient]
+	if !ok {
+		t.Fatalf("no client span among %+v", spans)
+	}
+	server, ok := byKind[trace.SpanKindServer]
+	if !ok {
+		t.Fatalf("no server span among %+v", spans)
+	}
+	if server.SpanContext.TraceID != client.SpanContext.TraceID {
+		t.Errorf("server span is in trace %v, want the client's %v", server.SpanContext.TraceID, client.SpanContext.TraceID)
+	}
+	if server.Parent.SpanID != client.SpanContext.SpanID {
+		t.Errorf("server span has parent %v, want the client span %v", server.Parent.SpanID, client.SpanContext.SpanID)
+	}
+}
+
+func TestStartWithoutExporter(t *testing.T) {
+	provider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+
+	shutdown, err := Start(context.Background(), "test", &Config{Exporter: ExporterNone})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown failed: %v", err)
+	}
+	if got := otel.GetTracerProvider(); got != provider {
+		t.Errorf("Start replaced the tracer provider with %T without an exporter", got)
+	}
+}
+
+func TestStartFileError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "traces.json")
+	if _, err := Start(context.Background(), "test", &Config{Exporter: ExporterFile, File: path}); err == nil {
+		t.Fatal("Start with a tracing-file in a missing directory succeeded")
+	}
+}